@@ -3,10 +3,8 @@
 package alerter
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"os/exec"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -18,26 +16,29 @@ import (
 	"github.com/eleme/banshee/util/safemap"
 )
 
-const (
-	// Limit for buffered detected metric results, further results will be dropped
-	// if this limit is reached.
-	bufferedMetricResultsLimit = 10 * 1024
-	// Exec command timeout in second
-	execCommandTimeout = 5 * time.Second
-)
+// Limit for buffered detected metric results, further results will be dropped
+// if this limit is reached.
+const bufferedMetricResultsLimit = 10 * 1024
 
 // Alerter alerts on anomalies detected.
 type Alerter struct {
 	// Storage
 	db *storage.DB
-	// Config
-	cfg *config.Config
+	// Config, replaced on reloading.
+	cfg     *config.Config
+	cfgLock sync.RWMutex
 	// Input
 	In chan *models.Event
 	// Alertings stamps
 	m *safemap.SafeMap
 	// Alertings counters
 	c *safemap.SafeMap
+	// Alerted incidents stamps, by metric name and rule id.
+	incs *safemap.SafeMap
+	// Notifiers
+	notifiers []Notifier
+	// Event groups for digests.
+	groups *groups
 }
 
 // New creates a alerter.
@@ -48,25 +49,131 @@ func New(cfg *config.Config, db *storage.DB) *Alerter {
 	al.In = make(chan *models.Event, bufferedMetricResultsLimit)
 	al.m = safemap.New()
 	al.c = safemap.New()
+	al.incs = safemap.New()
+	al.notifiers = newNotifiers(cfg)
+	al.groups = newGroups()
 	return al
 }
 
+// config returns the current config.
+func (al *Alerter) config() *config.Config {
+	al.cfgLock.RLock()
+	defer al.cfgLock.RUnlock()
+	return al.cfg
+}
+
+// SetConfig replaces the config on reloading, only the reloadable fields
+// take effect, see config.CopyReloadable.
+func (al *Alerter) SetConfig(cfg *config.Config) {
+	al.cfgLock.Lock()
+	defer al.cfgLock.Unlock()
+	al.cfg = cfg
+}
+
+// AddNotifier adds a notifier to send alerting messages.
+func (al *Alerter) AddNotifier(n Notifier) {
+	al.notifiers = append(al.notifiers, n)
+}
+
 // Start several goroutines to wait for detected metrics, then check each
-// metric with all the rules, the configured shell command will be executed
-// once a rule is hit.
+// metric with all the rules, the configured notifiers will be called once a
+// rule is hit.
 func (al *Alerter) Start() {
-	log.Infof("start %d alerter workers..", al.cfg.Alerter.Workers)
-	for i := 0; i < al.cfg.Alerter.Workers; i++ {
+	log.Infof("start %d alerter workers..", al.config().Alerter.Workers)
+	if len(al.notifiers) == 0 {
+		log.Warnf("no alerter notifiers configured")
+	}
+	for i := 0; i < al.config().Alerter.Workers; i++ {
 		go al.work()
 	}
 	go func() {
 		ticker := time.NewTicker(time.Hour * 24)
 		for _ = range ticker.C {
 			al.c.Clear()
+			al.expireIncidents()
+			al.expireSnoozes()
 		}
 	}()
 }
 
+// incidentKey returns the key of the alerted incident for metric and rule.
+func incidentKey(name string, rule *models.Rule) string {
+	return fmt.Sprintf("%s:%d", name, rule.ID)
+}
+
+// renewIncident renews the stamp of the alerted incident by key if any, the
+// incident keeps open as long as its events come, even not notified.
+func (al *Alerter) renewIncident(key string, stamp uint32) {
+	if al.incs.Has(key) {
+		al.incs.Set(key, stamp)
+	}
+}
+
+// expireIncidents removes alerted incidents without any events in a period,
+// i.e. the metric is gone or the rule is deleted.
+func (al *Alerter) expireIncidents() {
+	stamp := uint32(time.Now().Unix()) - al.config().Period
+	for k, v := range al.incs.Items() {
+		if v.(uint32) < stamp {
+			al.incs.Delete(k)
+		}
+	}
+}
+
+// isSnoozed tests if the metric of the event is snoozed or acknowledged on
+// the rule, acknowledgements are removed once the event is resolved.
+func (al *Alerter) isSnoozed(ev *models.Event, rule *models.Rule) bool {
+	now := uint32(time.Now().Unix())
+	var snoozes []models.Snooze
+	err := al.db.Admin.DB().Where("metric = ? AND (rule_id = ? OR rule_id = 0) AND until > ?",
+		ev.Metric.Name, rule.ID, now).Find(&snoozes).Error
+	if err != nil {
+		log.Errorf("get snoozes: %v", err)
+		return false
+	}
+	if !ev.Resolved {
+		return len(snoozes) > 0
+	}
+	snoozed := false
+	for _, snooze := range snoozes {
+		if !snooze.Ack {
+			snoozed = true
+			continue
+		}
+		// Acknowledgement ends.
+		if err := al.db.Admin.DB().Delete(&snooze).Error; err != nil {
+			log.Errorf("delete ack: %v", err)
+		}
+	}
+	return snoozed
+}
+
+// inMaintenance tests if the project or the rule is in a maintenance window
+// now.
+func (al *Alerter) inMaintenance(proj *models.Project, rule *models.Rule) bool {
+	var mts []models.Maintenance
+	err := al.db.Admin.DB().Where("project_id = ? OR rule_id = ?", proj.ID, rule.ID).Find(&mts).Error
+	if err != nil {
+		log.Errorf("get maintenances: %v", err)
+		return false
+	}
+	now := time.Now()
+	for i := 0; i < len(mts); i++ {
+		if mts[i].IsActive(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// expireSnoozes deletes expired snoozes from db.
+func (al *Alerter) expireSnoozes() {
+	now := uint32(time.Now().Unix())
+	if err := al.db.Admin.DB().Where("until <= ?", now).Delete(&models.Snooze{}).Error; err != nil {
+		log.Errorf("expire snoozes: %v", err)
+	}
+}
+
 // Test if an hour is in [start, end)
 func hourInRange(hour, start, end int) bool {
 	switch {
@@ -89,64 +196,102 @@ func (al *Alerter) shouldSilent(proj *models.Project) bool {
 		end = proj.SilentTimeEnd
 	} else {
 		// Default
-		start = al.cfg.Alerter.DefaultSilentTimeRange[0]
-		end = al.cfg.Alerter.DefaultSilentTimeRange[1]
+		rng := al.config().Alerter.DefaultSilentTimeRange
+		start = rng[0]
+		end = rng[1]
 	}
 	now := time.Now().Hour()
 	return hourInRange(now, start, end)
 }
 
-// execute command with event within certain timeout.
-func (al *Alerter) execCommand(ev *models.Event) error {
-	b, _ := json.Marshal(ev)
-	arg := string(b)
-	done := make(chan error)
-	cmd := exec.Command(al.cfg.Alerter.Command, arg)
-	go func() {
-		done <- cmd.Run()
-	}()
-	timeout := time.After(execCommandTimeout)
-	select {
-	case <-timeout:
-		err := cmd.Process.Kill()
-		if err == nil {
-			err = errors.New("command timed out, killed")
-		} else {
-			s := fmt.Sprintf("failed to kill command: %v", err)
-			err = errors.New(s)
+// notify sends messages for the event to receivers via all notifiers,
+// returns the number of messages sent.
+func (al *Alerter) notify(ev *models.Event, receivers []models.User) int {
+	sent := 0
+	for _, n := range al.notifiers {
+		num, err := n.Notify(ev, receivers)
+		if err != nil {
+			log.Errorf("notify %s via %s: %v", ev.Metric.Name, n.Name(), err)
 		}
-		go func() {
-			<-done // exit the prev goroutine
-		}()
-		return err
-	case err := <-done:
-		return err
+		sent += num
+	}
+	return sent
+}
+
+// Status of an event recorded.
+const (
+	// Notifications sent.
+	statusSent = iota
+	// Not sent for reasons.
+	statusSilenced
+	statusRateLimited
+	statusSnoozed
+	statusInMaintenance
+)
+
+// record persists the event for its rule into the events history.
+func (al *Alerter) record(ev *models.Event, receivers []models.User, status int) {
+	r := models.NewEventRecord(ev, ev.Rule)
+	r.SetReceivers(receivers)
+	switch status {
+	case statusSilenced:
+		r.Silenced = true
+	case statusRateLimited:
+		r.RateLimited = true
+	case statusSnoozed:
+		r.Snoozed = true
+	case statusInMaintenance:
+		r.InMaintenance = true
+	}
+	if err := al.db.Event.Put(r); err != nil {
+		log.Errorf("record event %s: %v", ev.Metric.Name, err)
 	}
 }
 
+// isRateLimited tests if the alerting of the event's metric should be
+// limited by interval or alert times in one day, or is waiting in a digest.
+func (al *Alerter) isRateLimited(ev *models.Event) bool {
+	// Check interval.
+	v, ok := al.m.Get(ev.Metric.Name)
+	if ok && ev.Metric.Stamp-v.(uint32) < al.config().Alerter.Interval {
+		return true
+	}
+	// Check digests.
+	if al.groups.pending(ev.Metric.Name) {
+		return true
+	}
+	// Check alert times in one day
+	v, ok = al.c.Get(ev.Metric.Name)
+	if ok && atomic.LoadUint32(v.(*uint32)) > al.config().Alerter.OneDayLimit {
+		log.Warnf("%s hit alerting one day limit, skipping..", ev.Metric.Name)
+		return true
+	}
+	if !ok {
+		var newCounter uint32
+		newCounter = 1
+		al.c.Set(ev.Metric.Name, &newCounter)
+	} else {
+		atomic.AddUint32(v.(*uint32), 1)
+	}
+	return false
+}
+
 // work waits for detected metrics, then check each metric with all the
-// rules, the configured shell command will be executed once a rule is hit.
+// rules, the notifiers will be called once a rule is hit. Events are
+// recorded into history, including the silenced, rate limited, snoozed and
+// in maintenance ones.
 func (al *Alerter) work() {
 	for {
 		ev := <-al.In
-		// Check interval.
-		v, ok := al.m.Get(ev.Metric.Name)
-		if ok && ev.Metric.Stamp-v.(uint32) < al.cfg.Alerter.Interval {
-			continue
-		}
-		// Check alert times in one day
-		v, ok = al.c.Get(ev.Metric.Name)
-		if ok && atomic.LoadUint32(v.(*uint32)) > al.cfg.Alerter.OneDayLimit {
-			log.Warnf("%s hit alerting one day limit, skipping..", ev.Metric.Name)
+		if !ev.Resolved && al.isRateLimited(ev) {
+			for _, rule := range ev.Metric.TestedRules {
+				ev.Rule = rule
+				ev.TranslateRuleComment()
+				al.renewIncident(incidentKey(ev.Metric.Name, rule), ev.Metric.Stamp)
+				al.record(ev, nil, statusRateLimited)
+			}
 			continue
 		}
-		if !ok {
-			var newCounter uint32
-			newCounter = 1
-			al.c.Set(ev.Metric.Name, &newCounter)
-		} else {
-			atomic.AddUint32(v.(*uint32), 1)
-		}
 		// Universals
 		var univs []models.User
 		if err := al.db.Admin.DB().Where("universal = ?", true).Find(&univs).Error; err != nil {
@@ -156,6 +301,20 @@ func (al *Alerter) work() {
 		for _, rule := range ev.Metric.TestedRules {
 			ev.Rule = rule
 			ev.TranslateRuleComment()
+			key := incidentKey(ev.Metric.Name, rule)
+			if !ev.Resolved {
+				al.renewIncident(key, ev.Metric.Stamp)
+			}
+			// Resolved events are only sent and recorded for incidents
+			// alerted.
+			if ev.Resolved && !al.incs.Delete(key) {
+				continue
+			}
+			// Snooze
+			if al.isSnoozed(ev, rule) {
+				al.record(ev, nil, statusSnoozed)
+				continue
+			}
 			// Project
 			proj := &models.Project{}
 			if err := al.db.Admin.DB().Model(rule).Related(proj).Error; err != nil {
@@ -163,8 +322,14 @@ func (al *Alerter) work() {
 				continue
 			}
 			ev.Project = proj
+			// Maintenance
+			if al.inMaintenance(proj, rule) {
+				al.record(ev, nil, statusInMaintenance)
+				continue
+			}
 			// Silent
 			if al.shouldSilent(proj) {
+				al.record(ev, nil, statusSilenced)
 				continue
 			}
 			// Users
@@ -174,27 +339,32 @@ func (al *Alerter) work() {
 				continue
 			}
 			users = append(users, univs...)
-			// Send
+			// Filter by rule level.
+			var receivers []models.User
 			for _, user := range users {
-				ev.User = &user
-				if rule.Level < user.RuleLevel {
-					continue
-				}
-				// Exec
-				if len(al.cfg.Alerter.Command) == 0 {
-					log.Warnf("alert command not configured")
-					continue
+				if rule.Level >= user.RuleLevel {
+					receivers = append(receivers, user)
 				}
-				if err := al.execCommand(ev); err != nil {
-					log.Errorf("exec %s: %v", al.cfg.Alerter.Command, err)
-					continue
-				}
-				log.Infof("send message to %s with %s ok", user.Name, ev.Metric.Name)
 			}
-			if len(users) != 0 {
-				al.m.Set(ev.Metric.Name, ev.Metric.Stamp)
-				health.IncrNumAlertingEvents(1)
+			// Send, or group into a digest, which is recorded once sent.
+			if al.config().Alerter.GroupWindow > 0 {
+				al.group(ev, receivers)
+				continue
 			}
+			al.sent(ev, receivers, al.notify(ev, receivers))
+		}
+	}
+}
+
+// sent records the event for its rule sent to receivers with n messages, the
+// alerting stamp and the incident of its metric are updated if alerted.
+func (al *Alerter) sent(ev *models.Event, receivers []models.User, n int) {
+	if !ev.Resolved && (len(receivers) != 0 || n != 0) {
+		al.m.Set(ev.Metric.Name, ev.Metric.Stamp)
+		health.IncrNumAlertingEvents(1)
+		if al.config().Alerter.ResolveIntervals > 0 {
+			al.incs.Set(incidentKey(ev.Metric.Name, ev.Rule), ev.Metric.Stamp)
 		}
 	}
+	al.record(ev, receivers, statusSent)
 }
@@ -3,8 +3,14 @@
 package alerter
 
 import (
-	"github.com/eleme/banshee/util"
+	"os"
 	"testing"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/util"
 )
 
 func TestHourInRange(t *testing.T) {
@@ -15,3 +21,72 @@ func TestHourInRange(t *testing.T) {
 	util.Must(t, hourInRange(6, 19, 10))
 	util.Must(t, !hourInRange(13, 19, 10))
 }
+
+func TestIsSnoozed(t *testing.T) {
+	fileName := "alerter_test"
+	db, err := storage.Open(fileName, nil)
+	util.Must(t, err == nil)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	al := New(config.New(), db)
+	now := uint32(time.Now().Unix())
+	rule := &models.Rule{ID: 1}
+	ev := models.NewEvent(&models.Metric{Name: "foo", Stamp: now}, nil)
+	util.Must(t, !al.isSnoozed(ev, rule))
+	// Snooze on all rules.
+	snooze := &models.Snooze{Metric: "foo", Until: now + 60}
+	util.Must(t, db.Admin.DB().Create(snooze).Error == nil)
+	util.Must(t, al.isSnoozed(ev, rule))
+	util.Must(t, db.Admin.DB().Delete(snooze).Error == nil)
+	// Expired.
+	util.Must(t, db.Admin.DB().Create(&models.Snooze{Metric: "foo", RuleID: 1, Until: now - 1}).Error == nil)
+	util.Must(t, !al.isSnoozed(ev, rule))
+	// Acknowledgement ends on resolved.
+	util.Must(t, db.Admin.DB().Create(&models.Snooze{Metric: "foo", RuleID: 1, Until: now + 60, Ack: true}).Error == nil)
+	util.Must(t, al.isSnoozed(ev, rule))
+	util.Must(t, !al.isSnoozed(models.NewResolvedEvent(ev.Metric, nil, nil), rule))
+	util.Must(t, !al.isSnoozed(ev, rule))
+	// Expire snoozes.
+	al.expireSnoozes()
+	var n int
+	db.Admin.DB().Model(&models.Snooze{}).Count(&n)
+	util.Must(t, n == 0)
+}
+
+func TestInMaintenance(t *testing.T) {
+	fileName := "alerter_test"
+	db, err := storage.Open(fileName, nil)
+	util.Must(t, err == nil)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	al := New(config.New(), db)
+	now := uint32(time.Now().Unix())
+	proj := &models.Project{ID: 1}
+	rule := &models.Rule{ID: 2, ProjectID: 1}
+	util.Must(t, !al.inMaintenance(proj, rule))
+	// Passed window.
+	util.Must(t, db.Admin.DB().Create(&models.Maintenance{ProjectID: 1, Start: now - 60, End: now - 1}).Error == nil)
+	util.Must(t, !al.inMaintenance(proj, rule))
+	// Window of another rule.
+	util.Must(t, db.Admin.DB().Create(&models.Maintenance{RuleID: 3, Start: now - 60, End: now + 60}).Error == nil)
+	util.Must(t, !al.inMaintenance(proj, rule))
+	// Window of the rule.
+	util.Must(t, db.Admin.DB().Create(&models.Maintenance{RuleID: 2, Start: now - 60, End: now + 60}).Error == nil)
+	util.Must(t, al.inMaintenance(proj, rule))
+}
+
+func TestRenewIncident(t *testing.T) {
+	cfg := config.New()
+	al := New(cfg, nil)
+	now := uint32(time.Now().Unix())
+	al.incs.Set("foo:1", now-cfg.Period-1)
+	al.incs.Set("bar:1", now-cfg.Period-1)
+	// Events of open incidents keep them open.
+	al.renewIncident("foo:1", now)
+	// Not alerted.
+	al.renewIncident("baz:1", now)
+	al.expireIncidents()
+	util.Must(t, al.incs.Has("foo:1"))
+	util.Must(t, !al.incs.Has("bar:1"))
+	util.Must(t, !al.incs.Has("baz:1"))
+}
@@ -0,0 +1,133 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package alerter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/models"
+)
+
+// eventGroup is a group of events waiting to be sent in a digest.
+type eventGroup struct {
+	events []*models.Event
+	// Receivers of each event.
+	receivers [][]models.User
+	// Receivers of all events.
+	users []models.User
+	// User ids in users.
+	ids map[int]bool
+}
+
+// groups holds event groups by group key.
+type groups struct {
+	lock sync.Mutex
+	m    map[string]*eventGroup
+	// Number of alerting events waiting by metric name.
+	names map[string]int
+}
+
+// newGroups creates groups.
+func newGroups() *groups {
+	return &groups{m: make(map[string]*eventGroup), names: make(map[string]int)}
+}
+
+// groupKey returns the group key of an event by project or rule, resolved
+// events are grouped separately.
+func groupKey(by string, ev *models.Event) string {
+	var key string
+	switch by {
+	case config.AlerterGroupByProject:
+		key = fmt.Sprintf("project:%d", ev.Project.ID)
+	default:
+		key = fmt.Sprintf("rule:%d", ev.Rule.ID)
+	}
+	if ev.Resolved {
+		key += ":resolved"
+	}
+	return key
+}
+
+// add an event with its receivers to the group by key, returns true if it
+// is the first event of the group.
+func (gs *groups) add(key string, ev *models.Event, users []models.User) bool {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	g, ok := gs.m[key]
+	if !ok {
+		g = &eventGroup{ids: make(map[int]bool)}
+		gs.m[key] = g
+	}
+	g.events = append(g.events, ev)
+	g.receivers = append(g.receivers, users)
+	if !ev.Resolved {
+		gs.names[ev.Metric.Name]++
+	}
+	for _, user := range users {
+		if !g.ids[user.ID] {
+			g.ids[user.ID] = true
+			g.users = append(g.users, user)
+		}
+	}
+	return !ok
+}
+
+// pop the group by key.
+func (gs *groups) pop(key string) *eventGroup {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	g, ok := gs.m[key]
+	if !ok {
+		return nil
+	}
+	delete(gs.m, key)
+	for _, ev := range g.events {
+		if ev.Resolved {
+			continue
+		}
+		if gs.names[ev.Metric.Name]--; gs.names[ev.Metric.Name] <= 0 {
+			delete(gs.names, ev.Metric.Name)
+		}
+	}
+	return g
+}
+
+// pending returns true if any alerting event of the metric is waiting.
+func (gs *groups) pending(name string) bool {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	return gs.names[name] > 0
+}
+
+// group adds a copy of the event into its group, the group will be sent in
+// a digest after the group window since its first event.
+func (al *Alerter) group(ev *models.Event, receivers []models.User) {
+	e := &models.Event{}
+	*e = *ev
+	key := groupKey(al.config().Alerter.GroupBy, e)
+	if al.groups.add(key, e, receivers) {
+		window := time.Duration(al.config().Alerter.GroupWindow) * time.Second
+		time.AfterFunc(window, func() { al.flush(key) })
+	}
+}
+
+// flush sends the group by key, a group of a single event is sent as it is.
+// Events in the group are recorded with the messages actually sent.
+func (al *Alerter) flush(key string) {
+	g := al.groups.pop(key)
+	if g == nil {
+		return
+	}
+	var n int
+	if len(g.events) == 1 {
+		n = al.notify(g.events[0], g.users)
+	} else {
+		n = al.notify(models.NewDigestEvent(g.events), g.users)
+	}
+	for i, ev := range g.events {
+		al.sent(ev, g.receivers[i], n)
+	}
+}
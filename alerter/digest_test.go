@@ -0,0 +1,91 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package alerter
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/storage/eventdb"
+	"github.com/eleme/banshee/util"
+)
+
+// testNotifier records events notified.
+type testNotifier struct {
+	ch chan *models.Event
+}
+
+func (n *testNotifier) Name() string { return "test" }
+
+func (n *testNotifier) Notify(ev *models.Event, users []models.User) (int, error) {
+	n.ch <- ev
+	return len(users), nil
+}
+
+func TestGroupKey(t *testing.T) {
+	ev := newTestEvent()
+	ev.Project.ID = 1
+	ev.Rule.ID = 2
+	util.Must(t, groupKey(config.AlerterGroupByProject, ev) == "project:1")
+	util.Must(t, groupKey(config.AlerterGroupByRule, ev) == "rule:2")
+	ev.Resolved = true
+	util.Must(t, groupKey(config.AlerterGroupByRule, ev) == "rule:2:resolved")
+}
+
+func TestGroupsAdd(t *testing.T) {
+	gs := newGroups()
+	util.Must(t, gs.add("k", newTestEvent(), []models.User{models.User{ID: 1}}))
+	util.Must(t, !gs.add("k", newTestEvent(), []models.User{models.User{ID: 1}, models.User{ID: 2}}))
+	g := gs.pop("k")
+	util.Must(t, len(g.events) == 2)
+	util.Must(t, len(g.users) == 2)
+	util.Must(t, gs.pop("k") == nil)
+}
+
+func TestGroupDigest(t *testing.T) {
+	fileName := "alerter_test"
+	db, err := storage.Open(fileName, nil)
+	util.Must(t, err == nil)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	cfg := config.New()
+	cfg.Alerter.GroupWindow = 1
+	al := New(cfg, db)
+	n := &testNotifier{ch: make(chan *models.Event, 2)}
+	al.AddNotifier(n)
+	ev1 := newTestEvent()
+	ev2 := newTestEvent()
+	ev2.Metric = &models.Metric{Name: "timer.mean_90.bar", Stamp: ev1.Metric.Stamp, Value: 400}
+	users := []models.User{models.User{ID: 1, Name: "hit9"}}
+	al.group(ev1, users)
+	al.group(ev2, users)
+	// Waiting events are rate limited, not yet recorded.
+	util.Must(t, al.groups.pending(ev1.Metric.Name))
+	util.Must(t, !al.m.Has(ev1.Metric.Name))
+	select {
+	case ev := <-n.ch:
+		util.Must(t, len(ev.Digest) == 2)
+		util.Must(t, ev.Metric.Name == "timer.mean_90.foo")
+		s := formatEvent(ev)
+		util.Must(t, strings.HasPrefix(s, "[banshee] foo 2 events\n"))
+		util.Must(t, strings.Contains(s, "timer.mean_90.bar ↑ value:400"))
+	case <-time.After(3 * time.Second):
+		t.Fatal("digest not sent")
+	}
+	// Recorded once sent.
+	var records []*models.EventRecord
+	for i := 0; i < 100 && len(records) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		records, err = db.Event.Query(&eventdb.QueryOptions{})
+		util.Must(t, err == nil)
+	}
+	util.Must(t, len(records) == 2)
+	util.Must(t, records[0].Receivers == "hit9")
+	util.Must(t, !al.groups.pending(ev1.Metric.Name))
+	util.Must(t, al.m.Has(ev1.Metric.Name))
+}
@@ -64,22 +64,89 @@ And the alerter command should do the sending message job, for example:
 		// Implement sendPhone..
 	endif
 
-Alert To Slack Or HipChat
+Notifiers
 
-We can also send alerting messages to some chat services like slack or
-hipchat, take the slack as an example:
+Besides the alerter command, there are built-in notifiers configured in
+config.alerter, all notifiers are called for an alerting event:
 
-1. Add an universal user named slack-bot on the web panel.
+	command          Execute the alerter command once for each user.
+	webhooks         Post the event with its users in JSON once for each url.
+	slack_webhooks   Post a message once for each slack incoming webhook url.
+	email            Send an email via smtp to users with email enabled.
 
-2. Add logic codes like followings to the command:
+The JSON posted to webhooks is the same as the alerter command argument,
+but with the users to receive and a text message:
 
-	if data['user']['name'] == 'slack-bot' then
-		// Post to slack with alerting message if the user is slack-bot
-		requestData = {'username': 'banshee-bot', text: packMessage(data)}
-		http.POST("https://hooks.slack.com/services/<hook-for-the-channel>", data=requestData)
-	endif
+	{
+		"project": {"name": "note"},
+		"metric": {...},
+		"rule": {...},
+		"users": [{"name": "jack", ...}, ...],
+		"message": "[banshee] note timer.mean_90.note.get ↑ value:2000 average:40"
+	}
+
+All notifiers share the timeout config.alerter.notify_timeout, and more
+notifiers can be plugged in by implementing the Notifier interface and
+calling Alerter.AddNotifier.
+
+Alert To Slack Or HipChat
+
+Configure the slack incoming webhook urls in config.alerter.slack_webhooks:
+
+	slack_webhooks: ["https://hooks.slack.com/services/<hook-for-the-channel>"]
 
 Slack incoming webhooks: https://api.slack.com/incoming-webhooks
 
+Resolved Events
+
+Once an alerted metric is back in bounds of the rule for continuous
+config.alerter.resolve_intervals detections, the incident is resolved and
+a resolved event (with "resolved": true) is sent to the users via the same
+notifiers, resolved events are not rate limited.
+
+Digest Notifications
+
+If config.alerter.group_window is set, events are grouped by project or rule
+(config.alerter.group_by) instead of being sent one by one, a group is sent
+in a single digest event once the window since its first event ends. The
+digest event has the project, rule and metric of the first event, and all
+events grouped in the field "digest":
+
+	{
+		"project": {"name": "note"},
+		"metric": {...},
+		"rule": {...},
+		"digest": [{"metric": {...}, "rule": {...}, ...}, ...]
+	}
+
+And the message of a digest lists all metrics:
+
+	[banshee] note 2 events
+	  timer.mean_90.note.get ↑ value:2000 average:40
+	  timer.mean_90.note.put ↑ value:1800 average:35
+
+Events are recorded into the history once the digest is sent, and further
+events of a metric waiting in a digest are rate limited.
+
+Snoozes And Acknowledgements
+
+Notifications of a metric on a rule can be stopped for a duration by a
+snooze, or until the alert is resolved by an acknowledgement, both stored in
+admindb, see POST /api/snooze and POST /api/ack in package webapp.
+
+Maintenance Windows
+
+Alerts of a project or a rule are suppressed during its maintenance windows,
+either absolute time ranges or weekly recurring ones in a timezone, see POST
+/api/maintenance in package webapp. The suppressed alerts are still recorded
+into the events history.
+
+Events History
+
+Every alerting event is recorded into the eventdb for each of its rules,
+with the users notified, including the silenced and the rate limited (by
+config.alerter.interval and config.alerter.one_day_limit) ones. The history
+expires with config.expiration, see GET /api/events in package webapp.
+
 */
 package alerter
@@ -0,0 +1,151 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package alerter
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/log"
+)
+
+// emailOptions is the smtp options, the same as config.Alerter.Email.
+type emailOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// emailNotifier sends emails via smtp to users with email enabled.
+type emailNotifier struct {
+	opts    emailOptions
+	timeout time.Duration
+}
+
+// newEmailNotifier creates an emailNotifier.
+func newEmailNotifier(opts emailOptions, timeout time.Duration) *emailNotifier {
+	return &emailNotifier{opts, timeout}
+}
+
+// Name implements Notifier.
+func (n *emailNotifier) Name() string { return "email" }
+
+// Notify implements Notifier.
+func (n *emailNotifier) Notify(ev *models.Event, users []models.User) (int, error) {
+	var to []string
+	for _, user := range users {
+		if user.EnableEmail && len(user.Email) > 0 {
+			to = append(to, user.Email)
+		}
+	}
+	if len(to) == 0 {
+		return 0, nil
+	}
+	if err := n.send(to, buildEmail(n.opts.From, to, ev)); err != nil {
+		return 0, err
+	}
+	log.Infof("send email to %v with %s ok", to, ev.Metric.Name)
+	return len(to), nil
+}
+
+// send sends the message to the recipients within the timeout.
+func (n *emailNotifier) send(to []string, msg []byte) error {
+	addr := net.JoinHostPort(n.opts.Host, strconv.Itoa(n.opts.Port))
+	conn, err := net.DialTimeout("tcp", addr, n.timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(n.timeout))
+	c, err := smtp.NewClient(conn, n.opts.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.opts.Host}); err != nil {
+			return err
+		}
+	}
+	if len(n.opts.Username) > 0 {
+		auth := smtp.PlainAuth("", n.opts.Username, n.opts.Password, n.opts.Host)
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.opts.From); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildEmail builds the email message for an event, events in a digest are
+// listed one by one.
+func buildEmail(from string, to []string, ev *models.Event) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	for _, addr := range to {
+		fmt.Fprintf(&b, "To: %s\r\n", addr)
+	}
+	subject := formatEvent(ev)
+	if len(ev.Digest) > 0 {
+		subject = formatDigestSummary(ev)
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=UTF-8\r\n")
+	if len(ev.Digest) == 0 {
+		fmt.Fprintf(&b, "\r\n")
+		writeEmailEvent(&b, ev)
+	}
+	for _, e := range ev.Digest {
+		fmt.Fprintf(&b, "\r\n")
+		writeEmailEvent(&b, e)
+	}
+	return b.Bytes()
+}
+
+// writeEmailEvent writes the email body lines for an event.
+func writeEmailEvent(b *bytes.Buffer, ev *models.Event) {
+	fmt.Fprintf(b, "Project: %s\r\n", ev.Project.Name)
+	fmt.Fprintf(b, "Metric: %s\r\n", ev.Metric.BaseName())
+	if len(ev.Tags) > 0 {
+		fmt.Fprintf(b, "Tags: %s\r\n", strings.Join(formatTags(ev.Tags), " "))
+	}
+	fmt.Fprintf(b, "Time: %s\r\n", time.Unix(int64(ev.Metric.Stamp), 0).Format(time.RFC3339))
+	fmt.Fprintf(b, "Value: %v\r\n", ev.Metric.Value)
+	fmt.Fprintf(b, "Average: %v\r\n", ev.Metric.Average)
+	if ev.Index != nil {
+		fmt.Fprintf(b, "Score: %v\r\n", ev.Index.Score)
+	}
+	if ev.Rule != nil {
+		fmt.Fprintf(b, "Rule: %s\r\n", ev.Rule.Pattern)
+	}
+	if len(ev.RuleTranslatedComment) > 0 {
+		fmt.Fprintf(b, "Comment: %s\r\n", ev.RuleTranslatedComment)
+	}
+}
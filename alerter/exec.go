@@ -0,0 +1,75 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package alerter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/log"
+)
+
+// execNotifier executes the alerter command for each user with the event as
+// JSON argument.
+type execNotifier struct {
+	command string
+	timeout time.Duration
+}
+
+// newExecNotifier creates an execNotifier.
+func newExecNotifier(command string, timeout time.Duration) *execNotifier {
+	return &execNotifier{command, timeout}
+}
+
+// Name implements Notifier.
+func (n *execNotifier) Name() string { return "command" }
+
+// Notify implements Notifier.
+func (n *execNotifier) Notify(ev *models.Event, users []models.User) (int, error) {
+	defer func() { ev.User = nil }()
+	var err error
+	sent := 0
+	for i := 0; i < len(users); i++ {
+		ev.User = &users[i]
+		if e := n.exec(ev); e != nil {
+			log.Errorf("exec %s: %v", n.command, e)
+			err = e
+			continue
+		}
+		sent++
+		log.Infof("send message to %s with %s ok", users[i].Name, ev.Metric.Name)
+	}
+	return sent, err
+}
+
+// exec executes command with event within certain timeout.
+func (n *execNotifier) exec(ev *models.Event) error {
+	b, _ := json.Marshal(ev)
+	arg := string(b)
+	done := make(chan error)
+	cmd := exec.Command(n.command, arg)
+	go func() {
+		done <- cmd.Run()
+	}()
+	timeout := time.After(n.timeout)
+	select {
+	case <-timeout:
+		err := cmd.Process.Kill()
+		if err == nil {
+			err = errors.New("command timed out, killed")
+		} else {
+			s := fmt.Sprintf("failed to kill command: %v", err)
+			err = errors.New(s)
+		}
+		go func() {
+			<-done // exit the prev goroutine
+		}()
+		return err
+	case err := <-done:
+		return err
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package alerter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util"
+)
+
+// Notifier sends alerting messages for an event.
+type Notifier interface {
+	// Name returns the notifier name for logging.
+	Name() string
+	// Notify sends messages for the event to the users, returns the number
+	// of messages sent. Notifiers sending to users are called once for all
+	// users, and notifiers sending to channels (i.e. webhooks) ignore users.
+	Notify(ev *models.Event, users []models.User) (int, error)
+}
+
+// newNotifiers creates notifiers from config.
+func newNotifiers(cfg *config.Config) []Notifier {
+	var notifiers []Notifier
+	timeout := time.Duration(cfg.Alerter.NotifyTimeout) * time.Second
+	if len(cfg.Alerter.Command) > 0 {
+		notifiers = append(notifiers, newExecNotifier(cfg.Alerter.Command, timeout))
+	}
+	for _, url := range cfg.Alerter.Webhooks {
+		notifiers = append(notifiers, newWebhookNotifier(url, timeout))
+	}
+	for _, url := range cfg.Alerter.SlackWebhooks {
+		notifiers = append(notifiers, newSlackNotifier(url, timeout))
+	}
+	if len(cfg.Alerter.Email.Host) > 0 {
+		opts := emailOptions{
+			Host:     cfg.Alerter.Email.Host,
+			Port:     cfg.Alerter.Email.Port,
+			Username: cfg.Alerter.Email.Username,
+			Password: cfg.Alerter.Email.Password,
+			From:     cfg.Alerter.Email.From,
+		}
+		notifiers = append(notifiers, newEmailNotifier(opts, timeout))
+	}
+	return notifiers
+}
+
+// formatEvent returns the text message for an event, a digest event is
+// formatted in multiple lines with the summary as the first line.
+func formatEvent(ev *models.Event) string {
+	if len(ev.Digest) == 0 {
+		return fmt.Sprintf("[banshee] %s %s", ev.Project.Name, formatMetric(ev))
+	}
+	lines := []string{formatDigestSummary(ev)}
+	for _, e := range ev.Digest {
+		lines = append(lines, "  "+formatMetric(e))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatDigestSummary returns the summary line of a digest event.
+func formatDigestSummary(ev *models.Event) string {
+	s := fmt.Sprintf("[banshee] %s %d events", ev.Project.Name, len(ev.Digest))
+	if ev.Resolved {
+		s += " resolved"
+	}
+	return s
+}
+
+// formatMetric returns the text message of the metric of an event.
+func formatMetric(ev *models.Event) string {
+	var trend string
+	switch {
+	case ev.Resolved:
+		trend = "resolved"
+	case ev.Index != nil && ev.Index.Score > 0:
+		trend = "↑"
+	case ev.Index != nil && ev.Index.Score < 0:
+		trend = "↓"
+	}
+	s := fmt.Sprintf("%s %s value:%s average:%s", ev.Metric.Name, trend,
+		util.ToFixed(ev.Metric.Value, 3), util.ToFixed(ev.Metric.Average, 3))
+	if len(ev.RuleTranslatedComment) > 0 {
+		s = fmt.Sprintf("%s (%s)", s, ev.RuleTranslatedComment)
+	}
+	return s
+}
+
+// formatTags returns the tags of an event as "key=value" sorted by key.
+func formatTags(tags map[string]string) []string {
+	l := make([]string, 0, len(tags))
+	for k, v := range tags {
+		l = append(l, k+"="+v)
+	}
+	sort.Strings(l)
+	return l
+}
@@ -0,0 +1,106 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package alerter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util"
+)
+
+func newTestEvent() *models.Event {
+	m := &models.Metric{Name: "timer.mean_90.foo", Stamp: 1452674178, Value: 300, Average: 20}
+	ev := models.NewEvent(m, &models.Index{Name: m.Name, Score: 1.2})
+	ev.Project = &models.Project{Name: "foo"}
+	ev.Rule = &models.Rule{Pattern: "timer.mean_90.*", Comment: "$1 latency"}
+	ev.TranslateRuleComment()
+	return ev
+}
+
+func TestNewNotifiers(t *testing.T) {
+	cfg := config.New()
+	util.Must(t, len(newNotifiers(cfg)) == 0)
+	cfg.Alerter.Command = "echo"
+	cfg.Alerter.Webhooks = []string{"http://a", "http://b"}
+	cfg.Alerter.SlackWebhooks = []string{"http://c"}
+	cfg.Alerter.Email.Host = "localhost"
+	util.Must(t, len(newNotifiers(cfg)) == 5)
+}
+
+func TestFormatEvent(t *testing.T) {
+	s := formatEvent(newTestEvent())
+	util.Must(t, s == "[banshee] foo timer.mean_90.foo ↑ value:300 average:20 (foo latency)")
+	ev := newTestEvent()
+	ev.Resolved = true
+	s = formatEvent(ev)
+	util.Must(t, s == "[banshee] foo timer.mean_90.foo resolved value:300 average:20 (foo latency)")
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer ts.Close()
+	n := newWebhookNotifier(ts.URL, time.Second)
+	sent, err := n.Notify(newTestEvent(), []models.User{models.User{Name: "jack"}})
+	util.Must(t, err == nil && sent == 1)
+	util.Must(t, payload["metric"].(map[string]interface{})["name"] == "timer.mean_90.foo")
+	util.Must(t, len(payload["users"].([]interface{})) == 1)
+	util.Must(t, strings.HasPrefix(payload["message"].(string), "[banshee]"))
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var payload slackPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer ts.Close()
+	n := newSlackNotifier(ts.URL, time.Second)
+	sent, err := n.Notify(newTestEvent(), nil)
+	util.Must(t, err == nil && sent == 1)
+	util.Must(t, payload.Text == formatEvent(newTestEvent()))
+}
+
+func TestWebhookNotifierBadStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	n := newWebhookNotifier(ts.URL, time.Second)
+	sent, err := n.Notify(newTestEvent(), nil)
+	util.Must(t, err != nil && sent == 0)
+}
+
+func TestEmailNotifierNoReceivers(t *testing.T) {
+	n := newEmailNotifier(emailOptions{Host: "localhost", Port: 25}, time.Second)
+	users := []models.User{models.User{Email: "jack@gmail.com", EnableEmail: false}}
+	sent, err := n.Notify(newTestEvent(), users)
+	util.Must(t, err == nil && sent == 0)
+}
+
+func TestBuildEmail(t *testing.T) {
+	s := string(buildEmail("banshee@ele.me", []string{"jack@gmail.com"}, newTestEvent()))
+	util.Must(t, strings.Contains(s, "From: banshee@ele.me\r\n"))
+	util.Must(t, strings.Contains(s, "To: jack@gmail.com\r\n"))
+	util.Must(t, strings.Contains(s, "Subject: =?utf-8?q?"))
+	util.Must(t, strings.Contains(s, "Metric: timer.mean_90.foo\r\n"))
+	util.Must(t, strings.Contains(s, "Comment: foo latency\r\n"))
+}
+
+func TestBuildEmailDigest(t *testing.T) {
+	ev2 := newTestEvent()
+	ev2.Metric = &models.Metric{Name: "timer.mean_90.bar"}
+	ev := models.NewDigestEvent([]*models.Event{newTestEvent(), ev2})
+	s := string(buildEmail("banshee@ele.me", []string{"jack@gmail.com"}, ev))
+	util.Must(t, strings.Contains(s, "Subject: [banshee] foo 2 events\r\n"))
+	util.Must(t, strings.Contains(s, "Metric: timer.mean_90.foo\r\n"))
+	util.Must(t, strings.Contains(s, "Metric: timer.mean_90.bar\r\n"))
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package alerter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eleme/banshee/models"
+)
+
+// postJSON posts value as JSON to the url.
+func postJSON(client *http.Client, url string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// webhookPayload is the JSON posted to generic webhooks.
+type webhookPayload struct {
+	*models.Event
+	Users   []models.User `json:"users"`
+	Message string        `json:"message"`
+}
+
+// webhookNotifier posts the event and its users to an url in JSON.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// newWebhookNotifier creates a webhookNotifier.
+func newWebhookNotifier(url string, timeout time.Duration) *webhookNotifier {
+	return &webhookNotifier{url, &http.Client{Timeout: timeout}}
+}
+
+// Name implements Notifier.
+func (n *webhookNotifier) Name() string { return "webhook" }
+
+// Notify implements Notifier.
+func (n *webhookNotifier) Notify(ev *models.Event, users []models.User) (int, error) {
+	if users == nil {
+		users = make([]models.User, 0)
+	}
+	payload := &webhookPayload{Event: ev, Users: users, Message: formatEvent(ev)}
+	if err := postJSON(n.client, n.url, payload); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// slackPayload is the JSON posted to slack incoming webhooks.
+type slackPayload struct {
+	Username string `json:"username"`
+	Text     string `json:"text"`
+}
+
+// slackNotifier posts a message to a slack compatible incoming webhook.
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// newSlackNotifier creates a slackNotifier.
+func newSlackNotifier(url string, timeout time.Duration) *slackNotifier {
+	return &slackNotifier{url, &http.Client{Timeout: timeout}}
+}
+
+// Name implements Notifier.
+func (n *slackNotifier) Name() string { return "slack" }
+
+// Notify implements Notifier.
+func (n *slackNotifier) Notify(ev *models.Event, users []models.User) (int, error) {
+	payload := &slackPayload{Username: "banshee", Text: formatEvent(ev)}
+	if err := postJSON(n.client, n.url, payload); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eleme/banshee/detector"
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/util/log"
+)
+
+// runCommand runs a subcommand and exits.
+func runCommand(args []string) {
+	if len(args) != 2 {
+		usage()
+	}
+	var err error
+	switch args[0] {
+	case "backup":
+		err = backup(args[1])
+	case "restore":
+		err = restore(args[1])
+	case "import":
+		err = importMetrics(args[1])
+	case "replay":
+		err = replay(args[1])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", args[0], err)
+	}
+	log.Infof("%s: %s done", args[0], args[1])
+	os.Exit(0)
+}
+
+// post requests the api of the running banshee by config, the response
+// body should be closed by the caller.
+func post(path string, body io.Reader) (*http.Response, error) {
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", cfg.Webapp.Port, path)
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	if user := cfg.Webapp.Auth[0]; len(user) > 0 {
+		req.SetBasicAuth(user, cfg.Webapp.Auth[1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return resp, nil
+}
+
+// backup requests a backup from the running banshee by config and saves it
+// to fileName.
+func backup(fileName string) error {
+	resp, err := post("/api/admin/backup", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, resp.Body); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		// Remove the partial backup.
+		os.Remove(fileName)
+	}
+	return err
+}
+
+// restore unpacks a backup into the storage path by config, banshee should
+// be stopped and the storage path should not exist.
+func restore(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return storage.Unpack(f, cfg.Storage.Path)
+}
+
+// importMetrics imports history metrics in fileName into the running banshee
+// by config, files with extension ".csv" are in csv, others are in the
+// detector's tcp protocol.
+func importMetrics(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	format := detector.ImportFormatLine
+	if strings.ToLower(path.Ext(fileName)) == ".csv" {
+		format = detector.ImportFormatCSV
+	}
+	resp, err := post("/api/metrics/import?format="+format, f)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Imported int `json:"imported"`
+		Failed   int `json:"failed"`
+		Errors   []struct {
+			Line int    `json:"line"`
+			Msg  string `json:"msg"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	for _, e := range result.Errors {
+		log.Warnf("line %d: %s", e.Line, e.Msg)
+	}
+	log.Infof("%d metrics imported, %d failed", result.Imported, result.Failed)
+	return nil
+}
+
+// replay replays a rule against the stored history of the running banshee
+// by config, and prints the number of would-be events by day. The arg is
+// either a rule id, or a file of the replay api request in json.
+func replay(arg string) error {
+	var body io.Reader
+	if id, err := strconv.Atoi(arg); err == nil {
+		body = strings.NewReader(fmt.Sprintf(`{"ruleID": %d, "limit": 0}`, id))
+	} else {
+		f, err := os.Open(arg)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		body = f
+	}
+	resp, err := post("/api/replay", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result detector.ReplayResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	for _, day := range result.Days {
+		fmt.Printf("%s\t%d\n", day.Day, day.NumEvents)
+	}
+	for _, ev := range result.Events {
+		fmt.Printf("%s\t%s\t%v\n", time.Unix(int64(ev.Metric.Stamp), 0).Format(time.RFC3339),
+			ev.Metric.Name, ev.Metric.Value)
+	}
+	log.Infof("%d events of %d metrics in %d points", result.NumEvents, result.NumMetrics, result.NumPoints)
+	return nil
+}
@@ -5,8 +5,12 @@ package config
 
 import (
 	"github.com/eleme/banshee/util/log"
+	"github.com/eleme/banshee/util/wildcard"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
 )
 
 // Measures
@@ -34,12 +38,28 @@ const (
 	DefaultFilterOffset float64 = 0.01
 	// Default filter times to query history metrics.
 	DefaultFilterTimes int = 4
+	// Default interval to sync changes from the shared admin storage.
+	DefaultStorageAdminSyncInterval uint32 = 10 * Second
+	// Default number of detector workers.
+	DefaultDetectorWorkers int = 4
+	// Default size of the detector ingestion queue.
+	DefaultDetectorQueueSize int = 10 * 1024
+	// Default detection algorithm.
+	DefaultDetectorAlgorithm string = "3sigma"
 	// Default value of alerting interval.
 	DefaultAlerterInterval uint32 = 20 * Minute
 	// Default value of alert times limit in one day for the same metric
 	DefaultAlerterOneDayLimit uint32 = 10
 	// Default value of least count.
 	DefaultLeastCount uint32 = 5 * Minute / DefaultInterval
+	// Default number of intervals in bounds to resolve an alerting incident.
+	DefaultAlerterResolveIntervals uint32 = 3
+	// Default key to group alerting events in digests.
+	DefaultAlerterGroupBy string = AlerterGroupByRule
+	// Default timeout in seconds for alerter notifiers.
+	DefaultAlerterNotifyTimeout uint32 = 5 * Second
+	// Default port for alerter email smtp server.
+	DefaultAlerterEmailPort int = 25
 	// Default alerting silent time range.
 	DefaultSilentTimeStart int = 0
 	DefaultSilentTimeEnd   int = 6
@@ -49,38 +69,82 @@ const (
 
 // Limitations
 const (
-	// Max value for the number of DefaultThresholdMaxs.
-	MaxNumDefaultThresholdMaxs = 8
-	// Max value for the number of DefaultThresholdMins.
-	MaxNumDefaultThresholdMins = 8
-	// Max value for the number of FillBlankZeros.
-	MaxFillBlankZerosLen = 8
+	// Max value for the number of detector Algorithms.
+	MaxNumDetectorAlgorithms = 8
+	// Max value for the number of storage rollups.
+	MaxNumStorageRollups = 4
 	// Min value for the expiration to period.
 	MinExpirationNumToPeriod uint32 = 5
 	// Min value for the period.
 	MinPeriod uint32 = 1 * Hour // 1h
 )
 
+// SQL dialects of the admin storage.
+const (
+	StorageAdminDialectSQLite3  = "sqlite3"
+	StorageAdminDialectMySQL    = "mysql"
+	StorageAdminDialectPostgres = "postgres"
+)
+
+// Replication roles.
+const (
+	ReplicationRolePrimary = "primary"
+	ReplicationRoleReplica = "replica"
+)
+
+// Keys to group alerting events by.
+const (
+	AlerterGroupByProject = "project"
+	AlerterGroupByRule    = "rule"
+)
+
 // WebappSupportedLanguages lists webapp supported languages.
 var WebappSupportedLanguages = []string{"en", "zh"}
 
+// Names of the algorithms registered to the detector, see
+// detector.RegisterAlgorithm.
+var (
+	detectorAlgorithms     = make(map[string]bool)
+	detectorAlgorithmsLock sync.RWMutex
+)
+
 // Config is the configuration container.
 type Config struct {
-	Interval   uint32         `json:"interval" yaml:"interval"`
-	Period     uint32         `json:"period" yaml:"period"`
-	Expiration uint32         `json:"expiration" yaml:"expiration"`
-	Storage    configStorage  `json:"storage" yaml:"storage"`
-	Detector   configDetector `json:"detector" yaml:"detector"`
-	Webapp     configWebapp   `json:"webapp" yaml:"webapp"`
-	Alerter    configAlerter  `json:"alerter" yaml:"alerter"`
+	Interval    uint32            `json:"interval" yaml:"interval"`
+	Period      uint32            `json:"period" yaml:"period"`
+	Expiration  uint32            `json:"expiration" yaml:"expiration"`
+	Storage     configStorage     `json:"storage" yaml:"storage"`
+	Detector    configDetector    `json:"detector" yaml:"detector"`
+	Webapp      configWebapp      `json:"webapp" yaml:"webapp"`
+	Alerter     configAlerter     `json:"alerter" yaml:"alerter"`
+	Cluster     configCluster     `json:"cluster" yaml:"cluster"`
+	Replication configReplication `json:"replication" yaml:"replication"`
 }
 
 type configStorage struct {
-	Path string `json:"path" yaml:"path"`
+	Path    string                `json:"path" yaml:"path"`
+	Rollups []configStorageRollup `json:"rollups" yaml:"rollups"`
+	Admin   configStorageAdmin    `json:"admin" yaml:"admin"`
+}
+
+type configStorageAdmin struct {
+	Dialect      string `json:"dialect" yaml:"dialect"`
+	DSN          string `json:"dsn" yaml:"dsn"`
+	SyncInterval uint32 `json:"syncInterval" yaml:"sync_interval"`
+}
+
+type configStorageRollup struct {
+	Interval   uint32 `json:"interval" yaml:"interval"`
+	Expiration uint32 `json:"expiration" yaml:"expiration"`
 }
 
 type configDetector struct {
 	Port                 int                `json:"port" yaml:"port"`
+	UDPPort              int                `json:"udpPort" yaml:"udp_port"`
+	GraphitePort         int                `json:"graphitePort" yaml:"graphite_port"`
+	InfluxDBPort         int                `json:"influxdbPort" yaml:"influxdb_port"`
+	Workers              int                `json:"workers" yaml:"workers"`
+	QueueSize            int                `json:"queueSize" yaml:"queue_size"`
 	TrendingFactor       float64            `json:"trendingFactor" yaml:"trending_factor"`
 	FilterOffset         float64            `json:"filterOffset" yaml:"filter_offset"`
 	FilterTimes          int                `json:"filterTimes" yaml:"filter_times"`
@@ -90,6 +154,8 @@ type configDetector struct {
 	DefaultThresholdMaxs map[string]float64 `json:"defaultThresholdMaxs" yaml:"default_threshold_maxs"`
 	DefaultThresholdMins map[string]float64 `json:"defaultThresholdMins" yaml:"default_threshold_mins"`
 	FillBlankZeros       []string           `json:"fillBlankZeros" yaml:"fill_blank_zeros"`
+	Algorithm            string             `json:"algorithm" yaml:"algorithm"`
+	Algorithms           map[string]string  `json:"algorithms" yaml:"algorithms"`
 }
 
 type configWebapp struct {
@@ -101,11 +167,44 @@ type configWebapp struct {
 }
 
 type configAlerter struct {
-	Command                string `json:"command" yaml:"command"`
-	Workers                int    `json:"workers" yaml:"workers"`
-	Interval               uint32 `json:"interval" yaml:"interval"`
-	OneDayLimit            uint32 `json:"oneDayLimit" yaml:"one_day_limit"`
-	DefaultSilentTimeRange []int  `json:"defaultSilentTimeRange" yaml:"default_silent_time_range"`
+	Command                string             `json:"command" yaml:"command"`
+	Workers                int                `json:"workers" yaml:"workers"`
+	Interval               uint32             `json:"interval" yaml:"interval"`
+	OneDayLimit            uint32             `json:"oneDayLimit" yaml:"one_day_limit"`
+	DefaultSilentTimeRange []int              `json:"defaultSilentTimeRange" yaml:"default_silent_time_range"`
+	ResolveIntervals       uint32             `json:"resolveIntervals" yaml:"resolve_intervals"`
+	GroupWindow            uint32             `json:"groupWindow" yaml:"group_window"`
+	GroupBy                string             `json:"groupBy" yaml:"group_by"`
+	NotifyTimeout          uint32             `json:"notifyTimeout" yaml:"notify_timeout"`
+	Webhooks               []string           `json:"webhooks" yaml:"webhooks"`
+	SlackWebhooks          []string           `json:"slackWebhooks" yaml:"slack_webhooks"`
+	Email                  configAlerterEmail `json:"email" yaml:"email"`
+}
+
+type configAlerterEmail struct {
+	Host     string `json:"host" yaml:"host"`
+	Port     int    `json:"port" yaml:"port"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	From     string `json:"from" yaml:"from"`
+}
+
+type configCluster struct {
+	Node       string              `json:"node" yaml:"node"`
+	Nodes      []configClusterNode `json:"nodes" yaml:"nodes"`
+	RouterPort int                 `json:"routerPort" yaml:"router_port"`
+}
+
+type configClusterNode struct {
+	Name     string `json:"name" yaml:"name"`
+	Detector string `json:"detector" yaml:"detector"`
+	Webapp   string `json:"webapp" yaml:"webapp"`
+}
+
+type configReplication struct {
+	Role    string `json:"role" yaml:"role"`
+	Port    int    `json:"port" yaml:"port"`
+	Primary string `json:"primary" yaml:"primary"`
 }
 
 // New creates a Config with default values.
@@ -115,7 +214,16 @@ func New() *Config {
 	c.Period = DefaultPeriod
 	c.Expiration = DefaultExpiration
 	c.Storage.Path = "./data"
+	c.Storage.Rollups = []configStorageRollup{}
+	c.Storage.Admin.Dialect = StorageAdminDialectSQLite3
+	c.Storage.Admin.DSN = ""
+	c.Storage.Admin.SyncInterval = DefaultStorageAdminSyncInterval
 	c.Detector.Port = 2015
+	c.Detector.UDPPort = 0
+	c.Detector.GraphitePort = 0
+	c.Detector.InfluxDBPort = 0
+	c.Detector.Workers = DefaultDetectorWorkers
+	c.Detector.QueueSize = DefaultDetectorQueueSize
 	c.Detector.TrendingFactor = DefaultTrendingFactor
 	c.Detector.FilterOffset = DefaultFilterOffset
 	c.Detector.FilterTimes = DefaultFilterTimes
@@ -125,6 +233,8 @@ func New() *Config {
 	c.Detector.DefaultThresholdMaxs = make(map[string]float64, 0)
 	c.Detector.DefaultThresholdMins = make(map[string]float64, 0)
 	c.Detector.FillBlankZeros = []string{}
+	c.Detector.Algorithm = DefaultDetectorAlgorithm
+	c.Detector.Algorithms = make(map[string]string, 0)
 	c.Webapp.Port = 2016
 	c.Webapp.Auth = []string{"admin", "admin"}
 	c.Webapp.Static = "static/dist"
@@ -135,6 +245,23 @@ func New() *Config {
 	c.Alerter.Interval = DefaultAlerterInterval
 	c.Alerter.OneDayLimit = DefaultAlerterOneDayLimit
 	c.Alerter.DefaultSilentTimeRange = []int{DefaultSilentTimeStart, DefaultSilentTimeEnd}
+	c.Alerter.ResolveIntervals = DefaultAlerterResolveIntervals
+	c.Alerter.GroupWindow = 0
+	c.Alerter.GroupBy = DefaultAlerterGroupBy
+	c.Alerter.NotifyTimeout = DefaultAlerterNotifyTimeout
+	c.Alerter.Webhooks = []string{}
+	c.Alerter.SlackWebhooks = []string{}
+	c.Alerter.Email.Host = ""
+	c.Alerter.Email.Port = DefaultAlerterEmailPort
+	c.Alerter.Email.Username = ""
+	c.Alerter.Email.Password = ""
+	c.Alerter.Email.From = ""
+	c.Cluster.Node = ""
+	c.Cluster.Nodes = []configClusterNode{}
+	c.Cluster.RouterPort = 0
+	c.Replication.Role = ReplicationRolePrimary
+	c.Replication.Port = 0
+	c.Replication.Primary = ""
 	return c
 }
 
@@ -152,25 +279,36 @@ func (c *Config) UpdateWithYamlFile(fileName string) error {
 	return err
 }
 
-// Copy config.
+// Copy config, slices and maps are copied as well.
 func (c *Config) Copy() *Config {
 	cfg := New()
 	cfg.Interval = c.Interval
 	cfg.Period = c.Period
 	cfg.Expiration = c.Expiration
 	cfg.Storage.Path = c.Storage.Path
+	cfg.Storage.Rollups = append([]configStorageRollup{}, c.Storage.Rollups...)
+	cfg.Storage.Admin = c.Storage.Admin
 	cfg.Detector.Port = c.Detector.Port
+	cfg.Detector.UDPPort = c.Detector.UDPPort
+	cfg.Detector.GraphitePort = c.Detector.GraphitePort
+	cfg.Detector.InfluxDBPort = c.Detector.InfluxDBPort
+	cfg.Detector.Workers = c.Detector.Workers
+	cfg.Detector.QueueSize = c.Detector.QueueSize
 	cfg.Detector.TrendingFactor = c.Detector.TrendingFactor
 	cfg.Detector.FilterOffset = c.Detector.FilterOffset
 	cfg.Detector.FilterTimes = c.Detector.FilterTimes
 	cfg.Detector.LeastCount = c.Detector.LeastCount
-	cfg.Detector.BlackList = c.Detector.BlackList
-	cfg.Detector.DefaultThresholdMaxs = c.Detector.DefaultThresholdMaxs
-	cfg.Detector.DefaultThresholdMins = c.Detector.DefaultThresholdMins
-	cfg.Detector.FillBlankZeros = c.Detector.FillBlankZeros
+	cfg.Detector.BlackList = copyStrings(c.Detector.BlackList)
+	cfg.Detector.DefaultThresholdMaxs = copyFloats(c.Detector.DefaultThresholdMaxs)
+	cfg.Detector.DefaultThresholdMins = copyFloats(c.Detector.DefaultThresholdMins)
+	cfg.Detector.FillBlankZeros = copyStrings(c.Detector.FillBlankZeros)
 	cfg.Detector.IntervalHitLimit = c.Detector.IntervalHitLimit
+	cfg.Detector.Algorithm = c.Detector.Algorithm
+	for k, v := range c.Detector.Algorithms {
+		cfg.Detector.Algorithms[k] = v
+	}
 	cfg.Webapp.Port = c.Webapp.Port
-	cfg.Webapp.Auth = c.Webapp.Auth
+	cfg.Webapp.Auth = copyStrings(c.Webapp.Auth)
 	cfg.Webapp.Static = c.Webapp.Static
 	cfg.Webapp.Language = c.Webapp.Language
 	cfg.Webapp.PrivateDocURL = c.Webapp.PrivateDocURL
@@ -178,15 +316,70 @@ func (c *Config) Copy() *Config {
 	cfg.Alerter.Workers = c.Alerter.Workers
 	cfg.Alerter.Interval = c.Alerter.Interval
 	cfg.Alerter.OneDayLimit = c.Alerter.OneDayLimit
-	cfg.Alerter.DefaultSilentTimeRange = c.Alerter.DefaultSilentTimeRange
+	cfg.Alerter.DefaultSilentTimeRange = append([]int{}, c.Alerter.DefaultSilentTimeRange...)
+	cfg.Alerter.ResolveIntervals = c.Alerter.ResolveIntervals
+	cfg.Alerter.GroupWindow = c.Alerter.GroupWindow
+	cfg.Alerter.GroupBy = c.Alerter.GroupBy
+	cfg.Alerter.NotifyTimeout = c.Alerter.NotifyTimeout
+	cfg.Alerter.Webhooks = copyStrings(c.Alerter.Webhooks)
+	cfg.Alerter.SlackWebhooks = copyStrings(c.Alerter.SlackWebhooks)
+	cfg.Alerter.Email = c.Alerter.Email
+	cfg.Cluster.Node = c.Cluster.Node
+	cfg.Cluster.Nodes = append(cfg.Cluster.Nodes, c.Cluster.Nodes...)
+	cfg.Cluster.RouterPort = c.Cluster.RouterPort
+	cfg.Replication = c.Replication
+	return cfg
+}
+
+// CopyReloadable returns a copy of the config with the fields safe to change
+// while running from the config n, the other fields are kept:
+//
+//	detector.blacklist
+//	detector.interval_hit_limit
+//	detector.default_threshold_maxs
+//	detector.default_threshold_mins
+//	detector.fill_blank_zeros
+//	alerter.interval
+//	alerter.one_day_limit
+//	alerter.default_silent_time_range
+//	webapp.language
+//
+func (c *Config) CopyReloadable(n *Config) *Config {
+	cfg := c.Copy()
+	cfg.Detector.BlackList = copyStrings(n.Detector.BlackList)
+	cfg.Detector.IntervalHitLimit = n.Detector.IntervalHitLimit
+	cfg.Detector.DefaultThresholdMaxs = copyFloats(n.Detector.DefaultThresholdMaxs)
+	cfg.Detector.DefaultThresholdMins = copyFloats(n.Detector.DefaultThresholdMins)
+	cfg.Detector.FillBlankZeros = copyStrings(n.Detector.FillBlankZeros)
+	cfg.Alerter.Interval = n.Alerter.Interval
+	cfg.Alerter.OneDayLimit = n.Alerter.OneDayLimit
+	cfg.Alerter.DefaultSilentTimeRange = append([]int{}, n.Alerter.DefaultSilentTimeRange...)
+	cfg.Webapp.Language = n.Webapp.Language
 	return cfg
 }
 
+// copyStrings returns a copy of the string slice, never nil.
+func copyStrings(l []string) []string {
+	return append([]string{}, l...)
+}
+
+// copyFloats returns a copy of the float map, never nil.
+func copyFloats(m map[string]float64) map[string]float64 {
+	c := make(map[string]float64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
 // Validate config.
 func (c *Config) Validate() error {
 	if err := c.validateGlobals(); err != nil {
 		return err
 	}
+	if err := c.Storage.validateStorage(c.Expiration); err != nil {
+		return err
+	}
 	if err := c.Detector.validateDetector(c.Period, c.Expiration); err != nil {
 		return err
 	}
@@ -196,6 +389,12 @@ func (c *Config) Validate() error {
 	if err := c.Alerter.validateAlerter(); err != nil {
 		return err
 	}
+	if err := c.validateCluster(); err != nil {
+		return err
+	}
+	if err := c.validateReplication(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -223,23 +422,72 @@ func (c *Config) validateGlobals() error {
 	return nil
 }
 
+func (c *configStorage) validateStorage(expiration uint32) error {
+	// Should: len(Rollups) <= 4
+	if len(c.Rollups) > MaxNumStorageRollups {
+		return ErrStorageRollupsLen
+	}
+	for i, rollup := range c.Rollups {
+		// Should: Interval >= 1 Minute, and increasing
+		if rollup.Interval < 1*Minute || (i > 0 && rollup.Interval <= c.Rollups[i-1].Interval) {
+			return ErrStorageRollupInterval
+		}
+		// Should: Expiration >= expiration
+		if rollup.Expiration < expiration {
+			return ErrStorageRollupExpiration
+		}
+	}
+	// Should: Admin.Dialect in sqlite3, mysql and postgres
+	switch c.Admin.Dialect {
+	case StorageAdminDialectSQLite3:
+	case StorageAdminDialectMySQL, StorageAdminDialectPostgres:
+		// Should: DSN not empty for shared dbs
+		if len(c.Admin.DSN) == 0 {
+			return ErrStorageAdminDSN
+		}
+	default:
+		return ErrStorageAdminDialect
+	}
+	return nil
+}
+
 func (c *configDetector) validateDetector(period uint32, expiration uint32) error {
 	// Should: 0 < Port < 65536
 	if c.Port < 1 || c.Port > 65535 {
 		return ErrDetectorPort
 	}
+	// Should: 0 <= UDPPort < 65536 (0 for disabled)
+	if c.UDPPort < 0 || c.UDPPort > 65535 {
+		return ErrDetectorUDPPort
+	}
+	// Should: 0 <= GraphitePort < 65536 (0 for disabled)
+	if c.GraphitePort < 0 || c.GraphitePort > 65535 {
+		return ErrDetectorGraphitePort
+	}
+	// Should: GraphitePort not in use by tcp or udp listener.
+	if c.GraphitePort != 0 && (c.GraphitePort == c.Port || c.GraphitePort == c.UDPPort) {
+		return ErrDetectorGraphitePort
+	}
+	// Should: 0 <= InfluxDBPort < 65536 (0 for disabled)
+	if c.InfluxDBPort < 0 || c.InfluxDBPort > 65535 {
+		return ErrDetectorInfluxDBPort
+	}
+	// Should: InfluxDBPort not in use by other listeners.
+	if c.InfluxDBPort != 0 && (c.InfluxDBPort == c.Port || c.InfluxDBPort == c.UDPPort || c.InfluxDBPort == c.GraphitePort) {
+		return ErrDetectorInfluxDBPort
+	}
+	// Should: Workers > 0
+	if c.Workers <= 0 {
+		return ErrDetectorWorkers
+	}
+	// Should: QueueSize >= Workers
+	if c.QueueSize < c.Workers {
+		return ErrDetectorQueueSize
+	}
 	// Should: 0 < TrendingFactor < 1
 	if c.TrendingFactor <= 0 || c.TrendingFactor >= 1 {
 		return ErrDetectorTrendingFactor
 	}
-	// Should: len(DefaultThresholdMaxs) <= 8
-	if len(c.DefaultThresholdMaxs) > MaxNumDefaultThresholdMaxs {
-		return ErrDetectorDefaultThresholdMaxsLen
-	}
-	// Should: len(DefaultThresholdMins) <= 8
-	if len(c.DefaultThresholdMins) > MaxNumDefaultThresholdMins {
-		return ErrDetectorDefaultThresholdMinsLen
-	}
 	// Should: No zero values in DefaultThresholdMaxs
 	for _, v := range c.DefaultThresholdMaxs {
 		if v == 0 {
@@ -252,17 +500,85 @@ func (c *configDetector) validateDetector(period uint32, expiration uint32) erro
 			return ErrDetectorDefaultThresholdMinZero
 		}
 	}
-	// Should: len(FillBlankZeros) <= 8
-	if len(c.FillBlankZeros) > MaxFillBlankZerosLen {
-		return ErrDetectorFillBlankZerosLen
+	// Should: Valid patterns of BlackList, DefaultThresholdMaxs,
+	// DefaultThresholdMins and FillBlankZeros, the same as rule patterns.
+	for _, p := range c.SettingPatterns() {
+		if !isValidSettingPattern(p) {
+			return ErrDetectorSettingPattern
+		}
 	}
 	// Should: FilterTimes * Period < Expiration
 	if uint32(c.FilterTimes)*period > expiration {
 		return ErrDetectorFilterTimes
 	}
+	// Should: Algorithm in Supported
+	if !IsDetectorAlgorithmSupported(c.Algorithm) {
+		return ErrDetectorAlgorithm
+	}
+	// Should: len(Algorithms) <= 8
+	if len(c.Algorithms) > MaxNumDetectorAlgorithms {
+		return ErrDetectorAlgorithmsLen
+	}
+	// Should: Algorithms values in Supported, and keys valid rule patterns
+	for p, v := range c.Algorithms {
+		if !IsDetectorAlgorithmSupported(v) {
+			return ErrDetectorAlgorithm
+		}
+		if !isValidSettingPattern(p) {
+			return ErrDetectorAlgorithmPattern
+		}
+	}
 	return nil
 }
 
+// SettingPatterns returns the patterns of BlackList, DefaultThresholdMaxs,
+// DefaultThresholdMins and FillBlankZeros.
+func (c *configDetector) SettingPatterns() []string {
+	patterns := append([]string{}, c.BlackList...)
+	for p := range c.DefaultThresholdMaxs {
+		patterns = append(patterns, p)
+	}
+	for p := range c.DefaultThresholdMins {
+		patterns = append(patterns, p)
+	}
+	return append(patterns, c.FillBlankZeros...)
+}
+
+// isValidSettingPattern returns true if the pattern is a valid rule pattern:
+// not empty, without spaces and tags, and well formed wildcards.
+func isValidSettingPattern(p string) bool {
+	return len(p) > 0 && !strings.ContainsAny(p, " \t\r\n;") && wildcard.Valid(p)
+}
+
+// RegisterDetectorAlgorithm registers the name of an algorithm, called on
+// registering the algorithm to the detector.
+func RegisterDetectorAlgorithm(name string) {
+	detectorAlgorithmsLock.Lock()
+	defer detectorAlgorithmsLock.Unlock()
+	detectorAlgorithms[name] = true
+}
+
+// DetectorAlgorithms returns the names of the algorithms registered to the
+// detector in order.
+func DetectorAlgorithms() []string {
+	detectorAlgorithmsLock.RLock()
+	defer detectorAlgorithmsLock.RUnlock()
+	names := make([]string, 0, len(detectorAlgorithms))
+	for name := range detectorAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsDetectorAlgorithmSupported returns true if the given algorithm name is
+// registered to the detector.
+func IsDetectorAlgorithmSupported(name string) bool {
+	detectorAlgorithmsLock.RLock()
+	defer detectorAlgorithmsLock.RUnlock()
+	return detectorAlgorithms[name]
+}
+
 func (c *configWebapp) validateWebapp() error {
 	// Should: 0 < Port < 65536
 	if c.Port < 1 || c.Port > 65535 {
@@ -299,5 +615,102 @@ func (c *configAlerter) validateAlerter() error {
 	if c.DefaultSilentTimeRange[1] < 0 || c.DefaultSilentTimeRange[1] > 23 {
 		return ErrAlerterDefaultSilentTimeRange
 	}
+	// Should: NotifyTimeout > 0
+	if c.NotifyTimeout <= 0 {
+		return ErrAlerterNotifyTimeout
+	}
+	// Should: GroupWindow <= 1 Hour
+	if c.GroupWindow > Hour {
+		return ErrAlerterGroupWindow
+	}
+	// Should: GroupBy is project or rule
+	if c.GroupBy != AlerterGroupByProject && c.GroupBy != AlerterGroupByRule {
+		return ErrAlerterGroupBy
+	}
+	// Should: Email Port and From valid if Host is set.
+	if len(c.Email.Host) > 0 {
+		if c.Email.Port < 1 || c.Email.Port > 65535 {
+			return ErrAlerterEmailPort
+		}
+		if !strings.Contains(c.Email.From, "@") {
+			return ErrAlerterEmailFrom
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateCluster() error {
+	// Should: 0 <= RouterPort < 65536
+	if c.Cluster.RouterPort < 0 || c.Cluster.RouterPort > 65535 {
+		return ErrClusterRouterPort
+	}
+	if len(c.Cluster.Nodes) == 0 {
+		// Should: Router requires nodes.
+		if c.Cluster.RouterPort != 0 {
+			return ErrClusterRouterPort
+		}
+		return nil
+	}
+	// Should: RouterPort not in use by other listeners.
+	if c.Cluster.RouterPort != 0 {
+		for _, port := range []int{c.Detector.Port, c.Detector.UDPPort, c.Detector.GraphitePort, c.Detector.InfluxDBPort, c.Webapp.Port} {
+			if c.Cluster.RouterPort == port {
+				return ErrClusterRouterPort
+			}
+		}
+	}
+	// Should: Nodes have unique names and addresses.
+	names := make(map[string]bool, len(c.Cluster.Nodes))
+	for _, node := range c.Cluster.Nodes {
+		if len(node.Name) == 0 || len(node.Detector) == 0 || len(node.Webapp) == 0 || names[node.Name] {
+			return ErrClusterNodes
+		}
+		names[node.Name] = true
+	}
+	// Should: Node in Nodes
+	if !names[c.Cluster.Node] {
+		return ErrClusterNode
+	}
+	return nil
+}
+
+// MetricExpiration returns the expiration of metrics not updated, the longest
+// of the expiration and the rollup tier expirations.
+func (c *Config) MetricExpiration() uint32 {
+	expiration := c.Expiration
+	for _, rollup := range c.Storage.Rollups {
+		if rollup.Expiration > expiration {
+			expiration = rollup.Expiration
+		}
+	}
+	return expiration
+}
+
+// IsCluster returns true if the cluster mode is enabled.
+func (c *Config) IsCluster() bool {
+	return len(c.Cluster.Nodes) > 0
+}
+
+func (c *Config) validateReplication() error {
+	// Should: Role in primary and replica
+	if c.Replication.Role != ReplicationRolePrimary && c.Replication.Role != ReplicationRoleReplica {
+		return ErrReplicationRole
+	}
+	// Should: 0 <= Port < 65536
+	if c.Replication.Port < 0 || c.Replication.Port > 65535 {
+		return ErrReplicationPort
+	}
+	// Should: Port not in use by other listeners.
+	if c.Replication.Port != 0 {
+		for _, port := range []int{c.Detector.Port, c.Detector.UDPPort, c.Detector.GraphitePort, c.Detector.InfluxDBPort, c.Webapp.Port, c.Cluster.RouterPort} {
+			if c.Replication.Port == port {
+				return ErrReplicationPort
+			}
+		}
+	}
+	// Should: Replica has a primary
+	if c.Replication.Role == ReplicationRoleReplica && len(c.Replication.Primary) == 0 {
+		return ErrReplicationPrimary
+	}
 	return nil
 }
@@ -8,11 +8,23 @@ import (
 	"testing"
 )
 
+func init() {
+	// Algorithms are registered by the detector.
+	for _, name := range []string{"3sigma", "mad", "percentile"} {
+		RegisterDetectorAlgorithm(name)
+	}
+}
+
 func TestExampleConfigParsing(t *testing.T) {
 	c := New()
 	err := c.UpdateWithYamlFile("./exampleConfig.yaml")
 	util.Must(t, err == nil)
 	defaults := New()
+	// Rollups are disabled by default, the example enables 2 tiers.
+	defaults.Storage.Rollups = []configStorageRollup{
+		{Interval: 1 * Minute, Expiration: 30 * Day},
+		{Interval: 10 * Minute, Expiration: 365 * Day},
+	}
 	util.Must(t, reflect.DeepEqual(c, defaults))
 }
 
@@ -21,3 +33,94 @@ func TestExampleConfigValidate(t *testing.T) {
 	c.UpdateWithYamlFile("./exampleConfig.yaml")
 	util.Must(t, c.Validate() == nil)
 }
+
+func TestValidateCluster(t *testing.T) {
+	c := New()
+	c.Cluster.RouterPort = 2017
+	util.Must(t, c.Validate() == ErrClusterRouterPort)
+	c.Cluster.Nodes = []configClusterNode{
+		{Name: "node1", Detector: "127.0.0.1:2015", Webapp: "127.0.0.1:2016"},
+		{Name: "node2", Detector: "127.0.0.2:2015", Webapp: "127.0.0.2:2016"},
+	}
+	util.Must(t, c.Validate() == ErrClusterNode)
+	c.Cluster.Node = "node1"
+	util.Must(t, c.Validate() == nil)
+	util.Must(t, c.IsCluster())
+	c.Cluster.RouterPort = c.Webapp.Port
+	util.Must(t, c.Validate() == ErrClusterRouterPort)
+	c.Cluster.RouterPort = 2017
+	c.Cluster.Nodes[1].Name = "node1"
+	util.Must(t, c.Validate() == ErrClusterNodes)
+	// Copy
+	c.Cluster.Nodes[1].Name = "node2"
+	cfg := c.Copy()
+	cfg.Cluster.Nodes[1].Name = "node3"
+	util.Must(t, c.Cluster.Nodes[1].Name == "node2")
+}
+
+func TestValidateReplication(t *testing.T) {
+	c := New()
+	c.Replication.Role = "master"
+	util.Must(t, c.Validate() == ErrReplicationRole)
+	c.Replication.Role = ReplicationRoleReplica
+	util.Must(t, c.Validate() == ErrReplicationPrimary)
+	c.Replication.Primary = "127.0.0.1:2018"
+	util.Must(t, c.Validate() == nil)
+	c.Replication.Port = c.Detector.Port
+	util.Must(t, c.Validate() == ErrReplicationPort)
+}
+
+func TestValidateDetectorSettingPatterns(t *testing.T) {
+	c := New()
+	c.Detector.BlackList = []string{"statsd.**", "timer.*_90.{get,set}"}
+	util.Must(t, c.Validate() == nil)
+	c.Detector.FillBlankZeros = []string{"counter.**.**"}
+	util.Must(t, c.Validate() == ErrDetectorSettingPattern)
+	c.Detector.FillBlankZeros = []string{}
+	c.Detector.DefaultThresholdMaxs["cpu;host=*"] = 1
+	util.Must(t, c.Validate() == ErrDetectorSettingPattern)
+	delete(c.Detector.DefaultThresholdMaxs, "cpu;host=*")
+	c.Detector.DefaultThresholdMins[""] = 1
+	util.Must(t, c.Validate() == ErrDetectorSettingPattern)
+}
+
+func TestValidateDetectorAlgorithm(t *testing.T) {
+	c := New()
+	c.Detector.Algorithms["timer.**"] = "mad"
+	util.Must(t, c.Validate() == nil)
+	util.Must(t, DetectorAlgorithms()[0] == "3sigma")
+	c.Detector.Algorithms["timer.**"] = "unknown"
+	util.Must(t, c.Validate() == ErrDetectorAlgorithm)
+}
+
+func TestCopy(t *testing.T) {
+	c := New()
+	c.Detector.BlackList = []string{"foo.*"}
+	c.Detector.DefaultThresholdMaxs["timer.mean_90.*"] = 300
+	cfg := c.Copy()
+	util.Must(t, reflect.DeepEqual(c, cfg))
+	// Not shared.
+	cfg.Webapp.Auth[0] = "******"
+	cfg.Detector.BlackList[0] = "bar.*"
+	cfg.Detector.DefaultThresholdMaxs["timer.mean_90.*"] = 200
+	cfg.Alerter.DefaultSilentTimeRange[0] = 1
+	util.Must(t, c.Webapp.Auth[0] == "admin")
+	util.Must(t, c.Detector.BlackList[0] == "foo.*")
+	util.Must(t, c.Detector.DefaultThresholdMaxs["timer.mean_90.*"] == 300)
+	util.Must(t, c.Alerter.DefaultSilentTimeRange[0] == 0)
+}
+
+func TestCopyReloadable(t *testing.T) {
+	c := New()
+	n := New()
+	n.Detector.Port = 2017
+	n.Detector.BlackList = []string{"foo.*"}
+	n.Alerter.Interval = 60
+	n.Webapp.Language = "zh"
+	cfg := c.CopyReloadable(n)
+	util.Must(t, cfg.Detector.Port == c.Detector.Port)
+	util.Must(t, cfg.Detector.BlackList[0] == "foo.*")
+	util.Must(t, cfg.Alerter.Interval == 60)
+	util.Must(t, cfg.Webapp.Language == "zh")
+	util.Must(t, len(c.Detector.BlackList) == 0)
+}
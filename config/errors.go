@@ -10,19 +10,41 @@ var (
 	ErrPeriodTooSmall                  = errors.New("period at least 1 hour")
 	ErrExpiration                      = errors.New("expiration should be an integer greater than 5 * period")
 	ErrExpirationDivPeriodClean        = errors.New("expiration should be divided by period cleanly")
+	ErrStorageRollupsLen               = errors.New("storage.rollups should have up to 4 items")
+	ErrStorageRollupInterval           = errors.New("storage.rollups interval should be at least 1 minute and increasing")
+	ErrStorageRollupExpiration         = errors.New("storage.rollups expiration should not be smaller than expiration")
+	ErrStorageAdminDialect             = errors.New("storage.admin.dialect should be one of sqlite3, mysql and postgres")
+	ErrStorageAdminDSN                 = errors.New("storage.admin.dsn is required for mysql and postgres")
 	ErrDetectorPort                    = errors.New("invalid detector.port")
+	ErrDetectorUDPPort                 = errors.New("invalid detector.udp_port")
+	ErrDetectorGraphitePort            = errors.New("invalid detector.graphite_port, should not conflict with other ports")
+	ErrDetectorInfluxDBPort            = errors.New("invalid detector.influxdb_port, should not conflict with other ports")
+	ErrDetectorWorkers                 = errors.New("detector.workers should be greater than 0")
+	ErrDetectorQueueSize               = errors.New("detector.queue_size should not be smaller than detector.workers")
 	ErrDetectorTrendingFactor          = errors.New("detector.trending_factor should be a float between 0 and 1")
 	ErrDetectorFilterTimes             = errors.New("detector.filter_times should be smaller")
-	ErrDetectorDefaultThresholdMaxsLen = errors.New("detector.default_threshold_maxs should have up to 8 items")
-	ErrDetectorDefaultThresholdMinsLen = errors.New("detector.default_threshold_mins should have up to 8 items")
 	ErrDetectorDefaultThresholdMaxZero = errors.New("detector.default_threshold_maxs should not contain zeros")
 	ErrDetectorDefaultThresholdMinZero = errors.New("detector.default_threshold_mins should not contain zeros")
-	ErrDetectorFillBlankZerosLen       = errors.New("detector.fill_blank_zeros should have up to 8 items")
+	ErrDetectorSettingPattern          = errors.New("detector.blacklist, default_threshold_maxs, default_threshold_mins and fill_blank_zeros should be valid rule patterns")
+	ErrDetectorAlgorithm               = errors.New("detector.algorithm and detector.algorithms should be registered algorithms, e.g. 3sigma, mad and percentile")
+	ErrDetectorAlgorithmPattern        = errors.New("detector.algorithms should be keyed by valid rule patterns")
+	ErrDetectorAlgorithmsLen           = errors.New("detector.algorithms should have up to 8 items")
 	ErrWebappPort                      = errors.New("invalid webapp.port")
 	ErrWebappLanguage                  = errors.New("invalid webapp language")
 	ErrAlerterInterval                 = errors.New("alerter.interval should be greater than 0")
 	ErrAlerterOneDayLimit              = errors.New("alerter.one_day_limit should be greater than 0")
 	ErrAlerterDefaultSilentTimeRange   = errors.New("alerter.default_silent_time_range should be 2 numbers between 0~24")
+	ErrAlerterNotifyTimeout            = errors.New("alerter.notify_timeout should be greater than 0")
+	ErrAlerterGroupWindow              = errors.New("alerter.group_window should be at most 1 hour")
+	ErrAlerterGroupBy                  = errors.New("alerter.group_by should be project or rule")
+	ErrAlerterEmailPort                = errors.New("invalid alerter.email.port")
+	ErrAlerterEmailFrom                = errors.New("alerter.email.from should be an email address")
+	ErrClusterNode                     = errors.New("cluster.node should be one of cluster.nodes")
+	ErrClusterNodes                    = errors.New("cluster.nodes should have unique names, detector and webapp addresses")
+	ErrClusterRouterPort               = errors.New("invalid cluster.router_port, should not conflict with other ports and requires cluster.nodes")
+	ErrReplicationRole                 = errors.New("replication.role should be primary or replica")
+	ErrReplicationPort                 = errors.New("invalid replication.port, should not conflict with other ports")
+	ErrReplicationPrimary              = errors.New("replication.primary is required for replica")
 	// Warn
 	ErrAlerterCommandEmpty = errors.New("alerter.command is empty")
 )
@@ -0,0 +1,170 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package detector
+
+import (
+	"math"
+	"sync"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/util/mathutil"
+)
+
+// Algorithm is the interface to score a metric value against its history
+// values.
+//
+// The score should follow the same convention for all algorithms:
+//
+//	score > 0   => values is trending up
+//	score < 0   => values is trending down
+//	score > 1   => values is anomalously trending up
+//	score < -1  => values is anomalously trending down
+//
+type Algorithm interface {
+	// Name returns the unique name of the algorithm.
+	Name() string
+	// Score returns the score and the average (baseline) for the last value
+	// of vals, the values before the last are the history values with the
+	// same phase. The vals is never empty.
+	Score(vals []float64) (score float64, avg float64)
+}
+
+// Registered algorithms.
+var (
+	algorithms     = make(map[string]Algorithm)
+	algorithmsLock sync.RWMutex
+)
+
+// RegisterAlgorithm registers an algorithm by its name, an algorithm
+// registered with the same name will be replaced. The name is also
+// registered to the config to validate against.
+func RegisterAlgorithm(alg Algorithm) {
+	algorithmsLock.Lock()
+	defer algorithmsLock.Unlock()
+	algorithms[alg.Name()] = alg
+	config.RegisterDetectorAlgorithm(alg.Name())
+}
+
+// GetAlgorithm returns the algorithm by name.
+func GetAlgorithm(name string) (Algorithm, bool) {
+	algorithmsLock.RLock()
+	defer algorithmsLock.RUnlock()
+	alg, ok := algorithms[name]
+	return alg, ok
+}
+
+func init() {
+	RegisterAlgorithm(sigma3{})
+	RegisterAlgorithm(mad{})
+	RegisterAlgorithm(percentile{})
+}
+
+// scoreBySign returns 0, 1 or -1 by comparing the value and the average,
+// used if the dispersion of values is zero.
+func scoreBySign(last, avg float64) float64 {
+	switch {
+	case last > avg:
+		return 1
+	case last < avg:
+		return -1
+	}
+	return 0
+}
+
+// sigma3 is the default algorithm.
+//
+// What's the 3-sigma rule?
+//
+//	states that nearly all values (99.7%) lie within the 3 standard deviations
+//	of the mean in a normal distribution.
+//
+// Also like z-score, defined as
+//
+//	(val - mean) / stddev
+//
+// And we name the below as metric score, yet 1/3 of z-score
+//
+//	(val - mean) / (3 * stddev)
+//
+type sigma3 struct{}
+
+func (sigma3) Name() string { return "3sigma" }
+
+func (sigma3) Score(vals []float64) (float64, float64) {
+	avg := mathutil.Average(vals)
+	std := mathutil.StdDev(vals, avg)
+	last := vals[len(vals)-1]
+	if std == 0 {
+		return scoreBySign(last, avg), avg
+	}
+	return (last - avg) / (3 * std), avg
+}
+
+// Constant to scale MAD to a consistent estimator of the standard deviation
+// for normally distributed values.
+const madScale = 1.4826
+
+// mad scores via the median absolute deviation, also known as the robust
+// z-score, which is much less sensitive to outliers in history values than
+// 3-sigma:
+//
+//	(val - median) / (3 * 1.4826 * median(|vals - median|))
+//
+// The average is the median.
+type mad struct{}
+
+func (mad) Name() string { return "mad" }
+
+func (mad) Score(vals []float64) (float64, float64) {
+	med := mathutil.Median(vals)
+	devs := make([]float64, len(vals))
+	for i, v := range vals {
+		devs[i] = math.Abs(v - med)
+	}
+	dev := madScale * mathutil.Median(devs)
+	last := vals[len(vals)-1]
+	if dev == 0 {
+		return scoreBySign(last, med), med
+	}
+	return (last - med) / (3 * dev), med
+}
+
+// Percentile bands of the percentile algorithm.
+const (
+	percentileLower = 1
+	percentileUpper = 99
+)
+
+// percentile scores by the distance from the median to the percentile bands
+// of the history values, the score reaches 1 at the upper band and -1 at the
+// lower band. It makes no assumption about the distribution, so it is
+// suitable for heavy-tailed metrics like latencies.
+//
+// The average is the median.
+type percentile struct{}
+
+func (percentile) Name() string { return "percentile" }
+
+func (percentile) Score(vals []float64) (float64, float64) {
+	last := vals[len(vals)-1]
+	history := vals[:len(vals)-1]
+	if len(history) == 0 {
+		return 0, last
+	}
+	med := mathutil.Median(history)
+	switch {
+	case last > med:
+		upper := mathutil.Percentile(history, percentileUpper)
+		if upper == med {
+			return 1, med
+		}
+		return (last - med) / (upper - med), med
+	case last < med:
+		lower := mathutil.Percentile(history, percentileLower)
+		if lower == med {
+			return -1, med
+		}
+		return (last - med) / (med - lower), med
+	}
+	return 0, med
+}
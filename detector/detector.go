@@ -5,34 +5,103 @@ package detector
 import (
 	"bufio"
 	"fmt"
+	"hash/fnv"
 	"net"
-	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/eleme/banshee/config"
 	"github.com/eleme/banshee/filter"
 	"github.com/eleme/banshee/health"
 	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/replication"
+	"github.com/eleme/banshee/router"
 	"github.com/eleme/banshee/storage"
 	"github.com/eleme/banshee/storage/indexdb"
 	"github.com/eleme/banshee/util"
 	"github.com/eleme/banshee/util/log"
-	"github.com/eleme/banshee/util/mathutil"
+	"github.com/eleme/banshee/util/wildcard"
 )
 
 // Timeout in milliseconds.
 const timeout = 300
 
+// Max size of an udp packet to read.
+const maxUDPPacketSize = 64 * 1024
+
+// Max time to wait for a full output channel, the event is dropped then.
+const outputTimeout = time.Second
+
 // Detector is to detect anomalies.
 type Detector struct {
-	cfg  *config.Config
-	db   *storage.DB
-	flt  *filter.Filter
-	outs []chan *models.Event
+	// Config, replaced on reloading.
+	cfg     *config.Config
+	cfgLock sync.RWMutex
+	db      *storage.DB
+	flt     *filter.Filter
+	outs    []chan *models.Event
+	// Ingestion queues of workers, metrics are queued by name so those of
+	// the same name are processed in order.
+	queues []chan *models.Metric
+	// Operations of workers, queued by name the same as metrics, e.g.
+	// deletions, so they are serialized with the metrics of the name.
+	ops []chan *op
+	// Set once the workers are started.
+	started int32
+	// Open alerting incidents.
+	incs *incidents
+	// Replaying stored metrics, history values are those before the metric.
+	replay bool
+	// Replication to publish metrics saved.
+	rep *replication.Replication
+	// Router to forward metrics owned by other nodes in cluster.
+	rt *router.Router
 }
 
 // New creates a detector.
 func New(cfg *config.Config, db *storage.DB, flt *filter.Filter) *Detector {
-	return &Detector{cfg, db, flt, make([]chan *models.Event, 0)}
+	d := &Detector{
+		cfg:    cfg,
+		db:     db,
+		flt:    flt,
+		outs:   make([]chan *models.Event, 0),
+		queues: make([]chan *models.Metric, cfg.Detector.Workers),
+		ops:    make([]chan *op, cfg.Detector.Workers),
+		incs:   newIncidents(),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan *models.Metric, cfg.Detector.QueueSize/cfg.Detector.Workers)
+		d.ops[i] = make(chan *op)
+	}
+	return d
+}
+
+// config returns the current config.
+func (d *Detector) config() *config.Config {
+	d.cfgLock.RLock()
+	defer d.cfgLock.RUnlock()
+	return d.cfg
+}
+
+// SetConfig replaces the config on reloading, only the reloadable fields
+// take effect, see config.CopyReloadable.
+func (d *Detector) SetConfig(cfg *config.Config) {
+	d.cfgLock.Lock()
+	defer d.cfgLock.Unlock()
+	d.cfg = cfg
+}
+
+// Replicate publishes metrics saved to replicas via the replication.
+func (d *Detector) Replicate(rep *replication.Replication) {
+	d.rep = rep
+}
+
+// Route forwards metrics received but owned by other nodes in cluster via
+// the router.
+func (d *Detector) Route(rt *router.Router) {
+	d.rt = rt
 }
 
 // Out adds a channel to receive detection results.
@@ -40,23 +109,146 @@ func (d *Detector) Out(ch chan *models.Event) {
 	d.outs = append(d.outs, ch)
 }
 
-// Output detected metrics to channels in outs, will skip if the target channel
-// is full.
+// Output detected metrics to channels in outs, waits for a full channel up to
+// outputTimeout, the event is dropped then.
 func (d *Detector) output(ev *models.Event) {
 	for _, ch := range d.outs {
 		select {
 		case ch <- ev:
-		default:
-			log.Errorf("output channel is full, skipping..")
 			continue
+		default:
 		}
+		timer := time.NewTimer(outputTimeout)
+		select {
+		case ch <- ev:
+		case <-timer.C:
+			health.IncrNumDroppedEvents(1)
+			log.Errorf("output channel is full, dropping..")
+		}
+		timer.Stop()
 	}
 }
 
-// Start the tcp server.
+// Start the tcp server, also the udp server, graphite servers and influxdb
+// servers if configured.
 func (d *Detector) Start() {
+	go d.expireIncidents()
+	go d.expireMetrics()
+	atomic.StoreInt32(&d.started, 1)
+	for i := range d.queues {
+		go d.work(d.queues[i], d.ops[i])
+	}
+	if d.config().Detector.UDPPort != 0 {
+		go d.serveUDP(d.config().Detector.UDPPort, single(parseMetric))
+	}
+	if d.config().Detector.GraphitePort != 0 {
+		go d.serveUDP(d.config().Detector.GraphitePort, single(parseGraphiteMetric))
+		go d.serveTCP(d.config().Detector.GraphitePort, single(parseGraphiteMetric))
+	}
+	if d.config().Detector.InfluxDBPort != 0 {
+		go d.serveUDP(d.config().Detector.InfluxDBPort, parseInfluxMetrics)
+		go d.serveTCP(d.config().Detector.InfluxDBPort, parseInfluxMetrics)
+	}
+	d.serveTCP(d.config().Detector.Port, single(parseMetric))
+}
+
+// expireIncidents removes incidents not detected in a period every hour.
+func (d *Detector) expireIncidents() {
+	ticker := time.NewTicker(time.Hour)
+	for _ = range ticker.C {
+		d.incs.expire(uint32(time.Now().Unix()) - d.config().Period)
+	}
+}
+
+// expireMetrics deletes metrics not updated in expiration every hour, both
+// the indexes and the data. Metrics in rollup tiers are kept until the
+// longest tier expiration.
+func (d *Detector) expireMetrics() {
+	ticker := time.NewTicker(time.Hour)
+	for _ = range ticker.C {
+		n, err := d.expire(uint32(time.Now().Unix()) - d.config().MetricExpiration())
+		if err != nil {
+			log.Errorf("expire metrics: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Infof("%d metrics expired", n)
+		}
+	}
+}
+
+// expire deletes metrics whose index is not updated since the stamp in the
+// workers, and publishes the deletions to replicas. Returns the number of
+// metrics deleted.
+func (d *Detector) expire(stamp uint32) (n int, err error) {
+	for _, idx := range d.db.Index.All() {
+		if idx.Stamp >= stamp {
+			continue
+		}
+		name := idx.Name
+		ok := false
+		err = d.do(name, func() (err error) {
+			if ok, err = d.db.ExpireMetric(name, stamp); ok {
+				d.replicateDelete(name)
+			}
+			return
+		})
+		if err == indexdb.ErrNotFound {
+			// Deleted meanwhile.
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DeleteMetric deletes a metric by name, both the index and the data, in the
+// worker of the name, so that it is not saved meanwhile. The deletion is
+// published to replicas.
+func (d *Detector) DeleteMetric(name string) error {
+	return d.do(name, func() error {
+		if err := d.db.DeleteMetric(name); err != nil {
+			return err
+		}
+		d.replicateDelete(name)
+		return nil
+	})
+}
+
+// replicateDelete publishes a metric deleted to replicas, in the worker of
+// the name, so it is in order with the metrics saved.
+func (d *Detector) replicateDelete(name string) {
+	if d.rep != nil {
+		d.rep.Delete(name)
+	}
+}
+
+// DeleteMetrics deletes metrics matching the pattern the same as
+// DeleteMetric, returns the number of metrics deleted.
+func (d *Detector) DeleteMetrics(pattern string) (n int, err error) {
+	for _, idx := range d.db.Index.Filter(pattern) {
+		err = d.DeleteMetric(idx.Name)
+		if err == indexdb.ErrNotFound {
+			// Deleted meanwhile.
+			continue
+		}
+		if err != nil {
+			return
+		}
+		n++
+	}
+	return n, nil
+}
+
+// serveTCP listens on the port and handles connections with the parser.
+func (d *Detector) serveTCP(port int, parse parser) {
 	// Listen
-	addr := fmt.Sprintf("0.0.0.0:%d", d.cfg.Detector.Port)
+	addr := fmt.Sprintf("0.0.0.0:%d", port)
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Fatalf("listen: %v", err)
@@ -69,7 +261,88 @@ func (d *Detector) Start() {
 			log.Errorf("cannot accept conn: %v, skipping..", err)
 			continue
 		}
-		go d.handle(conn)
+		go d.handle(conn, parse)
+	}
+}
+
+// op is an operation to run in a worker.
+type op struct {
+	fn   func() error
+	done chan error
+}
+
+// work processes metrics from the queue, and runs operations.
+func (d *Detector) work(q chan *models.Metric, ops chan *op) {
+	for {
+		select {
+		case m := <-q:
+			d.process(m)
+		case o := <-ops:
+			o.done <- o.fn()
+		}
+	}
+}
+
+// worker returns the index of the worker to process metrics by name.
+func (d *Detector) worker(name string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+// do runs fn in the worker of the name and waits for the result, fn is run
+// directly if the workers are not started.
+func (d *Detector) do(name string, fn func() error) error {
+	if atomic.LoadInt32(&d.started) == 0 {
+		return fn()
+	}
+	o := &op{fn: fn, done: make(chan error, 1)}
+	d.ops[d.worker(name)] <- o
+	return <-o.done
+}
+
+// enqueue queues a metric to the worker by name. Waits for the queue if wait
+// is true, which slows down the reading of the input, otherwise returns false
+// if the queue is full.
+func (d *Detector) enqueue(m *models.Metric, wait bool) bool {
+	q := d.queues[d.worker(m.Name)]
+	if wait {
+		q <- m
+		return true
+	}
+	select {
+	case q <- m:
+		return true
+	default:
+		return false
+	}
+}
+
+// serveUDP listens on the port and handles packets with the parser, a
+// packet may contain multiple lines.
+func (d *Detector) serveUDP(port int, parse parser) {
+	// Listen
+	addr := fmt.Sprintf("0.0.0.0:%d", port)
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+	log.Infof("detector is listening on udp://%s", addr)
+	// Read
+	buf := make([]byte, maxUDPPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Errorf("udp read error: %v, skipping..", err)
+			continue
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if len(strings.TrimSpace(line)) == 0 {
+				continue
+			}
+			// Packets can't wait.
+			d.handleLine(line, parse, false)
+		}
 	}
 }
 
@@ -78,8 +351,9 @@ func (d *Detector) Start() {
 //	1. Read input from the connection line by line.
 //	2. Parse the lines into metrics.
 //	3. Validate the metrics.
+//	4. Queue the metrics, the reading waits if the queue is full.
 //
-func (d *Detector) handle(conn net.Conn) {
+func (d *Detector) handle(conn net.Conn, parse parser) {
 	// New conn established.
 	addr := conn.RemoteAddr()
 	health.IncrNumClients(1)
@@ -93,30 +367,109 @@ func (d *Detector) handle(conn net.Conn) {
 			log.Errorf("read error: %v, closing conn..", err)
 			break
 		}
-		line := scanner.Text()
-		// Parse metric.
-		m, err := parseMetric(line)
-		if err != nil {
-			// Skip invalid input.
-			log.Errorf("parse error: %v, skipping..", err)
-			continue
-		}
-		// Validate metric.
-		if err := models.ValidateMetricName(m.Name); err != nil {
+		d.handleLine(scanner.Text(), parse, true)
+	}
+	// Close conn.
+	conn.Close()
+	log.Infof("conn %s disconnected", addr)
+	health.DecrNumClients(1)
+}
+
+// Handle a line of input, parse and validate it into metrics, then queue the
+// metrics. Waits for the queue if wait is true, otherwise the line is dropped
+// if the queue is full.
+func (d *Detector) handleLine(line string, parse parser, wait bool) {
+	// Parse metrics.
+	ms, err := parse(line)
+	if err != nil {
+		// Skip invalid input.
+		log.Errorf("parse error: %v, skipping..", err)
+		return
+	}
+	dropped := false
+	for _, m := range ms {
+		if err := validateMetric(m); err != nil {
 			log.Errorf("invalid metric: %v, skipping..", err)
 			continue
 		}
-		if err := models.ValidateMetricStamp(m.Stamp); err != nil {
-			log.Errorf("invalid metric: %v, skipping..", err)
+		if d.rt != nil && d.rt.Forward(m) {
 			continue
 		}
-		// Process
-		d.process(m)
+		if !d.enqueue(m, wait) {
+			dropped = true
+		}
+	}
+	if dropped {
+		health.IncrNumDroppedLines(1)
+		log.Debugf("queue is full, line dropped")
 	}
-	// Close conn.
-	conn.Close()
-	log.Infof("conn %s disconnected", addr)
-	health.DecrNumClients(1)
+}
+
+// Feed validates a metric and then queues it, the same as a metric line
+// received from the tcp server, waits for the queue if it is full. Returns an
+// error if the metric is invalid.
+func (d *Detector) Feed(m *models.Metric) error {
+	if err := validateMetric(m); err != nil {
+		return err
+	}
+	if d.rt != nil && d.rt.Forward(m) {
+		return nil
+	}
+	d.enqueue(m, true)
+	return nil
+}
+
+// Import validates a history metric and then saves it with the score, the
+// same as a metric detected, but rules are not tested and no events are
+// output. Metrics of the same name should be imported in time order, and the
+// index newer than the imported metric is kept. Saved in the worker of the
+// name once started, the same as live metrics. Returns ErrMetricNotOwned for
+// metrics owned by other nodes in cluster.
+func (d *Detector) Import(m *models.Metric) error {
+	if err := validateMetric(m); err != nil {
+		return err
+	}
+	if d.rt != nil && !d.rt.Owns(m) {
+		return ErrMetricNotOwned
+	}
+	// Serialized with the live metrics of the name.
+	return d.do(m.Name, func() error {
+		return d.importMetric(m)
+	})
+}
+
+// importMetric saves a history metric with the score.
+func (d *Detector) importMetric(m *models.Metric) error {
+	settings := d.matchSettings(m)
+	if settings.black {
+		return nil
+	}
+	rules := d.flt.Rules(m)
+	idx, err := d.getIdx(m.Name)
+	if err != nil {
+		return err
+	}
+	if idx == nil || idx.Stamp <= m.Stamp {
+		_, err := d.analyze(m, rules, settings)
+		return err
+	}
+	// Older than the index, e.g. history imported for a live metric, the
+	// index is kept.
+	if _, err := d.evaluate(m, idx, rules, settings); err != nil {
+		return err
+	}
+	return d.saveMetric(m, idx)
+}
+
+// validateMetric normalizes the metric tags and validates the metric.
+func validateMetric(m *models.Metric) error {
+	if err := m.NormalizeTags(); err != nil {
+		return err
+	}
+	if err := models.ValidateMetricName(m.Name); err != nil {
+		return err
+	}
+	return models.ValidateMetricStamp(m.Stamp)
 }
 
 // Process the input metric.
@@ -128,20 +481,20 @@ func (d *Detector) process(m *models.Metric) {
 	health.IncrNumMetricIncomed(1)
 	timer := util.NewTimer()
 	// Match
-	ok, rules := d.match(m)
+	ok, rules, settings := d.match(m)
 	if !ok {
 		// Not matched.
 		return
 	}
 	// Detect
-	ev, err := d.detect(m, rules)
+	evs, err := d.detect(m, rules, settings)
 	if err != nil {
 		log.Errorf("detect: %v, skipping..", err)
 		return
 	}
 	health.IncrNumMetricDetected(1)
 	// Output
-	if ev != nil {
+	for _, ev := range evs {
 		d.output(ev)
 	}
 	// Time end.
@@ -152,13 +505,14 @@ func (d *Detector) process(m *models.Metric) {
 	health.AddDetectionCost(elapsed)
 }
 
-// Match a metric with rules, and return matched rules.
+// Match a metric with rules, and return matched rules and the detector
+// settings.
 //
 //	If no rules matched, return false.
 //	If any black patterns matched, return false.
 //	Else, return true and matched rules.
 //
-func (d *Detector) match(m *models.Metric) (bool, []*models.Rule) {
+func (d *Detector) match(m *models.Metric) (bool, []*models.Rule, *metricSettings) {
 	// Check rules.
 	timer := util.NewTimer()
 	rules := d.flt.MatchedRules(m)
@@ -166,76 +520,140 @@ func (d *Detector) match(m *models.Metric) (bool, []*models.Rule) {
 	health.AddFilterCost(elapsed)
 	if len(rules) == 0 {
 		// Hit no rules.
-		return false, rules
+		return false, rules, nil
 	}
 	// Check blacklist.
-	for _, p := range d.cfg.Detector.BlackList {
-		ok, err := filepath.Match(p, m.Name)
-		if err != nil {
-			// Invalid black pattern.
-			log.Errorf("invalid black pattern: %s, %v", p, err)
-			continue
-		}
-		if ok {
+	settings := d.matchSettings(m)
+	if settings.black {
+		return false, rules, settings
+	}
+	// Rule hits.
+	for _, rule := range rules {
+		health.IncrRuleHits(rule.ID, 1)
+	}
+	// Ok
+	return true, rules, settings
+}
+
+// metricSettings are the detector settings of a metric.
+type metricSettings struct {
+	// Hit any black patterns.
+	black bool
+	// Fill blank with zeros to the history values.
+	fz bool
+	// Default thresholdMax and thresholdMin, 0 for none.
+	max, min float64
+}
+
+// matchSettings matches the detector settings of a metric, the settings
+// in db come first.
+func (d *Detector) matchSettings(m *models.Metric) *metricSettings {
+	settings := &metricSettings{}
+	for _, s := range d.flt.MatchedSettings(m) {
+		switch {
+		case s.Kind == models.DetectorSettingBlackList && !settings.black:
 			// Hit black pattern.
-			log.Debugf("%s hit black pattern %s", m.Name, p)
-			return false, rules
+			log.Debugf("%s hit black pattern %s", m.Name, s.Pattern)
+			settings.black = true
+		case s.Kind == models.DetectorSettingFillBlankZeros:
+			settings.fz = true
+		case s.Kind == models.DetectorSettingThresholdMax && settings.max == 0:
+			settings.max = s.Value
+		case s.Kind == models.DetectorSettingThresholdMin && settings.min == 0:
+			settings.min = s.Value
 		}
 	}
-	// Ok
-	return true, rules
+	return settings
+}
+
+// Detect input metric with its matched rules.
+//
+//	1. Analyze the metric and save it.
+//	2. Test with its matched rules and output it.
+//	3. Output resolved event for rules back in bounds.
+//
+func (d *Detector) detect(m *models.Metric, rules []*models.Rule, settings *metricSettings) ([]*models.Event, error) {
+	idx, err := d.analyze(m, rules, settings)
+	if err != nil {
+		return nil, err
+	}
+	// Test with rules.
+	d.test(m, idx, rules, settings)
+	var evs []*models.Event
+	if len(m.TestedRules) > 0 {
+		// Test ok.
+		evs = append(evs, models.NewEvent(m, idx))
+	}
+	if n := d.config().Alerter.ResolveIntervals; n > 0 {
+		// Resolved.
+		if resolved := d.incs.update(m, rules, n); len(resolved) > 0 {
+			evs = append(evs, models.NewResolvedEvent(m, idx, resolved))
+		}
+	}
+	return evs, nil
 }
 
-// Detect input metric with its matched rules via 3-sigma.
+// Analyze input metric with its matched rules, returns the new index.
 //
 //	1. Get history values for this metric.
 //	2. Get current index for this metric.
-//	3. Calculate score via 3-sigma.
+//	3. Calculate score via the algorithm (3-sigma by default).
 //	4. Get score trending via ewma.
 //	5. Save the metric and index to db.
-//	6. Test with its matched rules and output it.
 //
-func (d *Detector) detect(m *models.Metric, rules []*models.Rule) (*models.Event, error) {
+func (d *Detector) analyze(m *models.Metric, rules []*models.Rule, settings *metricSettings) (*models.Index, error) {
 	// Get index.
-	idx, err := d.db.Index.Get(m.Name)
+	idx, err := d.getIdx(m.Name)
 	if err != nil {
-		if err == indexdb.ErrNotFound {
-			idx = nil
-		} else {
-			return nil, err // unexcepted
-		}
+		return nil, err // unexcepted
+	}
+	alg, err := d.evaluate(m, idx, rules, settings)
+	if err != nil {
+		return nil, err // unexcepted
+	}
+	// New index.
+	idx = d.nextIdx(idx, m)
+	idx.Algorithm = alg.Name()
+	// Save
+	if err := d.save(m, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// getIdx returns the index by name, nil if not found.
+func (d *Detector) getIdx(name string) (*models.Index, error) {
+	idx, err := d.db.Index.Get(name)
+	if err == indexdb.ErrNotFound {
+		return nil, nil
 	}
+	return idx, err
+}
+
+// evaluate links the metric to the index if any and scores it with its
+// history values, returns the algorithm applied.
+func (d *Detector) evaluate(m *models.Metric, idx *models.Index, rules []*models.Rule, settings *metricSettings) (Algorithm, error) {
 	// Fill zero?
-	fz := idx != nil && d.shouldFz(m)
+	fz := idx != nil && settings.fz
 	if idx != nil {
 		m.LinkTo(idx)
 	}
 	// History values.
 	vals, err := d.values(m, fz)
 	if err != nil {
-		return nil, err // unexcepted
+		return nil, err
 	}
-	// Apply 3-sigma.
-	d.div3Sigma(m, vals)
-	// New index.
-	idx = d.nextIdx(idx, m)
-	// Test with rules.
-	d.test(m, idx, rules)
-	// Save
-	err = d.save(m, idx)
-	if len(m.TestedRules) > 0 {
-		// Test ok.
-		ev := models.NewEvent(m, idx)
-		return ev, err
-	}
-	return nil, err
+	// Apply algorithm.
+	alg := d.algorithm(m, rules)
+	d.score(m, vals, alg)
+	return alg, nil
 }
 
 // Test metric and index with rules.
 // The following function will fill the m.TestedRules.
-func (d *Detector) test(m *models.Metric, idx *models.Index, rules []*models.Rule) {
+func (d *Detector) test(m *models.Metric, idx *models.Index, rules []*models.Rule, settings *metricSettings) {
 	for _, rule := range rules {
-		if rule.Test(m, idx, d.cfg) {
+		if rule.Test(m, idx, settings.max, settings.min) {
 			// Add tested ok rules.
 			m.TestedRules = append(m.TestedRules, rule)
 		}
@@ -248,31 +666,20 @@ func (d *Detector) save(m *models.Metric, idx *models.Index) error {
 	if err := d.db.Index.Put(idx); err != nil {
 		return err
 	}
-	// Save metric.
+	return d.saveMetric(m, idx)
+}
+
+// saveMetric saves metric linked to the index into db.
+func (d *Detector) saveMetric(m *models.Metric, idx *models.Index) error {
 	m.LinkTo(idx)
 	if err := d.db.Metric.Put(m); err != nil {
 		return err
 	}
-	return nil
-}
-
-// Test whether a metric need to fill blank with zeros to its history
-// values.
-func (d *Detector) shouldFz(m *models.Metric) bool {
-	for _, p := range d.cfg.Detector.FillBlankZeros {
-		ok, err := filepath.Match(p, m.Name)
-		if err != nil {
-			// Invalid pattern.
-			log.Errorf("invalid fillBlankZeros pattern: %s, %v", p, err)
-			continue
-		}
-		if ok {
-			// Ok.
-			return true
-		}
+	// Replicate
+	if d.rep != nil {
+		d.rep.Put(m, idx)
 	}
-	// No need.
-	return false
+	return nil
 }
 
 // Fill blank with zeros into history values, mainly for dispersed
@@ -280,7 +687,7 @@ func (d *Detector) shouldFz(m *models.Metric) bool {
 // reasons.
 func (d *Detector) fill0(ms []*models.Metric, start, stop uint32) []float64 {
 	i := 0 // record real-metric.
-	step := d.cfg.Interval
+	step := d.config().Interval
 	var vals []float64
 	for start < stop {
 		if i < len(ms) {
@@ -324,18 +731,23 @@ func (d *Detector) values(m *models.Metric, fz bool) ([]float64, error) {
 		elapsed := timer.Elapsed()
 		health.AddQueryCost(elapsed)
 	}()
-	offset := uint32(d.cfg.Detector.FilterOffset * float64(d.cfg.Period))
-	expiration := d.cfg.Expiration
-	period := d.cfg.Period
-	ftimes := d.cfg.Detector.FilterTimes
+	cfg := d.config()
+	offset := uint32(cfg.Detector.FilterOffset * float64(cfg.Period))
+	expiration := cfg.Expiration
+	period := cfg.Period
+	ftimes := cfg.Detector.FilterTimes
 	// Get values with the same phase.
 	n := 0 // number of goroutines to luanch
 	ch := make(chan metricGetResult)
 	for stamp := m.Stamp; stamp+expiration > m.Stamp; stamp -= period {
 		start := stamp - offset
 		stop := stamp + offset
+		if d.replay && stop > m.Stamp {
+			// Exclude the metric and the later ones.
+			stop = m.Stamp
+		}
 		go func() {
-			ms, err := d.db.Metric.Get(m.Name, m.Link, start, stop)
+			ms, err := d.db.Metric.GetRaw(m.Name, m.Link, start, stop)
 			ch <- metricGetResult{err, ms, start, stop}
 		}()
 		n++
@@ -377,62 +789,65 @@ func (d *Detector) values(m *models.Metric, fz bool) ([]float64, error) {
 	return vals, nil
 }
 
-// Calculate metric score with 3-sigma rule.
-//
-// What's the 3-sigma rule?
-//
-//	states that nearly all values (99.7%) lie within the 3 standard deviations
-//	of the mean in a normal distribution.
+// Calculate metric score with the algorithm.
 //
-// Also like z-score, defined as
+// The following function will set the metric score and also the average, the
+// score is 0 if the values are not enough.
 //
-//	(val - mean) / stddev
-//
-// And we name the below as metric score, yet 1/3 of z-score
-//
-//	(val - mean) / (3 * stddev)
-//
-// The score has
-//
-//	score > 0   => values is trending up
-//	score < 0   => values is trending down
-//	score > 1   => values is anomalously trending up
-//	score < -1  => values is anomalously trending down
-//
-// The following function will set the metric score and also the average.
-//
-func (d *Detector) div3Sigma(m *models.Metric, vals []float64) {
+func (d *Detector) score(m *models.Metric, vals []float64, alg Algorithm) {
 	if len(vals) == 0 {
 		// Values empty.
 		m.Score = 0
 		m.Average = m.Value
 		return
 	}
-	// Values average and standard deviation.
-	avg := mathutil.Average(vals)
-	std := mathutil.StdDev(vals, avg)
+	score, avg := alg.Score(vals)
 	// Set metric average
 	m.Average = avg
 	// Set metric score
-	if len(vals) <= int(d.cfg.Detector.LeastCount) {
+	if len(vals) <= int(d.config().Detector.LeastCount) {
 		// Values not enough.
 		m.Score = 0
 		return
 	}
-	last := vals[len(vals)-1]
-	if std == 0 {
-		switch {
-		case last == avg:
-			m.Score = 0
-		case last > avg:
-			m.Score = 1
-		case last < avg:
-			m.Score = -1
+	m.Score = score
+}
+
+// Get the algorithm to score a metric.
+//
+//	1. The algorithm of the first matched rule with an algorithm set.
+//	2. The algorithm of the most specific matched pattern in config, the
+//	   longest one, or the smallest one in order if the same length.
+//	3. The default algorithm in config.
+//
+func (d *Detector) algorithm(m *models.Metric, rules []*models.Rule) Algorithm {
+	var name string
+	for _, rule := range rules {
+		if len(rule.Algorithm) > 0 {
+			name = rule.Algorithm
+			break
 		}
-		return
 	}
-	// 3-sigma
-	m.Score = (last - avg) / (3 * std)
+	if len(name) == 0 {
+		var matched string
+		for p, v := range d.config().Detector.Algorithms {
+			if !wildcard.Match(p, m.BaseName()) {
+				continue
+			}
+			if len(name) == 0 || len(p) > len(matched) || (len(p) == len(matched) && p < matched) {
+				matched, name = p, v
+			}
+		}
+	}
+	if len(name) == 0 {
+		name = d.config().Detector.Algorithm
+	}
+	if alg, ok := GetAlgorithm(name); ok {
+		return alg
+	}
+	log.Errorf("unknown algorithm %s, using %s", name, config.DefaultDetectorAlgorithm)
+	alg, _ := GetAlgorithm(config.DefaultDetectorAlgorithm)
+	return alg
 }
 
 // Calculate next score for index via ewma, called the weighted exponential
@@ -447,7 +862,7 @@ func (d *Detector) div3Sigma(m *models.Metric, vals []float64) {
 // Index score is the trending description of metric score.
 //
 func (d *Detector) nextIdx(idx *models.Index, m *models.Metric) *models.Index {
-	n := &models.Index{Name: m.Name, Stamp: m.Stamp}
+	n := &models.Index{Name: m.Name, Tags: m.Tags, Stamp: m.Stamp}
 	if idx == nil {
 		// As first
 		n.Score = m.Score
@@ -455,7 +870,7 @@ func (d *Detector) nextIdx(idx *models.Index, m *models.Metric) *models.Index {
 		return n
 	}
 	// Move next
-	f := d.cfg.Detector.TrendingFactor
+	f := d.config().Detector.TrendingFactor
 	n.Score = idx.Score*(1-f) + f*m.Score
 	n.Average = m.Average
 	n.Link = idx.Link
@@ -3,10 +3,20 @@
 package detector
 
 import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
 	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/filter"
 	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/router"
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/storage/indexdb"
 	"github.com/eleme/banshee/util"
-	"testing"
+	"gopkg.in/yaml.v2"
 )
 
 func TestFill0Issue470(t *testing.T) {
@@ -26,3 +36,221 @@ func TestFill0Issue470(t *testing.T) {
 		util.Must(t, excepted[i] == actually[i])
 	}
 }
+
+func TestAlgorithmsRegistered(t *testing.T) {
+	util.Must(t, len(config.DetectorAlgorithms()) == 3)
+	for _, name := range config.DetectorAlgorithms() {
+		alg, ok := GetAlgorithm(name)
+		util.Must(t, ok)
+		util.Must(t, alg.Name() == name)
+	}
+}
+
+func TestAlgorithmScore(t *testing.T) {
+	vals := []float64{10, 11, 9, 10, 12, 8, 10, 11, 9, 10}
+	for _, name := range config.DetectorAlgorithms() {
+		alg, _ := GetAlgorithm(name)
+		// Normal value.
+		score, _ := alg.Score(append(vals, 10))
+		util.Must(t, score > -1 && score < 1)
+		// Anomalously trending up.
+		score, _ = alg.Score(append(vals, 100))
+		util.Must(t, score > 1)
+		// Anomalously trending down.
+		score, _ = alg.Score(append(vals, -100))
+		util.Must(t, score < -1)
+	}
+}
+
+func TestAlgorithmMADRobust(t *testing.T) {
+	// Heavy-tailed history: 3-sigma is blinded by the spike, mad is not.
+	vals := []float64{10, 11, 9, 10, 1000, 10, 11, 9, 10, 30}
+	sigma3, _ := GetAlgorithm("3sigma")
+	mad, _ := GetAlgorithm("mad")
+	score, _ := sigma3.Score(vals)
+	util.Must(t, score < 1)
+	score, avg := mad.Score(vals)
+	util.Must(t, score > 1)
+	util.Must(t, avg == 10)
+}
+
+func TestDetectorAlgorithm(t *testing.T) {
+	cfg := config.New()
+	cfg.Detector.Algorithms["timer.upper_90.*"] = "percentile"
+	d := &Detector{cfg: cfg}
+	m := &models.Metric{Name: "timer.upper_90.foo"}
+	// Pattern in config.
+	util.Must(t, d.algorithm(m, nil).Name() == "percentile")
+	// Rule first.
+	rules := []*models.Rule{&models.Rule{}, &models.Rule{Algorithm: "mad"}}
+	util.Must(t, d.algorithm(m, rules).Name() == "mad")
+	// Default.
+	m = &models.Metric{Name: "counter.foo"}
+	util.Must(t, d.algorithm(m, nil).Name() == config.DefaultDetectorAlgorithm)
+	// Most specific pattern, matched the same as rules.
+	cfg.Detector.Algorithms["timer.**"] = "mad"
+	cfg.Detector.Algorithms["timer.upper_9*.*"] = "3sigma"
+	m = &models.Metric{Name: "timer.upper_90.foo;host=a"}
+	for i := 0; i < 10; i++ {
+		util.Must(t, d.algorithm(m, nil).Name() == "3sigma")
+	}
+	m = &models.Metric{Name: "timer.upper_90.foo.bar"}
+	util.Must(t, d.algorithm(m, nil).Name() == "mad")
+}
+
+func TestIncidents(t *testing.T) {
+	incs := newIncidents()
+	r1 := &models.Rule{ID: 1}
+	r2 := &models.Rule{ID: 2}
+	rules := []*models.Rule{r1, r2}
+	// Open on r1.
+	m := &models.Metric{Name: "foo", Stamp: 100, TestedRules: []*models.Rule{r1}}
+	util.Must(t, len(incs.update(m, rules, 2)) == 0)
+	util.Must(t, incs.len() == 1)
+	// In bounds once.
+	m = &models.Metric{Name: "foo", Stamp: 110}
+	util.Must(t, len(incs.update(m, rules, 2)) == 0)
+	// Renew resets the count.
+	m = &models.Metric{Name: "foo", Stamp: 120, TestedRules: []*models.Rule{r1}}
+	util.Must(t, len(incs.update(m, rules, 2)) == 0)
+	m = &models.Metric{Name: "foo", Stamp: 130}
+	util.Must(t, len(incs.update(m, rules, 2)) == 0)
+	// Resolved.
+	m = &models.Metric{Name: "foo", Stamp: 140}
+	resolved := incs.update(m, rules, 2)
+	util.Must(t, len(resolved) == 1 && resolved[0] == r1)
+	util.Must(t, incs.len() == 0)
+	// Expire.
+	m = &models.Metric{Name: "foo", Stamp: 150, TestedRules: []*models.Rule{r2}}
+	incs.update(m, rules, 2)
+	incs.expire(151)
+	util.Must(t, incs.len() == 0)
+}
+
+func TestEnqueue(t *testing.T) {
+	cfg := config.New()
+	cfg.Detector.Workers = 2
+	cfg.Detector.QueueSize = 2
+	d := New(cfg, nil, nil)
+	util.Must(t, len(d.queues) == 2)
+	// Queued by name.
+	line := fmt.Sprintf("foo %d 3.14", time.Now().Unix())
+	d.handleLine(line, single(parseMetric), false)
+	d.handleLine(line, single(parseMetric), false)
+	n := 0
+	for _, q := range d.queues {
+		n += len(q)
+	}
+	util.Must(t, n == 1)
+	// Full
+	m := &models.Metric{Name: "foo", Stamp: uint32(time.Now().Unix())}
+	util.Must(t, !d.enqueue(m, false))
+	// Invalid metric is not queued.
+	d.handleLine(fmt.Sprintf("foo; %d 3.14", time.Now().Unix()), single(parseMetric), false)
+	n = 0
+	for _, q := range d.queues {
+		n += len(q)
+	}
+	util.Must(t, n == 1)
+}
+
+func TestFeed(t *testing.T) {
+	cfg := config.New()
+	cfg.Detector.Workers = 2
+	d := New(cfg, nil, nil)
+	// Queued, not processed before the detector starts.
+	util.Must(t, d.Feed(&models.Metric{Name: "foo", Stamp: uint32(time.Now().Unix())}) == nil)
+	n := 0
+	for _, q := range d.queues {
+		n += len(q)
+	}
+	util.Must(t, n == 1)
+	// Invalid
+	util.Must(t, d.Feed(&models.Metric{Name: "foo", Stamp: 1}) != nil)
+}
+
+func TestRoute(t *testing.T) {
+	cfg := config.New()
+	s := "cluster:\n  node: node1\n  nodes:\n"
+	for i := 1; i <= 3; i++ {
+		s += fmt.Sprintf("    - {name: node%d, detector: \"127.0.0.%d:2015\", webapp: \"127.0.0.%d:2016\"}\n", i, i, i)
+	}
+	util.Must(t, yaml.Unmarshal([]byte(s), cfg) == nil)
+	cfg.Detector.QueueSize = 1024
+	rt := router.New(cfg)
+	d := New(cfg, nil, nil)
+	d.Route(rt)
+	// Only metrics owned are queued.
+	owned := 0
+	stamp := uint32(time.Now().Unix())
+	for i := 0; i < 30; i++ {
+		m := &models.Metric{Name: fmt.Sprintf("foo.%d", i), Stamp: stamp}
+		if rt.Owns(m) {
+			owned++
+		} else {
+			util.Must(t, d.Import(m) == ErrMetricNotOwned)
+		}
+		d.handleLine(fmt.Sprintf("%s %d 1", m.Name, stamp), single(parseMetric), false)
+	}
+	n := 0
+	for _, q := range d.queues {
+		n += len(q)
+	}
+	util.Must(t, owned > 0 && owned < 30 && n == owned)
+}
+
+func TestMatchSettings(t *testing.T) {
+	flt := filter.New()
+	flt.SetSettings([]*models.DetectorSetting{
+		{ID: 1, Kind: models.DetectorSettingThresholdMax, Pattern: "counter.*", Value: 10},
+		{ID: 2, Kind: models.DetectorSettingThresholdMax, Pattern: "counter.foo", Value: 20},
+		{ID: 3, Kind: models.DetectorSettingFillBlankZeros, Pattern: "counter.**"},
+		{ID: 4, Kind: models.DetectorSettingBlackList, Pattern: "statsd.**"},
+	})
+	d := New(config.New(), nil, flt)
+	settings := d.matchSettings(&models.Metric{Name: "counter.foo"})
+	util.Must(t, !settings.black && settings.fz)
+	util.Must(t, settings.max == 20 && settings.min == 0)
+	settings = d.matchSettings(&models.Metric{Name: "statsd.foo"})
+	util.Must(t, settings.black && !settings.fz && settings.max == 0)
+}
+
+func TestDeleteMetric(t *testing.T) {
+	// Open db.
+	fileName := "detector_test"
+	cfg := config.New()
+	opts := &storage.Options{Interval: cfg.Interval, Period: cfg.Period, Expiration: cfg.Expiration}
+	db, err := storage.Open(fileName, opts)
+	util.Must(t, err == nil)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	flt := filter.New()
+	flt.Init(db)
+	d := New(cfg, db, flt)
+	// Start workers only.
+	atomic.StoreInt32(&d.started, 1)
+	for i := range d.queues {
+		go d.work(d.queues[i], d.ops[i])
+	}
+	now := uint32(time.Now().Unix())
+	for i, name := range []string{"foo.a", "foo.b", "bar.a"} {
+		idx := &models.Index{Name: name, Stamp: now - uint32(i)*cfg.Expiration}
+		util.Must(t, db.Index.Put(idx) == nil)
+		util.Must(t, db.Metric.Put(&models.Metric{Link: idx.Link, Stamp: now, Value: 1}) == nil)
+	}
+	idx, _ := db.Index.Get("foo.a")
+	// Delete one.
+	util.Must(t, d.DeleteMetric("foo.a") == nil)
+	util.Must(t, d.DeleteMetric("foo.a") == indexdb.ErrNotFound)
+	ms, err := db.Metric.Get("foo.a", idx.Link, now, now+1)
+	util.Must(t, err == nil && len(ms) == 0)
+	// Delete by pattern.
+	n, err := d.DeleteMetrics("foo.*")
+	util.Must(t, err == nil && n == 1)
+	util.Must(t, !db.Index.Has("foo.b") && db.Index.Has("bar.a"))
+	// Expire.
+	util.Must(t, db.Index.Put(&models.Index{Name: "baz", Stamp: now}) == nil)
+	n, err = d.expire(now - cfg.Expiration)
+	util.Must(t, err == nil && n == 1)
+	util.Must(t, !db.Index.Has("bar.a") && db.Index.Has("baz"))
+}
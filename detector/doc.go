@@ -10,6 +10,53 @@ Line based text, for example:
 
 	timer.count_ps.get_user 1452674178 3.4
 
+The same protocol is also accepted over udp if detector.udp_port is set, one
+metric per line, multiple lines per packet.
+
+Graphite Plaintext Protocol
+
+If detector.graphite_port is set, the graphite plaintext protocol is accepted
+on this port over both tcp and udp, for example:
+
+	timer.count_ps.get_user 3.4 1452674178
+
+So graphite/carbon relays can forward metrics to banshee directly.
+
+InfluxDB Line Protocol
+
+If detector.influxdb_port is set, the influxdb line protocol is accepted on
+this port over both tcp and udp, for example:
+
+	cpu,host=web1 usage_idle=92.5,usage_user=3i 1452674178000000000
+
+Each numeric field is a metric named as "measurement.field" with the line
+tags, the example above is two metrics cpu.usage_idle and cpu.usage_user
+tagged with host=web1. String fields are skipped, booleans are 1 or 0, and
+the nanoseconds stamp is truncated to seconds, default to the time now.
+
+So telegraf agents can write to banshee directly via the socket_writer output
+with the influx data format.
+
+Ingestion Queue
+
+Metrics parsed are queued to detector.workers workers by name, so metrics of
+the same name are detected in order. Once the queue is full, reading from
+tcp connections waits for room, which slows down the clients instead of
+dropping; udp lines are dropped and counted as numDroppedLines in health
+info. Alerting events wait up to 1 second for a busy alerter before dropped
+and counted as numDroppedEvents.
+
+Tagged Metrics
+
+Metrics may be tagged in graphite's tagged series format on both protocols,
+for example:
+
+	cpu.usage;host=web1;region=us 1452674178 0.8
+
+Tags are sorted by key, and dots in tags are replaced by underscores. A
+tagged metric is a series apart from other tag sets of the same name, rule
+patterns match the name without tags, and rule tag selectors match the tags.
+
 Detection Algorithms
 
 A simple approach to detect anomalies is to set fixed thresholds, but
@@ -39,5 +86,28 @@ If score < -1, that means the metric is currently anomalously trending down.
 
 If score is larger than -1 and less than 1, the metric is normal.
 
+Pluggable Algorithms
+
+The 3-sigma rule assumes values are normally distributed, which is not true
+for heavy-tailed metrics like latencies. The scoring algorithm can be
+selected by rule (rule.algorithm), by metric pattern (detector.algorithms) or
+globally (detector.algorithm), all algorithms share the score convention above:
+
+	3sigma       (value - mean) / (3 * stddev), the default.
+	mad          (value - median) / (3 * 1.4826 * MAD), robust z-score.
+	percentile   distance from median to the 1st/99th percentile bands.
+
+The algorithm used is recorded in the metric index, as index.algorithm.
+
+New algorithms can be plugged in by implementing the Algorithm interface
+and calling RegisterAlgorithm.
+
+Incidents
+
+A metric hitting a rule opens an incident for the metric and the rule, the
+incident is resolved once the metric is detected in bounds of the rule for
+continuous alerter.resolve_intervals times, and a resolved event is then
+output to the alerter.
+
 */
 package detector
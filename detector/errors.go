@@ -11,4 +11,23 @@ var (
 	ErrMetricNameTooLong = errors.New("detector: metric name is too long")
 	// ErrMetricStampTooSmall is returned when input metric stamp is too small.
 	ErrMetricStampTooSmall = errors.New("detector: metric stamp is too small")
+	// ErrMetricNotOwned is returned when importing a metric owned by other
+	// nodes in cluster.
+	ErrMetricNotOwned = errors.New("detector: metric is owned by another node")
+	// ErrImportFormat is returned when the import format is unknown.
+	ErrImportFormat = errors.New("detector: unknown import format")
+	// ErrReplayRange is returned when the replay time range is invalid.
+	ErrReplayRange = errors.New("detector: invalid replay range")
+	// ErrReplayTrendingFactor is returned when the replay trending factor is
+	// invalid.
+	ErrReplayTrendingFactor = errors.New("detector: replay trending factor should be between 0 and 1")
+	// ErrReplayFilterTimes is returned when the replay filter times is
+	// invalid.
+	ErrReplayFilterTimes = errors.New("detector: replay filter times should be smaller")
+	// ErrReplayTooManyMetrics is returned when too many metrics match the
+	// rule to replay.
+	ErrReplayTooManyMetrics = errors.New("detector: too many metrics to replay")
+	// ErrReplayTooManyPoints is returned when too many metric points are in
+	// the replay range.
+	ErrReplayTooManyPoints = errors.New("detector: too many points to replay, narrow the range")
 )
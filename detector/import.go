@@ -0,0 +1,109 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package detector
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/eleme/banshee/models"
+)
+
+// Import formats.
+const (
+	// The detector's net protocol: "name stamp value".
+	ImportFormatLine = "line"
+	// Comma separated: "name,stamp,value", with an optional header.
+	ImportFormatCSV = "csv"
+)
+
+// Importer reads history metrics to import line by line, empty lines are
+// skipped.
+//
+//	imp, _ := NewImporter(r, ImportFormatCSV)
+//	for imp.Scan() {
+//		m, err := imp.Metric()
+//		...
+//	}
+//	err := imp.Err()
+//
+type Importer struct {
+	scanner *bufio.Scanner
+	format  string
+	parse   func(line string) (*models.Metric, error)
+	line    int
+	m       *models.Metric
+	err     error
+}
+
+// NewImporter creates an importer reading from r in the format.
+// Returns ErrImportFormat if the format is unknown.
+func NewImporter(r io.Reader, format string) (*Importer, error) {
+	imp := &Importer{scanner: bufio.NewScanner(r), format: format}
+	switch format {
+	case ImportFormatLine:
+		imp.parse = parseMetric
+	case ImportFormatCSV:
+		imp.parse = parseCSVMetric
+	default:
+		return nil, ErrImportFormat
+	}
+	return imp, nil
+}
+
+// Scan advances to the next metric, returns false at the end or on read
+// errors.
+func (imp *Importer) Scan() bool {
+	for imp.scanner.Scan() {
+		imp.line++
+		line := strings.TrimSpace(imp.scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if imp.format == ImportFormatCSV && strings.HasPrefix(line, "name,") {
+			// CSV header.
+			continue
+		}
+		imp.m, imp.err = imp.parse(line)
+		return true
+	}
+	return false
+}
+
+// Metric returns the metric scanned, or the error if the line is invalid.
+func (imp *Importer) Metric() (*models.Metric, error) {
+	return imp.m, imp.err
+}
+
+// Line returns the line number scanned, starting from 1.
+func (imp *Importer) Line() int {
+	return imp.line
+}
+
+// Err returns the read error.
+func (imp *Importer) Err() error {
+	return imp.scanner.Err()
+}
+
+// Parse input line text in csv into a metric.
+//
+// The csv columns are in the same order with the detector's net protocol:
+//	name,stamp,value
+//	foo,1449481993,3.145
+//
+// Values may be double quoted.
+func parseCSVMetric(line string) (*models.Metric, error) {
+	words := strings.Split(line, ",")
+	if len(words) != 3 {
+		// Wrong number of fields.
+		return nil, ErrProtocol
+	}
+	for i, word := range words {
+		words[i] = strings.Trim(strings.TrimSpace(word), `"`)
+		if len(words[i]) == 0 {
+			return nil, ErrProtocol
+		}
+	}
+	return parseMetric(strings.Join(words, " "))
+}
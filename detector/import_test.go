@@ -0,0 +1,100 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package detector
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/filter"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/util"
+)
+
+func TestImporter(t *testing.T) {
+	r := strings.NewReader("name,stamp,value\nfoo,1449655769,3.14\n\n\"bar\", 1449655770, 1\nbaz,1.3,1\n")
+	imp, err := NewImporter(r, ImportFormatCSV)
+	util.Must(t, err == nil)
+	util.Must(t, imp.Scan())
+	m, err := imp.Metric()
+	util.Must(t, err == nil && imp.Line() == 2)
+	util.Must(t, m.Name == "foo" && m.Stamp == 1449655769 && m.Value == 3.14)
+	util.Must(t, imp.Scan())
+	m, err = imp.Metric()
+	util.Must(t, err == nil && imp.Line() == 4)
+	util.Must(t, m.Name == "bar" && m.Stamp == 1449655770 && m.Value == 1)
+	util.Must(t, imp.Scan())
+	_, err = imp.Metric()
+	util.Must(t, err != nil && imp.Line() == 5)
+	util.Must(t, !imp.Scan() && imp.Err() == nil)
+	// Line format.
+	imp, _ = NewImporter(strings.NewReader("foo 1449655769 3.14"), ImportFormatLine)
+	util.Must(t, imp.Scan())
+	m, err = imp.Metric()
+	util.Must(t, err == nil && m.Name == "foo")
+	// Unknown format.
+	_, err = NewImporter(r, "json")
+	util.Must(t, err == ErrImportFormat)
+}
+
+func TestImport(t *testing.T) {
+	// Open db.
+	fileName := "detector_test"
+	cfg := config.New()
+	opts := &storage.Options{Interval: cfg.Interval, Period: cfg.Period, Expiration: cfg.Expiration}
+	db, err := storage.Open(fileName, opts)
+	util.Must(t, err == nil)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	flt := filter.New()
+	flt.Init(db)
+	rule := &models.Rule{ID: 1, Pattern: "foo", ThresholdMax: 1}
+	db.Admin.RulesCache.Put(rule)
+	d := New(cfg, db, flt)
+	ch := make(chan *models.Event, 1)
+	d.Out(ch)
+	// Import history of 2 days, the values are over the threshold.
+	now := uint32(time.Now().Unix())
+	start := now - 2*config.Day
+	for stamp := start; stamp < now; stamp += cfg.Interval {
+		util.Must(t, d.Import(&models.Metric{Name: "foo", Stamp: stamp, Value: 10}) == nil)
+	}
+	util.Must(t, len(ch) == 0)
+	idx, err := db.Index.Get("foo")
+	util.Must(t, err == nil && idx.Stamp == now-cfg.Interval)
+	ms, err := db.Metric.Get("foo", idx.Link, start, now)
+	util.Must(t, err == nil && len(ms) == int(2*config.Day/cfg.Interval))
+	// Invalid
+	util.Must(t, d.Import(&models.Metric{Name: "foo", Stamp: 1}) == models.ErrMetricStampTooSmall)
+}
+
+func TestImportLive(t *testing.T) {
+	// Open db.
+	fileName := "detector_test"
+	cfg := config.New()
+	opts := &storage.Options{Interval: cfg.Interval, Period: cfg.Period, Expiration: cfg.Expiration}
+	db, err := storage.Open(fileName, opts)
+	util.Must(t, err == nil)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	flt := filter.New()
+	flt.Init(db)
+	d := New(cfg, db, flt)
+	// Live metric.
+	now := uint32(time.Now().Unix())
+	util.Must(t, d.Import(&models.Metric{Name: "foo", Stamp: now, Value: 10}) == nil)
+	live, err := db.Index.Get("foo")
+	util.Must(t, err == nil && live.Stamp == now)
+	// Import history.
+	for stamp := now - config.Hour; stamp < now; stamp += cfg.Interval {
+		util.Must(t, d.Import(&models.Metric{Name: "foo", Stamp: stamp, Value: 100}) == nil)
+	}
+	idx, err := db.Index.Get("foo")
+	util.Must(t, err == nil && idx.Equal(live))
+	ms, err := db.Metric.Get("foo", idx.Link, now-config.Hour, now+1)
+	util.Must(t, err == nil && len(ms) == int(config.Hour/cfg.Interval)+1)
+}
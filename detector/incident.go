@@ -0,0 +1,85 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package detector
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/eleme/banshee/models"
+)
+
+// incident is an open anomaly of a metric on a rule.
+type incident struct {
+	// Last stamp the metric was detected.
+	stamp uint32
+	// Number of continuous detections in bounds.
+	normals uint32
+}
+
+// incidents tracks open incidents by metric name and rule id.
+type incidents struct {
+	lock sync.Mutex
+	m    map[string]*incident
+}
+
+// newIncidents creates an incidents tracker.
+func newIncidents() *incidents {
+	return &incidents{m: make(map[string]*incident)}
+}
+
+// incidentKey returns the key of the incident for metric and rule.
+func incidentKey(name string, rule *models.Rule) string {
+	return fmt.Sprintf("%s:%d", name, rule.ID)
+}
+
+// update the incidents of a detected metric with its matched rules, the
+// rules in m.TestedRules open or renew incidents, the others count the
+// detections in bounds for open incidents. Returns the rules whose
+// incidents are resolved by n continuous detections in bounds.
+func (is *incidents) update(m *models.Metric, rules []*models.Rule, n uint32) (resolved []*models.Rule) {
+	tested := make(map[int]bool, len(m.TestedRules))
+	for _, rule := range m.TestedRules {
+		tested[rule.ID] = true
+	}
+	is.lock.Lock()
+	defer is.lock.Unlock()
+	for _, rule := range rules {
+		key := incidentKey(m.Name, rule)
+		if tested[rule.ID] {
+			// Open or renew.
+			is.m[key] = &incident{stamp: m.Stamp}
+			continue
+		}
+		inc, ok := is.m[key]
+		if !ok {
+			continue
+		}
+		inc.stamp = m.Stamp
+		inc.normals++
+		if inc.normals >= n {
+			delete(is.m, key)
+			resolved = append(resolved, rule)
+		}
+	}
+	return resolved
+}
+
+// expire removes incidents not detected since the stamp, i.e. the metric
+// is gone or the rule is deleted.
+func (is *incidents) expire(stamp uint32) {
+	is.lock.Lock()
+	defer is.lock.Unlock()
+	for key, inc := range is.m {
+		if inc.stamp < stamp {
+			delete(is.m, key)
+		}
+	}
+}
+
+// len returns the number of open incidents.
+func (is *incidents) len() int {
+	is.lock.Lock()
+	defer is.lock.Unlock()
+	return len(is.m)
+}
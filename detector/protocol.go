@@ -4,10 +4,26 @@ package detector
 
 import (
 	"github.com/eleme/banshee/models"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// parser parses input line text into metrics.
+type parser func(line string) ([]*models.Metric, error)
+
+// single returns a parser from a function parsing a line into one metric.
+func single(parse func(line string) (*models.Metric, error)) parser {
+	return func(line string) ([]*models.Metric, error) {
+		m, err := parse(line)
+		if err != nil {
+			return nil, err
+		}
+		return []*models.Metric{m}, nil
+	}
+}
+
 // Parse input line text into a metric.
 //
 // The detector's net protocol is, with an example:
@@ -41,3 +57,183 @@ func parseMetric(line string) (*models.Metric, error) {
 	}
 	return m, nil
 }
+
+// Parse input line text in graphite plaintext protocol into a metric.
+//
+// The graphite plaintext protocol is, with an example:
+//	Path	Value	Stamp		\n
+//	foo		3.145	1449481993	\n
+//
+// Input line will be trimed at first before being processed. Values of NaN
+// or infinity are refused.
+func parseGraphiteMetric(line string) (*models.Metric, error) {
+	// Clean spaces.
+	line = strings.TrimSpace(line)
+	// Split fields.
+	words := strings.Fields(line)
+	if len(words) != 3 {
+		// Wrong number of fields.
+		return nil, ErrProtocol
+	}
+	var err error
+	m := &models.Metric{}
+	// Path is a string
+	m.Name = words[0]
+	// Value is a float64.
+	m.Value, err = strconv.ParseFloat(words[1], 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+		return nil, ErrProtocol
+	}
+	// Stamp is a uint32, some clients send it as a float.
+	stamp, err := strconv.ParseFloat(words[2], 64)
+	if err != nil {
+		return nil, err
+	}
+	if stamp < 0 || stamp > math.MaxUint32 {
+		return nil, ErrProtocol
+	}
+	m.Stamp = uint32(stamp)
+	return m, nil
+}
+
+// Parse input line text in influxdb line protocol into metrics.
+//
+// The influxdb line protocol is, with an example:
+//	Measurement[,Tags]		Fields				[Stamp]			\n
+//	cpu,host=web1			usage_idle=92.5,usage_user=3i	1449481993000000000	\n
+//
+// Each numeric field is a metric named "measurement.field" with the tags,
+// string fields are skipped. The stamp is in nanoseconds, default to the time
+// now. Values of NaN or infinity are refused.
+func parseInfluxMetrics(line string) ([]*models.Metric, error) {
+	// Clean spaces.
+	line = strings.TrimSpace(line)
+	// Split sections.
+	sections := splitInfluxLine(line, ' ')
+	if len(sections) != 2 && len(sections) != 3 {
+		// Wrong number of sections.
+		return nil, ErrProtocol
+	}
+	// Stamp is in nanoseconds.
+	stamp := uint32(time.Now().Unix())
+	if len(sections) == 3 {
+		ns, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if ns < 0 || ns/int64(time.Second) > math.MaxUint32 {
+			return nil, ErrProtocol
+		}
+		stamp = uint32(ns / int64(time.Second))
+	}
+	// Measurement and tags.
+	keys := splitInfluxLine(sections[0], ',')
+	measurement := unescapeInflux(keys[0])
+	if len(measurement) == 0 {
+		return nil, ErrProtocol
+	}
+	var tags map[string]string
+	for _, tag := range keys[1:] {
+		kv := splitInfluxLine(tag, '=')
+		if len(kv) != 2 {
+			return nil, ErrProtocol
+		}
+		if tags == nil {
+			tags = make(map[string]string, len(keys)-1)
+		}
+		k := strings.Replace(unescapeInflux(kv[0]), " ", "_", -1)
+		tags[k] = strings.Replace(unescapeInflux(kv[1]), " ", "_", -1)
+	}
+	// Fields.
+	var ms []*models.Metric
+	for _, field := range splitInfluxLine(sections[1], ',') {
+		kv := splitInfluxLine(field, '=')
+		if len(kv) != 2 || len(kv[0]) == 0 || len(kv[1]) == 0 {
+			return nil, ErrProtocol
+		}
+		value, ok, err := parseInfluxValue(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// String field.
+			continue
+		}
+		name := measurement + "." + unescapeInflux(kv[0])
+		m := &models.Metric{
+			Name:  strings.Replace(name, " ", "_", -1),
+			Tags:  tags,
+			Stamp: stamp,
+			Value: value,
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// parseInfluxValue parses an influxdb field value, returns false for string
+// values. Integers end with "i" or "u", and booleans are parsed to 1 or 0.
+func parseInfluxValue(s string) (float64, bool, error) {
+	switch s {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true, nil
+	}
+	if s[0] == '"' {
+		return 0, false, nil
+	}
+	if c := s[len(s)-1]; c == 'i' || c == 'u' {
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false, ErrProtocol
+	}
+	return v, true, nil
+}
+
+// splitInfluxLine splits s by the separator, except the escaped ones and
+// the ones in double quoted strings.
+func splitInfluxLine(s string, sep byte) []string {
+	var l []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++ // Skip the escaped
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			l = append(l, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(l, s[start:])
+}
+
+// unescapeInflux unescapes commas, equal signs and spaces in measurements,
+// tags and field keys.
+func unescapeInflux(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case ',', '=', ' ':
+				i++
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
@@ -22,3 +22,64 @@ func TestParseMetricBadLine(t *testing.T) {
 	util.Must(t, err != nil)
 	util.Must(t, m == nil)
 }
+
+func TestParseGraphiteMetric(t *testing.T) {
+	line := "foo.bar 3.14 1449655769"
+	m, err := parseGraphiteMetric(line)
+	util.Must(t, err == nil)
+	util.Must(t, m.Name == "foo.bar")
+	util.Must(t, m.Stamp == uint32(1449655769))
+	util.Must(t, m.Value == 3.14)
+	// Float stamp.
+	m, err = parseGraphiteMetric("foo 3 1449655769.0")
+	util.Must(t, err == nil)
+	util.Must(t, m.Stamp == uint32(1449655769))
+}
+
+func TestParseGraphiteMetricBadLine(t *testing.T) {
+	for _, line := range []string{
+		"foo 1449655769",
+		"foo bar 1449655769",
+		"foo nan 1449655769",
+		"foo 1.2 -1",
+	} {
+		m, err := parseGraphiteMetric(line)
+		util.Must(t, err != nil)
+		util.Must(t, m == nil)
+	}
+}
+
+func TestParseInfluxMetrics(t *testing.T) {
+	line := `cpu,host=web1.example.com,region=us usage_idle=92.5,usage_user=3i,busy=t,state="ok, fine" 1449655769123456789`
+	ms, err := parseInfluxMetrics(line)
+	util.Must(t, err == nil)
+	util.Must(t, len(ms) == 3)
+	util.Must(t, ms[0].Name == "cpu.usage_idle" && ms[0].Value == 92.5)
+	util.Must(t, ms[1].Name == "cpu.usage_user" && ms[1].Value == 3)
+	util.Must(t, ms[2].Name == "cpu.busy" && ms[2].Value == 1)
+	for _, m := range ms {
+		util.Must(t, m.Stamp == uint32(1449655769))
+		util.Must(t, m.Tags["host"] == "web1.example.com" && m.Tags["region"] == "us")
+	}
+	// Escaped and no stamp.
+	ms, err = parseInfluxMetrics(`disk\ io,path=/data\,1 reads=1.5`)
+	util.Must(t, err == nil && len(ms) == 1)
+	util.Must(t, ms[0].Name == "disk_io.reads" && ms[0].Tags["path"] == "/data,1")
+	util.Must(t, ms[0].Stamp > 0)
+}
+
+func TestParseInfluxMetricsBadLine(t *testing.T) {
+	for _, line := range []string{
+		"cpu",
+		"cpu,host usage=1",
+		"cpu usage= 1449655769000000000",
+		"cpu usage=bad",
+		"cpu usage=1 bad",
+		"cpu usage=NaN",
+		"cpu usage=1 1449655769000000000 1",
+	} {
+		ms, err := parseInfluxMetrics(line)
+		util.Must(t, err != nil)
+		util.Must(t, ms == nil)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package detector
+
+import (
+	"sort"
+	"time"
+
+	"github.com/eleme/banshee/models"
+)
+
+// MaxReplayMetrics is the max number of metrics a replay can handle.
+const MaxReplayMetrics = 256
+
+// MaxReplayPoints is the max number of metric points a replay can handle,
+// each point reads its history values, e.g. 64 metrics of 1 day by 10s.
+const MaxReplayPoints = 64 * 8640
+
+// ReplayOptions is the options to replay a rule, zero values are for the
+// detector's config.
+type ReplayOptions struct {
+	// Time range, stop is exclusive.
+	Start uint32
+	Stop  uint32
+	// Alternate detector options.
+	TrendingFactor float64
+	FilterTimes    int
+	// Max number of events in result, 0 for no events.
+	Limit int
+}
+
+// ReplayDay is the number of events of a day in replay result, days are in
+// UTC.
+type ReplayDay struct {
+	Day       string `json:"day"`
+	NumEvents int    `json:"numEvents"`
+}
+
+// ReplayResult is the result of a replay.
+type ReplayResult struct {
+	NumMetrics int             `json:"numMetrics"`
+	NumPoints  int             `json:"numPoints"`
+	NumEvents  int             `json:"numEvents"`
+	Days       []*ReplayDay    `json:"days"`
+	Events     []*models.Event `json:"events"`
+}
+
+// Replay replays the stored metrics matching the rule in a time range, the
+// metrics are scored again and tested with the rule, as if they were coming
+// in. Returns the would-be events, nothing is saved and no events are output.
+//
+// Scores are based on the history values before each metric, index scores
+// start over from the range start. Alerter options like notify intervals are
+// not applied, an event is the rule hit by a metric.
+func (d *Detector) Replay(rule *models.Rule, opts *ReplayOptions) (*ReplayResult, error) {
+	cfg := d.config().Copy()
+	if opts.TrendingFactor != 0 {
+		if opts.TrendingFactor < 0 || opts.TrendingFactor >= 1 {
+			return nil, ErrReplayTrendingFactor
+		}
+		cfg.Detector.TrendingFactor = opts.TrendingFactor
+	}
+	if opts.FilterTimes != 0 {
+		if opts.FilterTimes < 0 || uint32(opts.FilterTimes) > cfg.Expiration/cfg.Period {
+			return nil, ErrReplayFilterTimes
+		}
+		cfg.Detector.FilterTimes = opts.FilterTimes
+	}
+	// Only raw metrics are replayed.
+	now := uint32(time.Now().Unix())
+	start, stop := opts.Start, opts.Stop
+	if stop == 0 || stop > now {
+		stop = now
+	}
+	if start+cfg.Expiration < now {
+		start = now - cfg.Expiration
+	}
+	if start >= stop {
+		return nil, ErrReplayRange
+	}
+	// Metrics matching the rule.
+	var idxs []*models.Index
+	for _, idx := range d.db.Index.Filter(rule.Pattern) {
+		if rule.MatchTags(idx.Tags) {
+			idxs = append(idxs, idx)
+		}
+	}
+	if len(idxs) > MaxReplayMetrics {
+		return nil, ErrReplayTooManyMetrics
+	}
+	if uint64(len(idxs))*uint64((stop-start)/cfg.Interval) > MaxReplayPoints {
+		return nil, ErrReplayTooManyPoints
+	}
+	// Replay
+	rd := &Detector{cfg: cfg, db: d.db, flt: d.flt, replay: true}
+	rules := []*models.Rule{rule}
+	days := make(map[string]int)
+	result := &ReplayResult{
+		NumMetrics: len(idxs),
+		Days:       make([]*ReplayDay, 0),
+		Events:     make([]*models.Event, 0),
+	}
+	for _, idx := range idxs {
+		ms, err := d.db.Metric.GetRaw(idx.Name, idx.Link, start, stop)
+		if err != nil {
+			return nil, err
+		}
+		var last *models.Index
+		// Settings are the same for metrics of an index.
+		settings := rd.matchSettings(&models.Metric{Name: idx.Name, Tags: idx.Tags})
+		for _, m := range ms {
+			m.Name = idx.Name
+			m.Tags = idx.Tags
+			m.Link = idx.Link
+			vals, err := rd.values(m, settings.fz)
+			if err != nil {
+				return nil, err
+			}
+			rd.score(m, vals, rd.algorithm(m, rules))
+			last = rd.nextIdx(last, m)
+			result.NumPoints++
+			if result.NumPoints > MaxReplayPoints {
+				// More points than the interval.
+				return nil, ErrReplayTooManyPoints
+			}
+			if !rule.Test(m, last, settings.max, settings.min) {
+				continue
+			}
+			result.NumEvents++
+			days[time.Unix(int64(m.Stamp), 0).UTC().Format("2006-01-02")]++
+			if len(result.Events) < opts.Limit {
+				m.TestedRules = rules
+				ev := models.NewEvent(m, last)
+				ev.Rule = rule
+				result.Events = append(result.Events, ev)
+			}
+		}
+	}
+	for day, n := range days {
+		result.Days = append(result.Days, &ReplayDay{day, n})
+	}
+	sort.Sort(replayDaysByDay(result.Days))
+	return result, nil
+}
+
+// replayDaysByDay implements sort.Interface.
+type replayDaysByDay []*ReplayDay
+
+func (b replayDaysByDay) Len() int           { return len(b) }
+func (b replayDaysByDay) Less(i, j int) bool { return b[i].Day < b[j].Day }
+func (b replayDaysByDay) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,70 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package detector
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/filter"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/util"
+)
+
+func TestReplay(t *testing.T) {
+	// Open db.
+	fileName := "detector_test"
+	cfg := config.New()
+	opts := &storage.Options{Interval: cfg.Interval, Period: cfg.Period, Expiration: cfg.Expiration}
+	db, err := storage.Open(fileName, opts)
+	util.Must(t, err == nil)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	flt := filter.New()
+	flt.Init(db)
+	d := New(cfg, db, flt)
+	// Import history of 2 days with a spike at the end.
+	now := uint32(time.Now().Unix())
+	start := now - 2*config.Day
+	step := 6 * cfg.Interval
+	n := 0
+	for stamp := start; stamp < now; stamp += step {
+		n++
+		v := float64(10 + n%3)
+		if stamp+step >= now {
+			v = 1000
+		}
+		util.Must(t, d.Import(&models.Metric{Name: "foo", Stamp: stamp, Value: v}) == nil)
+		util.Must(t, d.Import(&models.Metric{Name: "bar", Stamp: stamp, Value: 10}) == nil)
+	}
+	idx, _ := db.Index.Get("foo")
+	// Replay, a single spike is smoothed by the default trending factor.
+	rule := &models.Rule{Pattern: "foo", TrendUp: true}
+	result, err := d.Replay(rule, &ReplayOptions{Start: start, Limit: 10})
+	util.Must(t, err == nil)
+	util.Must(t, result.NumMetrics == 1 && result.NumEvents == 0)
+	// Alternate trending factor.
+	result, err = d.Replay(rule, &ReplayOptions{Start: start, TrendingFactor: 0.9, Limit: 10})
+	util.Must(t, err == nil)
+	util.Must(t, result.NumMetrics == 1)
+	util.Must(t, result.NumPoints == n)
+	util.Must(t, result.NumEvents == 1 && len(result.Events) == 1)
+	util.Must(t, len(result.Days) == 1 && result.Days[0].NumEvents == 1)
+	util.Must(t, result.Events[0].Metric.Value == 1000)
+	// Alternate thresholds.
+	rule = &models.Rule{Pattern: "*", ThresholdMax: 11}
+	result, err = d.Replay(rule, &ReplayOptions{Start: start})
+	util.Must(t, err == nil && result.NumMetrics == 2)
+	util.Must(t, result.NumEvents > 1 && len(result.Events) == 0)
+	// Invalid options.
+	_, err = d.Replay(rule, &ReplayOptions{TrendingFactor: 1})
+	util.Must(t, err == ErrReplayTrendingFactor)
+	_, err = d.Replay(rule, &ReplayOptions{Start: now + config.Day})
+	util.Must(t, err == ErrReplayRange)
+	// Nothing saved.
+	i, _ := db.Index.Get("foo")
+	util.Must(t, i.Equal(idx))
+}
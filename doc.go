@@ -52,7 +52,7 @@ Build
 Command Line
 
 Usage:
-	banshee [-c config] [-d] [-v]
+	banshee [-c config] [-d] [-v] [backup|restore file]
 
 Flags:
 	-c config
@@ -62,6 +62,22 @@ Flags:
 	-v
 		Show version.
 
+Commands:
+	backup file
+		Backup the whole storage of the running banshee to a tarball.
+	restore file
+		Restore a backup tarball to the storage path, banshee should be
+		stopped and the storage path should not exist.
+	import file
+		Import history metrics into the running banshee without alerting,
+		in csv ("name,stamp,value") if the file extension is ".csv",
+		otherwise in the detector's tcp protocol ("name stamp value").
+	replay rule-id|file
+		Replay a rule against the stored history of the running banshee,
+		and print the number of would-be events by day. The file is a
+		request of the replay api in json, to try a rule or alternate
+		detector options.
+
 Configuration
 
 See package config.
@@ -84,6 +100,50 @@ npm module statsd-banshee to statsd's banckends:
 	, bansheePort: 2015
 	}
 
+Graphite Integration
+
+Alternatively, set detector.graphite_port (i.e. 2003) in config, and point
+the existing graphite/carbon relays to banshee, the graphite plaintext
+protocol is accepted over both tcp and udp.
+
+Cluster
+
+To scale out detection, set cluster.nodes in config on each node. Metrics
+are consistently hashed by name to nodes, send them to the router on any
+node (cluster.router_port), which forwards each to the node owning it. The
+webapp on any node queries metrics from all nodes. Nodes should share the
+admin storage (storage.admin) for the same rules. See package router.
+
+Replication
+
+To fail over, run a replica with replication.role "replica" following the
+primary, whose replication.port is set. The replica keeps a copy of the
+storage, promote it via the webapp once the primary is down. See package
+replication.
+
+Reload
+
+Send SIGHUP to reload the config file, or via the webapp, see package webapp.
+The new config is validated first and is rejected as a whole if invalid.
+These fields take effect without restarting, the others require a restart:
+
+	detector.blacklist
+	detector.interval_hit_limit
+	detector.default_threshold_maxs
+	detector.default_threshold_mins
+	detector.fill_blank_zeros
+	alerter.interval
+	alerter.one_day_limit
+	alerter.default_silent_time_range
+	webapp.language
+
+Detector Settings
+
+The blacklist, default thresholds and fill_blank_zeros patterns can be added
+at runtime via the webapp as well, which are stored in admin storage and take
+effect immediately, see package webapp. Patterns are matched the same way as
+rule patterns, with the metric names without tags.
+
 Migrate from bell
 
 Require bell.js v2.0+ and banshee v0.0.7+:
@@ -29,6 +29,17 @@ For example:
 Use suffix tree can find all matched rules at same time, it's far fast than match the rule
 one by one.
 
+Patterns
+
+Besides the single segment wildcard '*', a rule pattern may contain:
+
+	**              any number of segments, e.g. timer.**.upper
+	api_*_latency   wildcards within a segment
+	{get,set}       alternatives within a segment, e.g. timer.{get,set}.count
+
+Literal words are kept apart from wildcard words in each node, so metrics only
+walk through the wildcard words when they can't be found directly.
+
 Filter add and del rules by chan.
 
 A rule in the filter can be hit by metric for the intervalHitLimit times in an interval at
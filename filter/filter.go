@@ -15,6 +15,7 @@ import (
 	"github.com/eleme/banshee/storage"
 	"github.com/eleme/banshee/util/log"
 	"github.com/eleme/banshee/util/safemap"
+	"github.com/eleme/banshee/util/wildcard"
 )
 
 // Filter is to filter metrics by rules.
@@ -22,19 +23,30 @@ type Filter struct {
 	// Rule changes
 	addRuleCh chan *models.Rule
 	delRuleCh chan *models.Rule
-	// Children
-	children    *safemap.SafeMap
+	// Suffix tree
+	root        *childFilter
 	hitCounters *safemap.SafeMap
+	// Suffix tree of detector settings, replaced on loading.
+	settingsRoot *childFilter
+	settingsLock sync.RWMutex
+	// Detector settings in config, and the lock for loading.
+	cfgSettings []*models.DetectorSetting
+	loadLock    sync.Mutex
+	// Admin storage syncing, to reload detector settings.
+	syncCh chan struct{}
 	// Limit for a rule hits in an interval time
-	intervalHitLimit int
+	intervalHitLimit int32
 	enableHitLimit   bool
 }
 
-// childFilter is a suffix tree.
+// childFilter is a suffix tree, words with wildcards are stored apart from
+// the plain words.
 type childFilter struct {
 	lock         *sync.RWMutex
 	matchedRules []*models.Rule
+	settings     []*models.DetectorSetting
 	children     *safemap.SafeMap
+	wildcards    *safemap.SafeMap
 }
 
 // Limit for buffered changed rules
@@ -45,7 +57,9 @@ func New() *Filter {
 	return &Filter{
 		addRuleCh:        make(chan *models.Rule, bufferedChangedRulesLimit),
 		delRuleCh:        make(chan *models.Rule, bufferedChangedRulesLimit),
-		children:         safemap.New(),
+		syncCh:           make(chan struct{}, 1),
+		root:             newChildFilter(),
+		settingsRoot:     newChildFilter(),
 		hitCounters:      safemap.New(),
 		intervalHitLimit: 100,
 		enableHitLimit:   true,
@@ -70,6 +84,9 @@ func (f *Filter) Init(db *storage.DB) {
 	db.Admin.RulesCache.OnDel(f.delRuleCh)
 	go f.addRules()
 	go f.delRules()
+	// Reload detector settings changed by other instances.
+	db.Admin.OnSync(f.syncCh)
+	go f.syncSettings(db)
 	// Add rules from cache
 	rules := db.Admin.RulesCache.All()
 	for _, rule := range rules {
@@ -79,7 +96,7 @@ func (f *Filter) Init(db *storage.DB) {
 
 // SetHitLimit start to clear hitCounters by Interval
 func (f *Filter) SetHitLimit(cfg *config.Config) {
-	f.intervalHitLimit = cfg.Detector.IntervalHitLimit
+	f.SetIntervalHitLimit(cfg.Detector.IntervalHitLimit)
 	// Start to check number of matched metrics
 	ticker := time.NewTicker(time.Second * time.Duration(cfg.Interval))
 	go func() {
@@ -89,107 +106,153 @@ func (f *Filter) SetHitLimit(cfg *config.Config) {
 	}()
 }
 
+// SetIntervalHitLimit sets the limit for a rule hits in an interval, safe to
+// call while running.
+func (f *Filter) SetIntervalHitLimit(n int) {
+	atomic.StoreInt32(&f.intervalHitLimit, int32(n))
+}
+
 // newChildCache creates a new childCache
 func newChildFilter() *childFilter {
 	return &childFilter{
 		lock:         &sync.RWMutex{},
 		matchedRules: []*models.Rule{},
 		children:     nil,
+		wildcards:    nil,
 	}
 }
 
-// MatchedRules checks if a metric hit, l is the unchecked words list of the metric in order
-func (f *Filter) matchedRs(c *childFilter, prefix string, l []string) []*models.Rule {
-	// when len(l)==0 means all words are checked and passed, return all matched rules
-	if len(l) == 0 {
-		v, exist := f.hitCounters.Get(prefix)
-		if exist {
-			//use atomic
-			atomic.AddInt32(v.(*int32), 1)
-			if f.enableHitLimit && atomic.LoadInt32(v.(*int32)) > int32(f.intervalHitLimit) {
-				log.Warnf("hits over intervalHitLimit, metric: %s", prefix)
-				return []*models.Rule{}
-			}
-		} else {
-			var counter int32 = 1
-			f.hitCounters.Set(prefix, &counter)
+// matchedRs returns the rules of a matched node, the prefix is the metric
+// name checked.
+func (f *Filter) matchedRs(c *childFilter, prefix string) []*models.Rule {
+	v, exist := f.hitCounters.Get(prefix)
+	if exist {
+		//use atomic
+		atomic.AddInt32(v.(*int32), 1)
+		if f.enableHitLimit && atomic.LoadInt32(v.(*int32)) > atomic.LoadInt32(&f.intervalHitLimit) {
+			log.Warnf("hits over intervalHitLimit, metric: %s", prefix)
+			return []*models.Rule{}
 		}
-		c.lock.RLock()
-		defer c.lock.RUnlock()
-		return c.matchedRules
+	} else {
+		var counter int32 = 1
+		f.hitCounters.Set(prefix, &counter)
 	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.matchedRules
+}
 
-	rules := []*models.Rule{}
-	//when next level is nil,return empty rules slice
-	if c.children == nil {
-		return rules
+// match appends the nodes matching the unchecked words list l of a metric in
+// order to nodes.
+func (c *childFilter) match(l []string, nodes []*childFilter) []*childFilter {
+	c.lock.RLock()
+	children, wildcards := c.children, c.wildcards
+	c.lock.RUnlock()
+	//"**" node matches any number of words, including none
+	if wildcards != nil {
+		if v, exist := wildcards.Get(wildcard.Any); exist {
+			ch := v.(*childFilter)
+			for i := 0; i <= len(l); i++ {
+				nodes = ch.match(l[i:], nodes)
+			}
+		}
 	}
-	//check if this level has a "*" node
-	v, exist := c.children.Get("*")
-	if exist {
-		//when has a "*" node, the suffix tree matched the metric words by now, so goto next
-		// level and append matched rules to slice
-		ch := v.(*childFilter)
-		rules = append(rules, f.matchedRs(ch, prefix+"."+l[0], l[1:])...)
+	// when len(l)==0 means all words are checked and passed
+	if len(l) == 0 {
+		return append(nodes, c)
 	}
 	//check if this level has a same word node
-	v, exist = c.children.Get(l[0])
-	if exist {
-		//when has the node, matched by now, goto next level and append matched rules to slice
-		ch := v.(*childFilter)
-		rules = append(rules, f.matchedRs(ch, prefix+"."+l[0], l[1:])...)
+	if children != nil {
+		if v, exist := children.Get(l[0]); exist {
+			nodes = v.(*childFilter).match(l[1:], nodes)
+		}
 	}
-	//no matched node return empty rules slice, else return all matched rules
-	return rules
+	//check if this level has wildcard nodes matching the word
+	if wildcards != nil {
+		for k, v := range wildcards.Items() {
+			word := k.(string)
+			if word != wildcard.Any && wildcard.MatchSegment(word, l[0]) {
+				nodes = v.(*childFilter).match(l[1:], nodes)
+			}
+		}
+	}
+	return nodes
 }
 
-// MatchedRules checks if a metric hit the hitCache, if hit return all hit rules
+// MatchedRules checks if a metric hit the hitCache, if hit return all hit
+// rules, rule patterns are matched with the metric name without tags, and rule
+// tag selectors with the metric tags.
 func (f *Filter) MatchedRules(m *models.Metric) []*models.Rule {
+	return f.matchRules(m, true)
+}
+
+// Rules returns all rules matching a metric, the same as MatchedRules but
+// the hits are neither counted nor limited.
+func (f *Filter) Rules(m *models.Metric) []*models.Rule {
+	return f.matchRules(m, false)
+}
+
+// matchRules returns the rules matching a metric, hits are counted and
+// limited if hit is true.
+func (f *Filter) matchRules(m *models.Metric, hit bool) []*models.Rule {
 	//split the metric into ordered words
 	rules := []*models.Rule{}
-	l := strings.Split(m.Name, ".")
-	//check if root of the rules suffix tree has a "*" node
-	v, exist := f.children.Get("*")
-	if exist {
-		//when root has a "*" node, goto next level
-		ch := v.(*childFilter)
-		rules = append(rules, f.matchedRs(ch, l[0], l[1:])...)
-	}
-	//check if root of the rules suffix tree has the same node to the first word of the metric
-	v, exist = f.children.Get(l[0])
-	if exist {
-		//when has the same word node, goto next level
-		ch := v.(*childFilter)
-		rules = append(rules, f.matchedRs(ch, l[0], l[1:])...)
+	l := strings.Split(m.BaseName(), ".")
+	//a node may be matched more than once via "**"
+	seen := make(map[*childFilter]bool)
+	for _, c := range f.root.match(l, nil) {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		var rs []*models.Rule
+		if hit {
+			rs = f.matchedRs(c, m.Name)
+		} else {
+			c.lock.RLock()
+			rs = c.matchedRules
+			c.lock.RUnlock()
+		}
+		for _, rule := range rs {
+			if rule.MatchTags(m.Tags) {
+				rules = append(rules, rule)
+			}
+		}
 	}
 	return rules
 }
 
+// child returns the child node of the word, creates one if create is true.
+func (c *childFilter) child(word string, create bool) (*childFilter, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	children := &c.children
+	if !wildcard.IsLiteral(word) {
+		children = &c.wildcards
+	}
+	if *children == nil {
+		if !create {
+			return nil, false
+		}
+		*children = safemap.New()
+	}
+	if v, exist := (*children).Get(word); exist {
+		return v.(*childFilter), true
+	}
+	if !create {
+		return nil, false
+	}
+	ch := newChildFilter()
+	(*children).Set(word, ch)
+	return ch, true
+}
+
 // addRule add a rule to the suffix tree
 func (f *Filter) addRule(rule *models.Rule) {
-	//split the rule.Pattern into ordered words
-	l := strings.Split(rule.Pattern, ".")
-	//if suffix tree root do not has the same node, add it
-	if !f.children.Has(l[0]) {
-		f.children.Set(l[0], newChildFilter())
-	}
 	//check if suffix has the same word of the pattern by level step, if not add it
-	v, _ := f.children.Get(l[0])
-	ch := v.(*childFilter)
-	l = l[1:]
-	for len(l) > 0 {
-		if ch.children == nil {
-			ch.children = safemap.New()
-		}
-		if ch.children.Has(l[0]) {
-			v, _ = ch.children.Get(l[0])
-			ch = v.(*childFilter)
-		} else {
-			ch.children.Set(l[0], newChildFilter())
-			v, _ = ch.children.Get(l[0])
-			ch = v.(*childFilter)
-		}
-		l = l[1:]
+	ch := f.root
+	for _, word := range strings.Split(rule.Pattern, ".") {
+		ch, _ = ch.child(word, true)
 	}
 	ch.lock.Lock()
 	defer ch.lock.Unlock()
@@ -198,29 +261,17 @@ func (f *Filter) addRule(rule *models.Rule) {
 
 // delRule delete a rule from the suffix tree
 func (f *Filter) delRule(rule *models.Rule) {
-	l := strings.Split(rule.Pattern, ".")
-	if !f.children.Has(l[0]) {
-		return
-	}
-	v, _ := f.children.Get(l[0])
-	ch := v.(*childFilter)
-	l = l[1:]
-	for len(l) > 0 {
-		if ch.children == nil {
-			return
-		}
-		if ch.children.Has(l[0]) {
-			v, _ = ch.children.Get(l[0])
-			ch = v.(*childFilter)
-		} else {
+	ch := f.root
+	for _, word := range strings.Split(rule.Pattern, ".") {
+		var exist bool
+		if ch, exist = ch.child(word, false); !exist {
 			return
 		}
-		l = l[1:]
 	}
 	ch.lock.Lock()
 	defer ch.lock.Unlock()
 	rules := []*models.Rule{}
-	for _, r := range v.(*childFilter).matchedRules {
+	for _, r := range ch.matchedRules {
 		if !rule.Equal(r) {
 			rules = append(rules, r)
 		}
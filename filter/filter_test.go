@@ -5,9 +5,12 @@ package filter
 import (
 	"github.com/eleme/banshee/config"
 	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage"
 	"github.com/eleme/banshee/util"
 	"github.com/eleme/banshee/util/log"
+	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -35,6 +38,55 @@ func TestSimple(t *testing.T) {
 	util.Must(t, 2 == len(rules4))
 }
 
+func TestWildcards(t *testing.T) {
+	// New and add rules.
+	filter := New()
+	rule1 := &models.Rule{Pattern: "a.**.d"}
+	rule2 := &models.Rule{Pattern: "a.api_*_latency.{p99,p95}"}
+	rule3 := &models.Rule{Pattern: "**"}
+	filter.addRule(rule1)
+	filter.addRule(rule2)
+	filter.DisableHitLimit()
+	// Test
+	util.Must(t, 0 == len(filter.MatchedRules(&models.Metric{Name: "b.d"})))
+	rules := filter.MatchedRules(&models.Metric{Name: "a.d"})
+	util.Must(t, 1 == len(rules) && rules[0] == rule1)
+	rules = filter.MatchedRules(&models.Metric{Name: "a.b.c.d"})
+	util.Must(t, 1 == len(rules) && rules[0] == rule1)
+	rules = filter.MatchedRules(&models.Metric{Name: "a.api_get_latency.p99"})
+	util.Must(t, 1 == len(rules) && rules[0] == rule2)
+	util.Must(t, 0 == len(filter.MatchedRules(&models.Metric{Name: "a.api_get_latency.p50"})))
+	util.Must(t, 0 == len(filter.MatchedRules(&models.Metric{Name: "a.api_get_count.p99"})))
+	// Matched once although via different paths.
+	filter.addRule(rule3)
+	rules = filter.MatchedRules(&models.Metric{Name: "a.d.d.d"})
+	util.Must(t, 2 == len(rules))
+	// Delete
+	filter.delRule(rule1)
+	rules = filter.MatchedRules(&models.Metric{Name: "a.b.c.d"})
+	util.Must(t, 1 == len(rules) && rules[0] == rule3)
+}
+
+func TestTags(t *testing.T) {
+	// New and add rules.
+	filter := New()
+	rule1 := &models.Rule{Pattern: "cpu.*"}
+	rule2 := &models.Rule{Pattern: "cpu.usage", TagSelectors: "host=web*"}
+	filter.addRule(rule1)
+	filter.addRule(rule2)
+	filter.DisableHitLimit()
+	// Test
+	m := &models.Metric{Name: "cpu.usage;host=web1;region=us"}
+	util.Must(t, m.NormalizeTags() == nil)
+	util.Must(t, 2 == len(filter.MatchedRules(m)))
+	m = &models.Metric{Name: "cpu.usage", Tags: map[string]string{"host": "db1"}}
+	util.Must(t, m.NormalizeTags() == nil)
+	rules := filter.MatchedRules(m)
+	util.Must(t, 1 == len(rules) && rules[0] == rule1)
+	rules = filter.MatchedRules(&models.Metric{Name: "cpu.usage"})
+	util.Must(t, 1 == len(rules) && rules[0] == rule1)
+}
+
 func TestHitLimit(t *testing.T) {
 	// Currently disable logging
 	log.Disable()
@@ -62,10 +114,54 @@ func TestHitLimit(t *testing.T) {
 	util.Must(t, 1 == len(rules))
 }
 
+func TestSettings(t *testing.T) {
+	filter := New()
+	cfg := config.New()
+	cfg.Detector.BlackList = []string{"statsd.**"}
+	cfg.Detector.DefaultThresholdMaxs["counter.*"] = 10
+	s1 := &models.DetectorSetting{ID: 1, Kind: models.DetectorSettingThresholdMax, Pattern: "counter.*", Value: 20}
+	s2 := &models.DetectorSetting{ID: 2, Kind: models.DetectorSettingFillBlankZeros, Pattern: "counter.{foo,bar}"}
+	filter.SetSettings(append([]*models.DetectorSetting{s1, s2}, configSettings(cfg)...))
+	// Test
+	util.Must(t, 0 == len(filter.MatchedSettings(&models.Metric{Name: "timer.foo"})))
+	settings := filter.MatchedSettings(&models.Metric{Name: "statsd.a.b"})
+	util.Must(t, 1 == len(settings) && settings[0].Kind == models.DetectorSettingBlackList)
+	// Settings in db first, latest first.
+	settings = filter.MatchedSettings(&models.Metric{Name: "counter.foo", Tags: map[string]string{"host": "a"}})
+	util.Must(t, 3 == len(settings))
+	util.Must(t, settings[0] == s2 && settings[1] == s1)
+	util.Must(t, settings[2].ID == 0 && settings[2].Value == 10)
+	// Replace
+	filter.SetSettings(nil)
+	util.Must(t, 0 == len(filter.MatchedSettings(&models.Metric{Name: "statsd.a.b"})))
+}
+
+func TestSyncSettings(t *testing.T) {
+	fileName := "filter_test"
+	db, err := storage.Open(fileName, nil)
+	util.Must(t, err == nil)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	filter := New()
+	filter.Init(db)
+	util.Must(t, filter.LoadSettings(db, config.New()) == nil)
+	// Created by another instance.
+	s := &models.DetectorSetting{Kind: models.DetectorSettingBlackList, Pattern: "statsd.**"}
+	util.Must(t, db.Admin.DB().Create(s).Error == nil)
+	m := &models.Metric{Name: "statsd.a"}
+	util.Must(t, len(filter.MatchedSettings(m)) == 0)
+	// Reloaded on syncing.
+	filter.syncCh <- struct{}{}
+	for i := 0; i < 100 && len(filter.MatchedSettings(m)) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	util.Must(t, len(filter.MatchedSettings(m)) == 1)
+}
+
 func BenchmarkRules1KNativeBest(b *testing.B) {
 	var rules []*models.Rule
 	for i := 0; i < 1024; i++ {
-		rules = append(rules, &models.Rule{Pattern: "a.*.c." + string(i)})
+		rules = append(rules, &models.Rule{Pattern: "a.*.c." + strconv.Itoa(i)})
 	}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -78,38 +174,38 @@ func BenchmarkRules1KNativeBest(b *testing.B) {
 func BenchmarkRules1kBest(b *testing.B) {
 	filter := New()
 	for i := 0; i < 1024; i++ {
-		filter.addRule(&models.Rule{Pattern: "a.*.c." + string(i)})
+		filter.addRule(&models.Rule{Pattern: "a.*.c." + strconv.Itoa(i)})
 	}
 	filter.DisableHitLimit()
 	defer filter.EnableHitLimit()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		filter.MatchedRules(&models.Metric{Name: "x.b.c." + string(i&1024)})
+		filter.MatchedRules(&models.Metric{Name: "x.b.c." + strconv.Itoa(i&1024)})
 	}
 }
 
 func BenchmarkRules1kWorst(b *testing.B) {
 	filter := New()
 	for i := 0; i < 1024; i++ {
-		filter.addRule(&models.Rule{Pattern: "a.*.c." + string(i)})
+		filter.addRule(&models.Rule{Pattern: "a.*.c." + strconv.Itoa(i)})
 	}
 	filter.DisableHitLimit()
 	defer filter.EnableHitLimit()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		filter.MatchedRules(&models.Metric{Name: "a.b.c." + string(i&1024)})
+		filter.MatchedRules(&models.Metric{Name: "a.b.c." + strconv.Itoa(i&1024)})
 	}
 }
 
 func BenchmarkRules2kWorst(b *testing.B) {
 	filter := New()
 	for i := 0; i < 1024*2; i++ {
-		filter.addRule(&models.Rule{Pattern: "a.*.c." + string(i)})
+		filter.addRule(&models.Rule{Pattern: "a.*.c." + strconv.Itoa(i)})
 	}
 	filter.DisableHitLimit()
 	defer filter.EnableHitLimit()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		filter.MatchedRules(&models.Metric{Name: "a.b.c." + string(i&65535)})
+		filter.MatchedRules(&models.Metric{Name: "a.b.c." + strconv.Itoa(i&65535)})
 	}
 }
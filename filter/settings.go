@@ -0,0 +1,132 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package filter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/util/log"
+)
+
+// LoadSettings loads the detector settings in db and config into the
+// settings suffix tree, safe to call while running.
+func (f *Filter) LoadSettings(db *storage.DB, cfg *config.Config) error {
+	f.loadLock.Lock()
+	defer f.loadLock.Unlock()
+	cfgSettings := configSettings(cfg)
+	if err := f.loadSettings(db, cfgSettings); err != nil {
+		return err
+	}
+	f.cfgSettings = cfgSettings
+	return nil
+}
+
+// ReloadSettings reloads the detector settings in db into the settings
+// suffix tree, with the settings in config on last loading, safe to call
+// while running.
+func (f *Filter) ReloadSettings(db *storage.DB) error {
+	f.loadLock.Lock()
+	defer f.loadLock.Unlock()
+	return f.loadSettings(db, f.cfgSettings)
+}
+
+// syncSettings reloads the detector settings on each syncing of the admin
+// storage.
+func (f *Filter) syncSettings(db *storage.DB) {
+	for _ = range f.syncCh {
+		if err := f.ReloadSettings(db); err != nil {
+			log.Errorf("reload detector settings: %v", err)
+		}
+	}
+}
+
+// loadSettings loads the detector settings in db and the settings in config,
+// nothing is changed on error.
+func (f *Filter) loadSettings(db *storage.DB, cfgSettings []*models.DetectorSetting) error {
+	var l []models.DetectorSetting
+	if err := db.Admin.DB().Find(&l).Error; err != nil {
+		return err
+	}
+	settings := make([]*models.DetectorSetting, 0, len(l))
+	for i := 0; i < len(l); i++ {
+		settings = append(settings, &l[i])
+	}
+	f.SetSettings(append(settings, cfgSettings...))
+	return nil
+}
+
+// SetSettings replaces the settings suffix tree with a new one built from
+// the settings, safe to call while running.
+func (f *Filter) SetSettings(settings []*models.DetectorSetting) {
+	root := newChildFilter()
+	for _, s := range settings {
+		ch := root
+		for _, word := range strings.Split(s.Pattern, ".") {
+			ch, _ = ch.child(word, true)
+		}
+		ch.settings = append(ch.settings, s)
+	}
+	f.settingsLock.Lock()
+	defer f.settingsLock.Unlock()
+	f.settingsRoot = root
+}
+
+// MatchedSettings returns the detector settings matching a metric, patterns
+// are matched with the metric name without tags. The settings in db come
+// first, the latest created first, and then the ones in config.
+func (f *Filter) MatchedSettings(m *models.Metric) []*models.DetectorSetting {
+	f.settingsLock.RLock()
+	root := f.settingsRoot
+	f.settingsLock.RUnlock()
+	settings := []*models.DetectorSetting{}
+	l := strings.Split(m.BaseName(), ".")
+	//a node may be matched more than once via "**"
+	seen := make(map[*childFilter]bool)
+	for _, c := range root.match(l, nil) {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		settings = append(settings, c.settings...)
+	}
+	if len(settings) > 1 {
+		sort.Stable(settingsByID(settings))
+	}
+	return settings
+}
+
+// settingsByID sorts settings by id in descending order.
+type settingsByID []*models.DetectorSetting
+
+func (l settingsByID) Len() int           { return len(l) }
+func (l settingsByID) Less(i, j int) bool { return l[i].ID > l[j].ID }
+func (l settingsByID) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// configSettings returns the detector settings in config.
+func configSettings(cfg *config.Config) []*models.DetectorSetting {
+	var settings []*models.DetectorSetting
+	add := func(kind string, patterns []string, values map[string]float64) {
+		for _, p := range patterns {
+			settings = append(settings, &models.DetectorSetting{Kind: kind, Pattern: p, Value: values[p]})
+		}
+	}
+	add(models.DetectorSettingBlackList, cfg.Detector.BlackList, nil)
+	add(models.DetectorSettingThresholdMax, sortedKeys(cfg.Detector.DefaultThresholdMaxs), cfg.Detector.DefaultThresholdMaxs)
+	add(models.DetectorSettingThresholdMin, sortedKeys(cfg.Detector.DefaultThresholdMins), cfg.Detector.DefaultThresholdMins)
+	add(models.DetectorSettingFillBlankZeros, cfg.Detector.FillBlankZeros, nil)
+	return settings
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -13,6 +13,26 @@ Package health implements the health statistic aggregation.
 	numMetricIncomed              // Number of metrics incomed in last interval.
 	numMetricDetected             // Number of metrics detected in last interval.
 	numAlertingEvents             // Number of alerting events in last interval.
+	numDroppedLines               // Number of input lines dropped as the detector queue is full in last interval.
+	numDroppedEvents              // Number of alerting events dropped as the alerter is busy in last interval.
+
+Prometheus
+
+Statistics are also exported in prometheus text format via WritePrometheus,
+counters and histograms are cumulative and never reset:
+
+	banshee_clients                  // gauge
+	banshee_indexes                  // gauge
+	banshee_rules                    // gauge
+	banshee_metrics_incomed_total    // counter
+	banshee_metrics_detected_total   // counter
+	banshee_alerting_events_total    // counter
+	banshee_lines_dropped_total      // counter
+	banshee_events_dropped_total     // counter
+	banshee_rule_hits_total          // counter, by rule_id and pattern
+	banshee_detection_cost_seconds   // histogram
+	banshee_filter_cost_seconds      // histogram
+	banshee_query_cost_seconds       // histogram
 
 */
 package health
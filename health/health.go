@@ -37,6 +37,8 @@ type Info struct {
 	NumMetricIncomed  int64   `json:"numMetricIncomed"`
 	NumMetricDetected int64   `json:"numMetricDetected"`
 	NumAlertingEvents int64   `json:"numAlertingEvents"`
+	NumDroppedLines   int64   `json:"numDroppedLines"`
+	NumDroppedEvents  int64   `json:"numDroppedEvents"`
 }
 
 // Copy info.
@@ -54,6 +56,8 @@ func (info *Info) copy() *Info {
 		NumMetricIncomed:    info.NumMetricIncomed,
 		NumMetricDetected:   info.NumMetricDetected,
 		NumAlertingEvents:   info.NumAlertingEvents,
+		NumDroppedLines:     info.NumDroppedLines,
+		NumDroppedEvents:    info.NumDroppedEvents,
 	}
 }
 
@@ -75,6 +79,8 @@ type hub struct {
 	numMetricIncomed   int64
 	numMetricDetected  int64
 	numAlertingEvents  int64
+	numDroppedLines    int64
+	numDroppedEvents   int64
 }
 
 // Single-ton hub.
@@ -102,6 +108,7 @@ func DecrNumClients(n int64) {
 
 // AddDetectionCost appends cost to DetectionCosts.
 func AddDetectionCost(n float64) {
+	ph.detectionCost.observe(n / 1000)
 	h.detectionCostsLock.Lock()
 	defer h.detectionCostsLock.Unlock()
 	if len(h.detectionCosts) < maxDetectionCostsLen {
@@ -111,6 +118,7 @@ func AddDetectionCost(n float64) {
 
 // AddFilterCost appends cost to FilterCosts.
 func AddFilterCost(n float64) {
+	ph.filterCost.observe(n / 1000)
 	h.filterCostsLock.Lock()
 	defer h.filterCostsLock.Unlock()
 	if len(h.filterCosts) < maxFilterCostsLen {
@@ -120,6 +128,7 @@ func AddFilterCost(n float64) {
 
 // AddQueryCost appends cost to QueryCosts.
 func AddQueryCost(n float64) {
+	ph.queryCost.observe(n / 1000)
 	h.queryCostsLock.Lock()
 	defer h.queryCostsLock.Unlock()
 	if len(h.queryCosts) < maxQueryCostsLen {
@@ -129,19 +138,34 @@ func AddQueryCost(n float64) {
 
 // IncrNumMetricIncomed increments NumMetricIncomed by n.
 func IncrNumMetricIncomed(n int64) {
+	atomic.AddInt64(&ph.numMetricIncomed, n)
 	atomic.AddInt64(&h.numMetricIncomed, n)
 }
 
 // IncrNumMetricDetected increments NumMetricDetected by n.
 func IncrNumMetricDetected(n int64) {
+	atomic.AddInt64(&ph.numMetricDetected, n)
 	atomic.AddInt64(&h.numMetricDetected, n)
 }
 
 // IncrNumAlertingEvents increments NumAlertingsEvents by n.
 func IncrNumAlertingEvents(n int64) {
+	atomic.AddInt64(&ph.numAlertingEvents, n)
 	atomic.AddInt64(&h.numAlertingEvents, n)
 }
 
+// IncrNumDroppedLines increments NumDroppedLines by n.
+func IncrNumDroppedLines(n int64) {
+	atomic.AddInt64(&ph.numDroppedLines, n)
+	atomic.AddInt64(&h.numDroppedLines, n)
+}
+
+// IncrNumDroppedEvents increments NumDroppedEvents by n.
+func IncrNumDroppedEvents(n int64) {
+	atomic.AddInt64(&ph.numDroppedEvents, n)
+	atomic.AddInt64(&h.numDroppedEvents, n)
+}
+
 // Refresh NumIndexTotal.
 func refreshNumIndexTotal() {
 	h.info.lock.Lock()
@@ -217,6 +241,22 @@ func aggregateNumAlertingEvents() {
 	atomic.StoreInt64(&h.numAlertingEvents, 0)
 }
 
+// Aggregate NumDroppedLines.
+func aggregateNumDroppedLines() {
+	h.info.lock.Lock()
+	defer h.info.lock.Unlock()
+	h.info.NumDroppedLines = atomic.LoadInt64(&h.numDroppedLines)
+	atomic.StoreInt64(&h.numDroppedLines, 0)
+}
+
+// Aggregate NumDroppedEvents.
+func aggregateNumDroppedEvents() {
+	h.info.lock.Lock()
+	defer h.info.lock.Unlock()
+	h.info.NumDroppedEvents = atomic.LoadInt64(&h.numDroppedEvents)
+	atomic.StoreInt64(&h.numDroppedEvents, 0)
+}
+
 // Start the health aggregator.
 func Start() {
 	interval := time.Duration(AggregationInterval) * time.Second
@@ -230,6 +270,8 @@ func Start() {
 		aggregateNumMetricIncomed()
 		aggregateNumMetricDetected()
 		aggregateNumAlertingEvents()
+		aggregateNumDroppedLines()
+		aggregateNumDroppedEvents()
 		aggregationFilterCost()
 		aggregationQueryCost()
 	}
@@ -0,0 +1,162 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package health
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/eleme/banshee/util/safemap"
+)
+
+// Buckets in seconds for cost histograms.
+var costBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1}
+
+// histogram is a minimal prometheus like histogram.
+type histogram struct {
+	lock    sync.Mutex
+	buckets []float64 // upper bounds
+	counts  []uint64  // not cumulative
+	count   uint64
+	sum     float64
+}
+
+// newHistogram creates a histogram with upper bounds.
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// observe adds a value to the histogram.
+func (hg *histogram) observe(v float64) {
+	hg.lock.Lock()
+	defer hg.lock.Unlock()
+	i := sort.SearchFloat64s(hg.buckets, v)
+	if i < len(hg.buckets) {
+		hg.counts[i]++
+	}
+	hg.count++
+	hg.sum += v
+}
+
+// write writes the histogram in prometheus text format.
+func (hg *histogram) write(w io.Writer, name, help string) {
+	hg.lock.Lock()
+	defer hg.lock.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	var n uint64
+	for i, le := range hg.buckets {
+		n += hg.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(le), n)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, hg.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(hg.sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, hg.count)
+}
+
+// Prometheus counters and histograms, never reset.
+type promHub struct {
+	numMetricIncomed  int64
+	numMetricDetected int64
+	numAlertingEvents int64
+	numDroppedLines   int64
+	numDroppedEvents  int64
+	detectionCost     *histogram
+	filterCost        *histogram
+	queryCost         *histogram
+	ruleHits          *safemap.SafeMap // rule id => *int64
+	ruleHitsLock      sync.Mutex       // protects ruleHits creation
+}
+
+// Single-ton prometheus hub.
+var ph = promHub{
+	detectionCost: newHistogram(costBuckets),
+	filterCost:    newHistogram(costBuckets),
+	queryCost:     newHistogram(costBuckets),
+	ruleHits:      safemap.New(),
+}
+
+// IncrRuleHits increments the number of metrics hit the rule by n.
+func IncrRuleHits(id int, n int64) {
+	v, ok := ph.ruleHits.Get(id)
+	if !ok {
+		ph.ruleHitsLock.Lock()
+		if v, ok = ph.ruleHits.Get(id); !ok {
+			v = new(int64)
+			ph.ruleHits.Set(id, v)
+		}
+		ph.ruleHitsLock.Unlock()
+	}
+	atomic.AddInt64(v.(*int64), n)
+}
+
+// formatFloat formats float64 value for prometheus.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeLabel escapes label value for prometheus.
+func escapeLabel(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return strings.Replace(s, `"`, `\"`, -1)
+}
+
+// writeMetric writes a single counter or gauge in prometheus text format.
+func writeMetric(w io.Writer, name, typ, help string, v int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+	fmt.Fprintf(w, "%s %d\n", name, v)
+}
+
+// writeRuleHits writes rule hits counters, rules deleted are skipped.
+func writeRuleHits(w io.Writer) {
+	name := "banshee_rule_hits_total"
+	fmt.Fprintf(w, "# HELP %s Number of metrics hit the rule.\n", name)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	var ids []int
+	items := ph.ruleHits.Items()
+	for k := range items {
+		ids = append(ids, k.(int))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		var pattern string
+		if h.db != nil {
+			rule, ok := h.db.Admin.RulesCache.Get(id)
+			if !ok {
+				continue
+			}
+			pattern = rule.Pattern
+		}
+		n := atomic.LoadInt64(items[id].(*int64))
+		fmt.Fprintf(w, "%s{rule_id=\"%d\",pattern=\"%s\"} %d\n", name, id, escapeLabel(pattern), n)
+	}
+}
+
+// WritePrometheus writes health statistics in prometheus text exposition
+// format.
+func WritePrometheus(w io.Writer) {
+	// Gauges
+	writeMetric(w, "banshee_clients", "gauge", "Number of detector clients.", atomic.LoadInt64(&h.numClients))
+	if h.db != nil {
+		writeMetric(w, "banshee_indexes", "gauge", "Number of metric indexes total.", int64(h.db.Index.Len()))
+		writeMetric(w, "banshee_rules", "gauge", "Number of rules total.", int64(h.db.Admin.RulesCache.Len()))
+	}
+	// Counters
+	writeMetric(w, "banshee_metrics_incomed_total", "counter", "Number of metrics incomed.", atomic.LoadInt64(&ph.numMetricIncomed))
+	writeMetric(w, "banshee_metrics_detected_total", "counter", "Number of metrics detected.", atomic.LoadInt64(&ph.numMetricDetected))
+	writeMetric(w, "banshee_alerting_events_total", "counter", "Number of alerting events.", atomic.LoadInt64(&ph.numAlertingEvents))
+	writeMetric(w, "banshee_lines_dropped_total", "counter", "Number of input lines dropped.", atomic.LoadInt64(&ph.numDroppedLines))
+	writeMetric(w, "banshee_events_dropped_total", "counter", "Number of alerting events dropped.", atomic.LoadInt64(&ph.numDroppedEvents))
+	writeRuleHits(w)
+	// Histograms
+	ph.detectionCost.write(w, "banshee_detection_cost_seconds", "Time cost of detection.")
+	ph.filterCost.write(w, "banshee_filter_cost_seconds", "Time cost of filtering rules.")
+	ph.queryCost.write(w, "banshee_query_cost_seconds", "Time cost of querying history metrics.")
+}
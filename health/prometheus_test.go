@@ -0,0 +1,42 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package health
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/eleme/banshee/util"
+)
+
+func TestHistogram(t *testing.T) {
+	hg := newHistogram([]float64{1, 5})
+	hg.observe(0.5)
+	hg.observe(1)
+	hg.observe(3)
+	hg.observe(10)
+	var b bytes.Buffer
+	hg.write(&b, "foo", "Foo.")
+	s := b.String()
+	util.Must(t, strings.Contains(s, "# TYPE foo histogram\n"))
+	util.Must(t, strings.Contains(s, "foo_bucket{le=\"1\"} 2\n"))
+	util.Must(t, strings.Contains(s, "foo_bucket{le=\"5\"} 3\n"))
+	util.Must(t, strings.Contains(s, "foo_bucket{le=\"+Inf\"} 4\n"))
+	util.Must(t, strings.Contains(s, "foo_sum 14.5\n"))
+	util.Must(t, strings.Contains(s, "foo_count 4\n"))
+}
+
+func TestWritePrometheus(t *testing.T) {
+	IncrNumMetricIncomed(3)
+	IncrRuleHits(1, 2)
+	var b bytes.Buffer
+	WritePrometheus(&b)
+	s := b.String()
+	util.Must(t, strings.Contains(s, "banshee_metrics_incomed_total 3\n"))
+	util.Must(t, strings.Contains(s, "banshee_rule_hits_total{rule_id=\"1\",pattern=\"\"} 2\n"))
+}
+
+func TestEscapeLabel(t *testing.T) {
+	util.Must(t, escapeLabel(`a"b\c`) == `a\"b\\c`)
+}
@@ -8,12 +8,15 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/eleme/banshee/alerter"
 	"github.com/eleme/banshee/config"
 	"github.com/eleme/banshee/detector"
 	"github.com/eleme/banshee/filter"
 	"github.com/eleme/banshee/health"
+	"github.com/eleme/banshee/replication"
+	"github.com/eleme/banshee/router"
 	"github.com/eleme/banshee/storage"
 	"github.com/eleme/banshee/util/log"
 	"github.com/eleme/banshee/version"
@@ -32,7 +35,7 @@ var (
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: banshee [-c config] [-d] [-v]\n")
+	fmt.Fprintf(os.Stderr, "usage: banshee [-c config] [-d] [-v] [backup|restore|import|replay file]\n")
 	flag.PrintDefaults()
 	fmt.Fprintf(os.Stderr, "copyright eleme https://github.com/eleme/banshee.\n")
 	os.Exit(2)
@@ -49,10 +52,15 @@ func initLog() {
 	log.Debugf("banshee%s %s %d cpu", vers, goVs, nCPU)
 }
 
+// configSpecified returns true if a config file is specified.
+func configSpecified() bool {
+	// Case ./program [-d]
+	return !(flag.NFlag() == 0 || (flag.NFlag() == 1 && *debug))
+}
+
 func initConfig() {
 	// Config parsing.
-	if flag.NFlag() == 0 || (flag.NFlag() == 1 && *debug) {
-		// Case ./program [-d]
+	if !configSpecified() {
 		log.Warnf("no config specified, using default..")
 	} else {
 		// Update config.
@@ -66,6 +74,17 @@ func initConfig() {
 	if err != nil {
 		log.Fatalf("config: %s", err)
 	}
+	// Detector setting and algorithm patterns used to be matched by
+	// filepath.Match.
+	patterns := cfg.Detector.SettingPatterns()
+	for p := range cfg.Detector.Algorithms {
+		patterns = append(patterns, p)
+	}
+	for _, p := range patterns {
+		if strings.ContainsAny(strings.Replace(p, "**", "", -1), "*?[") {
+			log.Warnf("config: detector pattern %q is matched the same as rules now, \"*\" matches in a segment only, use \"**\" for any segments", p)
+		}
+	}
 }
 
 func initDB() {
@@ -74,7 +93,22 @@ func initDB() {
 		panic(errors.New("db require config"))
 	}
 	path := cfg.Storage.Path
-	opts := &storage.Options{Period: cfg.Period, Expiration: cfg.Expiration}
+	opts := &storage.Options{
+		Interval:   cfg.Interval,
+		Period:     cfg.Period,
+		Expiration: cfg.Expiration,
+		Admin: storage.AdminOptions{
+			Dialect:      cfg.Storage.Admin.Dialect,
+			DSN:          cfg.Storage.Admin.DSN,
+			SyncInterval: cfg.Storage.Admin.SyncInterval,
+		},
+	}
+	for _, rollup := range cfg.Storage.Rollups {
+		opts.Rollups = append(opts.Rollups, storage.RollupOptions{
+			Interval:   rollup.Interval,
+			Expiration: rollup.Expiration,
+		})
+	}
 	var err error
 	db, err = storage.Open(path, opts)
 	if err != nil {
@@ -90,6 +124,9 @@ func initFilter() {
 	// Init filter
 	flt.Init(db)
 	flt.SetHitLimit(cfg)
+	if err := flt.LoadSettings(db, cfg); err != nil {
+		log.Fatalf("failed to load detector settings: %v", err)
+	}
 }
 
 func init() {
@@ -103,6 +140,10 @@ func init() {
 	// Init
 	initLog()
 	initConfig()
+	if flag.NArg() > 0 {
+		// Case ./program [-c config] backup|restore|import|replay file
+		runCommand(flag.Args())
+	}
 	initDB()
 	initFilter()
 }
@@ -111,12 +152,32 @@ func main() {
 	health.Init(db)
 	go health.Start()
 
-	alerter := alerter.New(cfg, db)
-	alerter.Start()
+	rep := replication.New(cfg, db)
+	rep.Start()
 
-	go webapp.Start(cfg, db, flt)
+	alerter := alerter.New(cfg, db)
 
 	detector := detector.New(cfg, db, flt)
 	detector.Out(alerter.In)
+	detector.Replicate(rep)
+	if cfg.IsCluster() {
+		rt := router.New(cfg)
+		detector.Route(rt)
+		go rt.Start()
+	}
+
+	rl := &reloader{detector: detector, alerter: alerter}
+	webapp.SetConfigReloader(rl.reload)
+	go rl.watch()
+
+	go webapp.Start(cfg, db, flt, detector, rep)
+
+	// Alerter and detector are only active on primary.
+	<-rep.Promoted()
+	// Detector settings of a replica are from the primary.
+	if err := flt.ReloadSettings(db); err != nil {
+		log.Fatalf("failed to load detector settings: %v", err)
+	}
+	alerter.Start()
 	detector.Start()
 }
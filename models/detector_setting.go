@@ -0,0 +1,37 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package models
+
+// Detector setting kinds
+const (
+	// Refuse matched metrics.
+	DetectorSettingBlackList = "blacklist"
+	// Default thresholdMax for rules without thresholdMax.
+	DetectorSettingThresholdMax = "default_threshold_max"
+	// Default thresholdMin for rules without thresholdMin.
+	DetectorSettingThresholdMin = "default_threshold_min"
+	// Fill the blank gaps by zeros in detection.
+	DetectorSettingFillBlankZeros = "fill_blank_zeros"
+)
+
+// DetectorSetting is a detector setting for the metrics matching a pattern,
+// editable at runtime in addition to the detector settings in config:
+//
+//	// Refuse statsd metrics.
+//	&DetectorSetting{Kind: DetectorSettingBlackList, Pattern: "statsd.**"}
+//	// Trending up counters below 10 never trigger alerts for rules without
+//	// thresholdMax.
+//	&DetectorSetting{Kind: DetectorSettingThresholdMax, Pattern: "counter.*", Value: 10}
+//
+type DetectorSetting struct {
+	// ID in db, 0 for the settings in config.
+	ID int `gorm:"primary_key" json:"id"`
+	// Kind of the setting.
+	Kind string `sql:"size:32;index;not null" json:"kind"`
+	// Pattern is a wildcard string, same to rules.
+	Pattern string `sql:"size:400;not null" json:"pattern"`
+	// Threshold value, only for default thresholds.
+	Value float64 `json:"value"`
+	// Comment
+	Comment string `sql:"type:varchar(256)" json:"comment"`
+}
@@ -18,11 +18,45 @@ type Event struct {
 	Index                 *Index   `json:"index"`
 	Metric                *Metric  `json:"metric"`
 	RuleTranslatedComment string   `json:"ruleTranslatedComment"`
+	// Tags of the metric, e.g. the host or region anomalous.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Resolved events are sent once an alerting incident ends.
+	Resolved bool `json:"resolved"`
+	// Events grouped in a digest event, empty for a single event.
+	Digest []*Event `json:"digest,omitempty"`
 }
 
 // NewEvent returns a new event from metric and index.
 func NewEvent(m *Metric, idx *Index) *Event {
-	ev := &Event{Metric: m, Index: idx}
+	ev := &Event{Metric: m, Index: idx, Tags: m.Tags}
+	ev.generateID()
+	return ev
+}
+
+// NewResolvedEvent returns a new resolved event from metric and index for
+// the rules that the metric is back in bounds.
+func NewResolvedEvent(m *Metric, idx *Index, rules []*Rule) *Event {
+	rm := &Metric{}
+	*rm = *m
+	rm.TestedRules = rules
+	ev := &Event{Metric: rm, Index: idx, Tags: m.Tags, Resolved: true}
+	ev.generateID()
+	return ev
+}
+
+// NewDigestEvent returns a digest event of events grouped, with the project,
+// rule, metric and index of the first event.
+func NewDigestEvent(evs []*Event) *Event {
+	first := evs[0]
+	ev := &Event{
+		Project:               first.Project,
+		Rule:                  first.Rule,
+		Index:                 first.Index,
+		Metric:                first.Metric,
+		RuleTranslatedComment: first.RuleTranslatedComment,
+		Resolved:              first.Resolved,
+		Digest:                evs,
+	}
 	ev.generateID()
 	return ev
 }
@@ -30,28 +64,37 @@ func NewEvent(m *Metric, idx *Index) *Event {
 // generateID generates a sha1 string id for the event.
 func (ev *Event) generateID() {
 	slug := fmt.Sprintf("%s:%d", ev.Metric.Name, ev.Metric.Stamp)
+	if ev.Resolved {
+		slug += ":resolved"
+	}
+	if len(ev.Digest) > 0 {
+		slug += fmt.Sprintf(":digest:%d", len(ev.Digest))
+	}
 	hash := sha1.New()
 	hash.Write([]byte(slug))
 	ev.ID = hex.EncodeToString(hash.Sum(nil))
 }
 
 // TranslateRuleComment translates rule comment variables with metric name and
-// rule pattern.
+// rule pattern, and tag variables with metric tags.
 //
-//	m := &Metric{Name: "timer.count_ps.foo"}
-//	r := &Rule{Pattern: "timer.count_ps.*", Comment: "$1 timing"}
+//	m := &Metric{Name: "timer.count_ps.foo;host=web1", Tags: {"host": "web1"}}
+//	r := &Rule{Pattern: "timer.count_ps.*", Comment: "$1 timing on ${host}"}
 //	ev := &Event{Metric:m, Rule:r}
-//	ev.TranslateRuleComment()  // ev.RuleTranslatedComment => "foo timing"
+//	ev.TranslateRuleComment()  // ev.RuleTranslatedComment => "foo timing on web1"
 //
 func (ev *Event) TranslateRuleComment() {
+	s := ev.Rule.Comment
+	for k, v := range ev.Metric.Tags {
+		s = strings.Replace(s, "${"+k+"}", v, -1)
+	}
 	patternParts := strings.Split(ev.Rule.Pattern, ".")
-	metricParts := strings.Split(ev.Metric.Name, ".")
+	metricParts := strings.Split(ev.Metric.BaseName(), ".")
 	if len(patternParts) != len(metricParts) { // Unexcepted input metric and pattern.
-		ev.RuleTranslatedComment = ev.Rule.Comment // Use original comment
+		ev.RuleTranslatedComment = s // Use original comment
 		return
 	}
 	i := 0
-	s := ev.Rule.Comment
 	for j, patternPart := range patternParts {
 		if patternPart == "*" {
 			i++
@@ -0,0 +1,67 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package models
+
+import "strings"
+
+// EventRecord is the persisted history of an alerting event for a rule.
+type EventRecord struct {
+	// ID in db.
+	ID int `gorm:"primary_key" json:"id"`
+	// Event ID, the same for records of an event with multiple rules.
+	EventID string `sql:"size:40;index" json:"eventID"`
+	// Project and rule the event hit.
+	ProjectID   int    `sql:"index" json:"projectID"`
+	RuleID      int    `sql:"index" json:"ruleID"`
+	RulePattern string `sql:"size:400" json:"rulePattern"`
+	// Metric
+	Name    string  `sql:"size:256;index" json:"name"`
+	Stamp   uint32  `sql:"index" json:"stamp"`
+	Value   float64 `json:"value"`
+	Average float64 `json:"average"`
+	// Index score and the algorithm.
+	Score     float64 `json:"score"`
+	Algorithm string  `sql:"size:32" json:"algorithm"`
+	// Translated rule comment.
+	Comment string `sql:"type:varchar(256)" json:"comment"`
+	// Names of users notified, separated by comma.
+	Receivers string `sql:"type:text" json:"receivers"`
+	// Whether the event was silenced, rate limited, snoozed or in
+	// maintenance without notifications.
+	Silenced      bool `json:"silenced"`
+	RateLimited   bool `json:"rateLimited"`
+	Snoozed       bool `json:"snoozed"`
+	InMaintenance bool `json:"inMaintenance"`
+	// Whether it is a resolved event.
+	Resolved bool `json:"resolved"`
+}
+
+// NewEventRecord creates an EventRecord from an event with its rule.
+func NewEventRecord(ev *Event, rule *Rule) *EventRecord {
+	r := &EventRecord{
+		EventID:     ev.ID,
+		ProjectID:   rule.ProjectID,
+		RuleID:      rule.ID,
+		RulePattern: rule.Pattern,
+		Name:        ev.Metric.Name,
+		Stamp:       ev.Metric.Stamp,
+		Value:       ev.Metric.Value,
+		Average:     ev.Metric.Average,
+		Comment:     ev.RuleTranslatedComment,
+		Resolved:    ev.Resolved,
+	}
+	if ev.Index != nil {
+		r.Score = ev.Index.Score
+		r.Algorithm = ev.Index.Algorithm
+	}
+	return r
+}
+
+// SetReceivers sets the names of users notified.
+func (r *EventRecord) SetReceivers(users []User) {
+	names := make([]string, len(users))
+	for i, user := range users {
+		names[i] = user.Name
+	}
+	r.Receivers = strings.Join(names, ",")
+}
@@ -16,6 +16,13 @@ func TestGenerateID(t *testing.T) {
 	ev1 = NewEvent(&Metric{Name: "foo", Stamp: 1456815973}, nil)
 	ev2 = NewEvent(&Metric{Name: "bar", Stamp: 1456815973}, nil)
 	util.Must(t, ev1.ID != ev2.ID)
+	// Resolved event with the same metric.
+	m := &Metric{Name: "foo", Stamp: 1456815973}
+	ev1 = NewEvent(m, nil)
+	ev2 = NewResolvedEvent(m, nil, []*Rule{&Rule{ID: 1}})
+	util.Must(t, ev1.ID != ev2.ID)
+	util.Must(t, ev2.Resolved && len(ev2.Metric.TestedRules) == 1)
+	util.Must(t, len(m.TestedRules) == 0)
 }
 
 func TestTranslateRuleComment(t *testing.T) {
@@ -35,3 +42,14 @@ func TestTranslateRuleCommentNoVariables(t *testing.T) {
 	excepted := "no variables"
 	util.Must(t, ev.RuleTranslatedComment == excepted)
 }
+
+func TestTranslateRuleCommentTags(t *testing.T) {
+	m := &Metric{Name: "timer.count_ps.foo;host=web1", Tags: map[string]string{"host": "web1"}}
+	r := &Rule{Pattern: "timer.count_ps.*", Comment: "$1 timing on ${host}"}
+	ev := NewEvent(m, nil)
+	ev.Rule = r
+	ev.TranslateRuleComment()
+	excepted := "foo timing on web1"
+	util.Must(t, ev.RuleTranslatedComment == excepted)
+	util.Must(t, ev.Tags["host"] == "web1")
+}
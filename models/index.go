@@ -10,6 +10,8 @@ type Index struct {
 	cache `json:"-"`
 	// Metric name
 	Name string `json:"name"`
+	// Metric tags, nil for untagged metrics.
+	Tags map[string]string `json:"tags,omitempty"`
 	// Latest stamp for the metric.
 	Stamp uint32 `json:"stamp"`
 	// Latest trending score for the metric.
@@ -18,6 +20,8 @@ type Index struct {
 	Average float64 `json:"average"`
 	// Link between index and metric.
 	Link uint32 `json:"link"`
+	// Algorithm used to score the latest metric.
+	Algorithm string `json:"algorithm"`
 	// Matched rules.
 	MatchedRules []*Rule `json:"matchedRules"`
 }
@@ -27,6 +31,7 @@ func (idx *Index) WriteMetric(m *Metric) {
 	idx.Lock()
 	defer idx.Unlock()
 	idx.Name = m.Name
+	idx.Tags = m.Tags
 	idx.Stamp = m.Stamp
 	idx.Score = m.Score
 	idx.Average = m.Average
@@ -46,10 +51,12 @@ func (idx *Index) CopyTo(i *Index) {
 	i.Lock()
 	defer i.Unlock()
 	i.Name = idx.Name
+	i.Tags = idx.Tags
 	i.Stamp = idx.Stamp
 	i.Score = idx.Score
 	i.Average = idx.Average
 	i.Link = idx.Link
+	i.Algorithm = idx.Algorithm
 }
 
 // Equal tests the equality.
@@ -62,5 +69,6 @@ func (idx *Index) Equal(i *Index) bool {
 		idx.Stamp == i.Stamp &&
 		idx.Score == i.Score &&
 		idx.Average == i.Average &&
-		idx.Link == i.Link)
+		idx.Link == i.Link &&
+		idx.Algorithm == i.Algorithm)
 }
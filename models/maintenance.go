@@ -0,0 +1,106 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maintenance is a scheduled window to suppress alerts of a project or a
+// rule, alerts are still recorded into the events history.
+//
+// A window is either absolute, from Start to End, or recurring weekly, from
+// TimeStart to TimeEnd on the Weekdays in the Timezone, optionally bounded
+// by Start and End:
+//
+//	// Absolute
+//	&Maintenance{ProjectID: 1, Start: 1452674178, End: 1452677778}
+//	// Every Tuesday and Thursday 22:00 - 02:00 in Shanghai.
+//	&Maintenance{RuleID: 2, Weekdays: "2,4", TimeStart: "22:00", TimeEnd: "02:00", Timezone: "Asia/Shanghai"}
+//
+type Maintenance struct {
+	// ID in db.
+	ID int `gorm:"primary_key" json:"id"`
+	// Project or rule attached to, the other is 0.
+	ProjectID int `sql:"index" json:"projectID"`
+	RuleID    int `sql:"index" json:"ruleID"`
+	// Absolute unix stamps range [Start, End), 0 for unbounded if recurring.
+	Start uint32 `json:"start"`
+	End   uint32 `json:"end"`
+	// Recurring weekdays separated by comma, 0 for Sunday, empty for an
+	// absolute window.
+	Weekdays string `sql:"size:32" json:"weekdays"`
+	// Recurring time range [TimeStart, TimeEnd) in format "15:04", may cross
+	// the midnight.
+	TimeStart string `sql:"size:8" json:"timeStart"`
+	TimeEnd   string `sql:"size:8" json:"timeEnd"`
+	// IANA timezone name for recurring windows, empty for UTC.
+	Timezone string `sql:"size:64" json:"timezone"`
+	// Comment
+	Comment string `sql:"type:varchar(256)" json:"comment"`
+}
+
+// IsRecurring returns true if the maintenance is recurring weekly.
+func (mt *Maintenance) IsRecurring() bool {
+	return len(mt.Weekdays) > 0
+}
+
+// parseWeekdays parses weekdays separated by comma into a set.
+func parseWeekdays(s string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < int(time.Sunday) || n > int(time.Saturday) {
+			return nil, ErrMaintenanceWeekdays
+		}
+		days[time.Weekday(n)] = true
+	}
+	return days, nil
+}
+
+// parseMinutes parses time in format "15:04" into minutes of a day.
+func parseMinutes(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, ErrMaintenanceTime
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// IsActive returns true if the time t is in the maintenance window. Invalid
+// maintenances are never active.
+func (mt *Maintenance) IsActive(t time.Time) bool {
+	stamp := uint32(t.Unix())
+	if stamp < mt.Start || (mt.End > 0 && stamp >= mt.End) {
+		return false
+	}
+	if !mt.IsRecurring() {
+		return mt.End > 0
+	}
+	loc, err := time.LoadLocation(mt.Timezone)
+	if err != nil {
+		return false
+	}
+	days, err := parseWeekdays(mt.Weekdays)
+	if err != nil {
+		return false
+	}
+	start, err := parseMinutes(mt.TimeStart)
+	if err != nil {
+		return false
+	}
+	end, err := parseMinutes(mt.TimeEnd)
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+	minutes := t.Hour()*60 + t.Minute()
+	if start < end {
+		return days[t.Weekday()] && start <= minutes && minutes < end
+	}
+	// Crosses the midnight, the window belongs to the day it starts.
+	yesterday := (t.Weekday() + 6) % 7
+	return (days[t.Weekday()] && minutes >= start) || (days[yesterday] && minutes < end)
+}
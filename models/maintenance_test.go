@@ -0,0 +1,36 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eleme/banshee/util"
+)
+
+func TestMaintenanceAbsolute(t *testing.T) {
+	mt := &Maintenance{ProjectID: 1, Start: 1452674178, End: 1452677778}
+	util.Must(t, !mt.IsActive(time.Unix(1452674177, 0)))
+	util.Must(t, mt.IsActive(time.Unix(1452674178, 0)))
+	util.Must(t, mt.IsActive(time.Unix(1452677777, 0)))
+	util.Must(t, !mt.IsActive(time.Unix(1452677778, 0)))
+}
+
+func TestMaintenanceRecurring(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	util.Must(t, err == nil)
+	// Tuesday and Thursday 22:00 - 02:00 in Shanghai.
+	mt := &Maintenance{RuleID: 1, Weekdays: "2,4", TimeStart: "22:00", TimeEnd: "02:00", Timezone: "Asia/Shanghai"}
+	// 2016-01-12 is a Tuesday.
+	util.Must(t, mt.IsActive(time.Date(2016, 1, 12, 23, 0, 0, 0, loc)))
+	util.Must(t, mt.IsActive(time.Date(2016, 1, 13, 1, 59, 0, 0, loc)))
+	util.Must(t, !mt.IsActive(time.Date(2016, 1, 13, 2, 0, 0, 0, loc)))
+	util.Must(t, !mt.IsActive(time.Date(2016, 1, 13, 23, 0, 0, 0, loc)))
+	util.Must(t, !mt.IsActive(time.Date(2016, 1, 12, 1, 0, 0, 0, loc)))
+	// Timezone matters: 2016-01-12 23:00 in Shanghai is 15:00 in UTC.
+	util.Must(t, mt.IsActive(time.Date(2016, 1, 12, 15, 0, 0, 0, time.UTC)))
+	// Bounded by the absolute range.
+	mt.End = uint32(time.Date(2016, 1, 12, 0, 0, 0, 0, loc).Unix())
+	util.Must(t, !mt.IsActive(time.Date(2016, 1, 12, 23, 0, 0, 0, loc)))
+}
@@ -4,8 +4,10 @@ package models
 
 // Metric is a data container for time series datapoint.
 type Metric struct {
-	// Name
+	// Name, with tags if tagged, e.g. "cpu.usage;host=web1"
 	Name string `json:"name"`
+	// Tags, nil for untagged metrics.
+	Tags map[string]string `json:"tags,omitempty"`
 	// Metric unix time stamp
 	Stamp uint32 `json:"stamp"`
 	// Metric value
@@ -16,10 +18,24 @@ type Metric struct {
 	Average float64 `json:"average"`
 	// Link between index and metric.
 	Link uint32 `json:"link"`
+	// Aggregation of a rollup metric, nil for raw metrics.
+	Rollup *MetricRollup `json:"rollup,omitempty"`
 	// Matched rules
 	TestedRules []*Rule `json:"-"`
 }
 
+// MetricRollup is the aggregation of raw metrics in an interval, the metric
+// value and average are the averages.
+type MetricRollup struct {
+	// Interval in seconds
+	Interval uint32 `json:"interval"`
+	// Number of raw metrics
+	Count uint32 `json:"count"`
+	// Min and max values
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
 // LinkTo links the metric to an index.
 func (m *Metric) LinkTo(idx *Index) {
 	m.Link = idx.Link
@@ -2,11 +2,6 @@
 
 package models
 
-import (
-	"github.com/eleme/banshee/config"
-	"path/filepath"
-)
-
 // Rule Levels
 const (
 	RuleLevelLow = iota
@@ -41,6 +36,10 @@ type Rule struct {
 	Level int `json:"level"`
 	// Disabled
 	Disabled bool `sql:"default:false" json:"disabled"`
+	// Detection algorithm, empty for the configured default.
+	Algorithm string `sql:"size:32" json:"algorithm"`
+	// Tag selectors, e.g. "host=web*;region=us", empty to select all metrics.
+	TagSelectors string `sql:"size:256" json:"tagSelectors"`
 }
 
 // Copy the rule.
@@ -66,6 +65,8 @@ func (rule *Rule) CopyTo(r *Rule) {
 	r.Comment = rule.Comment
 	r.Level = rule.Level
 	r.Disabled = rule.Disabled
+	r.Algorithm = rule.Algorithm
+	r.TagSelectors = rule.TagSelectors
 }
 
 // Equal tests rule equality
@@ -73,7 +74,7 @@ func (rule *Rule) Equal(r *Rule) bool {
 	rule.RLock()
 	defer rule.RUnlock()
 	r.RLock()
-	defer rule.RUnlock()
+	defer r.RUnlock()
 	return (r.ID == rule.ID &&
 		r.ProjectID == rule.ProjectID &&
 		r.Pattern == rule.Pattern &&
@@ -83,15 +84,19 @@ func (rule *Rule) Equal(r *Rule) bool {
 		r.ThresholdMin == rule.ThresholdMin &&
 		r.Comment == rule.Comment &&
 		r.Level == rule.Level &&
-		r.Disabled == rule.Disabled)
+		r.Disabled == rule.Disabled &&
+		r.Algorithm == rule.Algorithm &&
+		r.TagSelectors == rule.TagSelectors)
 }
 
 // Test if a metric hits this rule.
 //
 //	1. For trend related conditions, index.Score will be used.
 //	2. For value related conditions, metric.Value will be used.
+//	3. Default thresholds are used if the rule has no thresholds, 0 for
+//	   none, see DetectorSetting.
 //
-func (rule *Rule) Test(m *Metric, idx *Index, cfg *config.Config) bool {
+func (rule *Rule) Test(m *Metric, idx *Index, defaultThresholdMax, defaultThresholdMin float64) bool {
 	// RLock if shared.
 	rule.RLock()
 	defer rule.RUnlock()
@@ -99,27 +104,6 @@ func (rule *Rule) Test(m *Metric, idx *Index, cfg *config.Config) bool {
 		// Tmp disabled
 		return false
 	}
-	// Default thresholds.
-	var defaultThresholdMax float64
-	var defaultThresholdMin float64
-	if cfg != nil {
-		// Check defaults
-		for p, v := range cfg.Detector.DefaultThresholdMaxs {
-			if ok, _ := filepath.Match(p, m.Name); ok && v != 0 {
-				defaultThresholdMax = v
-				break
-			}
-		}
-	}
-	if cfg != nil {
-		// Check defaults
-		for p, v := range cfg.Detector.DefaultThresholdMins {
-			if ok, _ := filepath.Match(p, m.Name); ok && v != 0 {
-				defaultThresholdMin = v
-				break
-			}
-		}
-	}
 	// Conditions
 	ok := false
 	if !ok && rule.TrendUp {
@@ -155,6 +139,19 @@ func (rule *Rule) Test(m *Metric, idx *Index, cfg *config.Config) bool {
 	return ok
 }
 
+// MatchTags tests if the rule's tag selectors select the tags, invalid
+// selectors select nothing.
+func (rule *Rule) MatchTags(tags map[string]string) bool {
+	// RLock if shared.
+	rule.RLock()
+	defer rule.RUnlock()
+	selectors, err := ParseTagSelectors(rule.TagSelectors)
+	if err != nil {
+		return false
+	}
+	return MatchTagSelectors(selectors, tags)
+}
+
 // SetNumMetrics sets the rule's number of metrics matched.
 func (rule *Rule) SetNumMetrics(n int) {
 	// Lock if shared.
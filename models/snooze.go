@@ -0,0 +1,28 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package models
+
+import "time"
+
+// Snooze stops the notifications of a metric on a rule until a time, an
+// acknowledgement is a snooze that also ends once the alert is resolved.
+type Snooze struct {
+	// ID in db.
+	ID int `gorm:"primary_key" json:"id"`
+	// Metric name.
+	Metric string `sql:"size:256;index;not null" json:"metric"`
+	// Rule id, 0 for all rules.
+	RuleID int `sql:"index" json:"ruleID"`
+	// Unix stamp until when the notifications are stopped.
+	Until uint32 `sql:"index" json:"until"`
+	// Is an acknowledgement.
+	Ack bool `json:"ack"`
+	// Comment
+	Comment   string    `sql:"type:varchar(256)" json:"comment"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsActive returns true if the snooze is not expired at stamp.
+func (s *Snooze) IsActive(stamp uint32) bool {
+	return stamp < s.Until
+}
@@ -0,0 +1,165 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/eleme/banshee/util/wildcard"
+)
+
+// Tagged metric names are in graphite's tagged series format, the metric
+// name followed by tags sorted by key:
+//
+//	cpu.usage;host=web1;region=us
+//
+// Dots in tags are replaced by underscores, since dots delimit the segments
+// of metric names.
+const (
+	// Delimiter between the metric name and tags.
+	TagsDelim = ";"
+	// Delimiter between a tag key and value.
+	TagDelim = "="
+)
+
+// TaggedName returns the metric name with tags.
+func TaggedName(name string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys)+1)
+	parts = append(parts, name)
+	for _, k := range keys {
+		parts = append(parts, k+TagDelim+tags[k])
+	}
+	return strings.Join(parts, TagsDelim)
+}
+
+// SplitTaggedName splits a tagged metric name into the name and tags, tags
+// is nil if the name is not tagged.
+func SplitTaggedName(s string) (string, map[string]string, error) {
+	parts := strings.Split(s, TagsDelim)
+	if len(parts) == 1 {
+		return s, nil, nil
+	}
+	tags := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		kv := strings.SplitN(part, TagDelim, 2)
+		if len(kv) != 2 {
+			return "", nil, ErrMetricTagFormat
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return parts[0], tags, nil
+}
+
+// ValidateMetricTag validates a metric tag key and value.
+func ValidateMetricTag(key, value string) error {
+	for _, s := range []string{key, value} {
+		if len(s) == 0 || strings.ContainsAny(s, TagsDelim+TagDelim+" \t") {
+			return ErrMetricTagFormat
+		}
+	}
+	return nil
+}
+
+// BaseName returns the metric name without tags.
+func (m *Metric) BaseName() string {
+	if i := strings.Index(m.Name, TagsDelim); i >= 0 {
+		return m.Name[:i]
+	}
+	return m.Name
+}
+
+// NormalizeTags merges the tags in the metric name into metric tags, and
+// rewrites the metric name to the tagged name with tags sorted.
+func (m *Metric) NormalizeTags() error {
+	name, tags, err := SplitTaggedName(m.Name)
+	if err != nil {
+		return err
+	}
+	for k, v := range m.Tags {
+		if tags == nil {
+			tags = make(map[string]string, len(m.Tags))
+		}
+		tags[k] = v
+	}
+	normalized := make(map[string]string, len(tags))
+	for k, v := range tags {
+		k = strings.Replace(k, ".", "_", -1)
+		v = strings.Replace(v, ".", "_", -1)
+		if err := ValidateMetricTag(k, v); err != nil {
+			return err
+		}
+		normalized[k] = v
+	}
+	if len(normalized) == 0 {
+		normalized = nil
+	}
+	m.Name = TaggedName(name, normalized)
+	m.Tags = normalized
+	return nil
+}
+
+// TagSelector selects metrics by a tag, the value is a wildcard pattern of a
+// segment, e.g. "web*" or "{us,eu}".
+type TagSelector struct {
+	Key     string
+	Pattern string
+	// Selects metrics without the tag matching the pattern.
+	Negative bool
+}
+
+// ParseTagSelectors parses selectors delimited by ";", a selector is either
+// "key=pattern" or "key!=pattern", e.g.
+//
+//	host=web*;region!={us,eu}
+//
+func ParseTagSelectors(s string) ([]TagSelector, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	var selectors []TagSelector
+	for _, part := range strings.Split(s, TagsDelim) {
+		kv := strings.SplitN(part, TagDelim, 2)
+		if len(kv) != 2 {
+			return nil, ErrRuleTagSelectors
+		}
+		sel := TagSelector{Key: kv[0], Pattern: kv[1]}
+		if strings.HasSuffix(sel.Key, "!") {
+			sel.Key = sel.Key[:len(sel.Key)-1]
+			sel.Negative = true
+		}
+		if len(sel.Key) == 0 || len(sel.Pattern) == 0 {
+			return nil, ErrRuleTagSelectors
+		}
+		if strings.ContainsAny(sel.Key+sel.Pattern, " \t") {
+			return nil, ErrRuleTagSelectors
+		}
+		selectors = append(selectors, sel)
+	}
+	return selectors, nil
+}
+
+// Match tests if the selector selects the tags.
+func (sel TagSelector) Match(tags map[string]string) bool {
+	v, ok := tags[sel.Key]
+	ok = ok && wildcard.MatchSegment(sel.Pattern, v)
+	return ok != sel.Negative
+}
+
+// MatchTagSelectors tests if all the selectors select the tags.
+func MatchTagSelectors(selectors []TagSelector, tags map[string]string) bool {
+	for _, sel := range selectors {
+		if !sel.Match(tags) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package models
+
+import (
+	"testing"
+
+	"github.com/eleme/banshee/util"
+)
+
+func TestTaggedName(t *testing.T) {
+	util.Must(t, TaggedName("foo", nil) == "foo")
+	tags := map[string]string{"region": "us", "host": "web1"}
+	util.Must(t, TaggedName("foo.bar", tags) == "foo.bar;host=web1;region=us")
+	name, tags, err := SplitTaggedName("foo.bar;host=web1;region=us")
+	util.Must(t, err == nil && name == "foo.bar")
+	util.Must(t, len(tags) == 2 && tags["host"] == "web1" && tags["region"] == "us")
+	name, tags, err = SplitTaggedName("foo.bar")
+	util.Must(t, err == nil && name == "foo.bar" && tags == nil)
+	_, _, err = SplitTaggedName("foo.bar;host")
+	util.Must(t, err == ErrMetricTagFormat)
+}
+
+func TestNormalizeTags(t *testing.T) {
+	m := &Metric{Name: "foo;region=us", Tags: map[string]string{"host": "web1.example.com"}}
+	util.Must(t, m.NormalizeTags() == nil)
+	util.Must(t, m.Name == "foo;host=web1_example_com;region=us")
+	util.Must(t, m.BaseName() == "foo")
+	util.Must(t, len(m.Tags) == 2 && m.Tags["region"] == "us")
+	// Untagged
+	m = &Metric{Name: "foo.bar"}
+	util.Must(t, m.NormalizeTags() == nil)
+	util.Must(t, m.Name == "foo.bar" && m.Tags == nil && m.BaseName() == "foo.bar")
+	// Invalid
+	m = &Metric{Name: "foo;host="}
+	util.Must(t, m.NormalizeTags() == ErrMetricTagFormat)
+	m = &Metric{Name: "foo", Tags: map[string]string{"host": "a b"}}
+	util.Must(t, m.NormalizeTags() == ErrMetricTagFormat)
+}
+
+func TestTagSelectors(t *testing.T) {
+	selectors, err := ParseTagSelectors("host=web*;region!={us,eu}")
+	util.Must(t, err == nil && len(selectors) == 2)
+	util.Must(t, selectors[1].Key == "region" && selectors[1].Negative)
+	util.Must(t, MatchTagSelectors(selectors, map[string]string{"host": "web1", "region": "cn"}))
+	util.Must(t, MatchTagSelectors(selectors, map[string]string{"host": "web1"}))
+	util.Must(t, !MatchTagSelectors(selectors, map[string]string{"host": "web1", "region": "us"}))
+	util.Must(t, !MatchTagSelectors(selectors, map[string]string{"host": "db1"}))
+	util.Must(t, !MatchTagSelectors(selectors, nil))
+	// Empty selects all.
+	selectors, err = ParseTagSelectors("")
+	util.Must(t, err == nil && MatchTagSelectors(selectors, nil))
+	// Invalid
+	_, err = ParseTagSelectors("host")
+	util.Must(t, err == ErrRuleTagSelectors)
+	_, err = ParseTagSelectors("host=web*;")
+	util.Must(t, err == ErrRuleTagSelectors)
+	_, err = ParseTagSelectors("!=web*")
+	util.Must(t, err == ErrRuleTagSelectors)
+}
@@ -6,6 +6,10 @@ import (
 	"errors"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/util/wildcard"
 )
 
 // Limitations
@@ -20,6 +24,8 @@ const (
 	MaxMetricNameLen = 256
 	// Min value of the metric stamp.
 	MinMetricStamp uint32 = 1450322633
+	// Max value of the snooze duration in seconds.
+	MaxSnoozeDuration uint32 = 30 * config.Day
 )
 
 // Errors
@@ -40,9 +46,21 @@ var (
 	ErrRulePatternContainsSpace = errors.New("rule pattern contains spaces")
 	ErrRulePatternFormat        = errors.New("rule pattern format is invalid")
 	ErrRuleLevel                = errors.New("rule level is invalid")
+	ErrRuleAlgorithm            = errors.New("rule algorithm is not supported")
+	ErrRuleTagSelectors         = errors.New("rule tag selectors format is invalid")
 	ErrMetricNameEmpty          = errors.New("metric name is empty")
 	ErrMetricNameTooLong        = errors.New("metric name is too long")
 	ErrMetricStampTooSmall      = errors.New("metric stamp is too small")
+	ErrMetricTagFormat          = errors.New("metric tag format is invalid")
+	ErrSnoozeDuration           = errors.New("snooze duration is invalid")
+	ErrMaintenanceTarget        = errors.New("maintenance should be attached to either a project or a rule")
+	ErrMaintenanceRange         = errors.New("maintenance start should be smaller than end")
+	ErrMaintenanceWeekdays      = errors.New("maintenance weekdays are invalid")
+	ErrMaintenanceTime          = errors.New("maintenance time should be in format 15:04")
+	ErrMaintenanceTimeRange     = errors.New("maintenance time start should not equal end")
+	ErrMaintenanceTimezone      = errors.New("maintenance timezone is invalid")
+	ErrDetectorSettingKind      = errors.New("detector setting kind is invalid")
+	ErrDetectorSettingValue     = errors.New("detector setting value should not be zero for default thresholds")
 )
 
 // ValidateProjectName validates project name
@@ -128,17 +146,12 @@ func ValidateRulePattern(pattern string) error {
 		// Contains space
 		return ErrRulePatternContainsSpace
 	}
-	for i := 0; i < len(pattern); i++ {
-		if pattern[i] == '*' {
-			if i != 0 && pattern[i-1] != '.' {
-				// Invalid format
-				return ErrRulePatternFormat
-			}
-			if i != len(pattern)-1 && pattern[i+1] != '.' {
-				// Invalid format
-				return ErrRulePatternFormat
-			}
-		}
+	if strings.Contains(pattern, TagsDelim) {
+		// Tags are selected by tag selectors
+		return ErrRulePatternFormat
+	}
+	if !wildcard.Valid(pattern) {
+		return ErrRulePatternFormat
 	}
 	return nil
 }
@@ -157,6 +170,27 @@ func ValidateRuleLevel(level int) error {
 	}
 }
 
+// ValidateRuleAlgorithm validates rule algorithm, empty is ok for the
+// configured default.
+func ValidateRuleAlgorithm(name string) error {
+	if len(name) == 0 {
+		// Use default.
+		return nil
+	}
+	if !config.IsDetectorAlgorithmSupported(name) {
+		// Unsupported
+		return ErrRuleAlgorithm
+	}
+	return nil
+}
+
+// ValidateRuleTagSelectors validates rule tag selectors, empty is ok for
+// selecting all metrics.
+func ValidateRuleTagSelectors(s string) error {
+	_, err := ParseTagSelectors(s)
+	return err
+}
+
 // ValidateMetricName validates metric name.
 func ValidateMetricName(name string) error {
 	if len(name) == 0 {
@@ -178,3 +212,62 @@ func ValidateMetricStamp(stamp uint32) error {
 	}
 	return nil
 }
+
+// ValidateSnoozeDuration validates snooze duration in seconds.
+func ValidateSnoozeDuration(duration uint32) error {
+	if duration == 0 || duration > MaxSnoozeDuration {
+		return ErrSnoozeDuration
+	}
+	return nil
+}
+
+// ValidateMaintenance validates a maintenance window.
+func ValidateMaintenance(mt *Maintenance) error {
+	if (mt.ProjectID > 0) == (mt.RuleID > 0) || mt.ProjectID < 0 || mt.RuleID < 0 {
+		return ErrMaintenanceTarget
+	}
+	if mt.End > 0 && mt.Start >= mt.End {
+		return ErrMaintenanceRange
+	}
+	if !mt.IsRecurring() {
+		if mt.End == 0 {
+			// Absolute window should be bounded.
+			return ErrMaintenanceRange
+		}
+		return nil
+	}
+	if _, err := parseWeekdays(mt.Weekdays); err != nil {
+		return err
+	}
+	start, err := parseMinutes(mt.TimeStart)
+	if err != nil {
+		return err
+	}
+	end, err := parseMinutes(mt.TimeEnd)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return ErrMaintenanceTimeRange
+	}
+	if _, err := time.LoadLocation(mt.Timezone); err != nil {
+		return ErrMaintenanceTimezone
+	}
+	return nil
+}
+
+// ValidateDetectorSetting validates a detector setting, the pattern is
+// validated as a rule pattern.
+func ValidateDetectorSetting(s *DetectorSetting) error {
+	switch s.Kind {
+	case DetectorSettingBlackList, DetectorSettingFillBlankZeros:
+	case DetectorSettingThresholdMax, DetectorSettingThresholdMin:
+		if s.Value == 0 {
+			// Zero disables nothing.
+			return ErrDetectorSettingValue
+		}
+	default:
+		return ErrDetectorSettingKind
+	}
+	return ValidateRulePattern(s.Pattern)
+}
@@ -3,6 +3,7 @@
 package models
 
 import (
+	"github.com/eleme/banshee/config"
 	"github.com/eleme/banshee/util"
 	"math/rand"
 	"testing"
@@ -51,10 +52,24 @@ func TestValidateUserPhone(t *testing.T) {
 func TestValidateRulePattern(t *testing.T) {
 	util.Must(t, ValidateRulePattern("") == ErrRulePatternEmpty)
 	util.Must(t, ValidateRulePattern("abc efg") == ErrRulePatternContainsSpace)
-	util.Must(t, ValidateRulePattern("abc*.s") == ErrRulePatternFormat)
 	util.Must(t, ValidateRulePattern("abc.*.s") == nil)
 	util.Must(t, ValidateRulePattern("abc.*.*") == nil)
 	util.Must(t, ValidateRulePattern("*.abc.*") == nil)
+	// Multi-level wildcards
+	util.Must(t, ValidateRulePattern("abc.**.s") == nil)
+	util.Must(t, ValidateRulePattern("**.s") == nil)
+	util.Must(t, ValidateRulePattern("abc.**.**.s") == ErrRulePatternFormat)
+	util.Must(t, ValidateRulePattern("abc**.s") == ErrRulePatternFormat)
+	// In-segment wildcards
+	util.Must(t, ValidateRulePattern("abc*.s") == nil)
+	util.Must(t, ValidateRulePattern("api_*_latency") == nil)
+	// Alternatives
+	util.Must(t, ValidateRulePattern("abc.{get,set}.s") == nil)
+	util.Must(t, ValidateRulePattern("abc.api_{get,set}_*") == nil)
+	util.Must(t, ValidateRulePattern("abc.{get,set.s") == ErrRulePatternFormat)
+	util.Must(t, ValidateRulePattern("abc.get}.s") == ErrRulePatternFormat)
+	util.Must(t, ValidateRulePattern("abc.{a,{b,c}}") == ErrRulePatternFormat)
+	util.Must(t, ValidateRulePattern("abc.{a.b}") == ErrRulePatternFormat)
 }
 
 func TestValidateRuleLevel(t *testing.T) {
@@ -64,6 +79,14 @@ func TestValidateRuleLevel(t *testing.T) {
 	util.Must(t, ValidateRuleLevel(2016) == ErrRuleLevel)
 }
 
+func TestValidateRuleAlgorithm(t *testing.T) {
+	// Registered by the detector.
+	config.RegisterDetectorAlgorithm("mad")
+	util.Must(t, ValidateRuleAlgorithm("") == nil)
+	util.Must(t, ValidateRuleAlgorithm("mad") == nil)
+	util.Must(t, ValidateRuleAlgorithm("unknown") == ErrRuleAlgorithm)
+}
+
 func TestValidateMetricName(t *testing.T) {
 	util.Must(t, ValidateMetricName("") == ErrMetricNameEmpty)
 	util.Must(t, ValidateMetricName(genLongString(MaxMetricNameLen+1)) == ErrMetricNameTooLong)
@@ -72,3 +95,38 @@ func TestValidateMetricName(t *testing.T) {
 func TestValidateMetricStamp(t *testing.T) {
 	util.Must(t, ValidateMetricStamp(123) == ErrMetricStampTooSmall)
 }
+
+func TestValidateSnoozeDuration(t *testing.T) {
+	util.Must(t, ValidateSnoozeDuration(0) == ErrSnoozeDuration)
+	util.Must(t, ValidateSnoozeDuration(MaxSnoozeDuration+1) == ErrSnoozeDuration)
+	util.Must(t, ValidateSnoozeDuration(3600) == nil)
+}
+
+func TestValidateMaintenance(t *testing.T) {
+	util.Must(t, ValidateMaintenance(&Maintenance{Start: 1, End: 2}) == ErrMaintenanceTarget)
+	util.Must(t, ValidateMaintenance(&Maintenance{ProjectID: 1, RuleID: 1, Start: 1, End: 2}) == ErrMaintenanceTarget)
+	util.Must(t, ValidateMaintenance(&Maintenance{ProjectID: 1, Start: 2, End: 1}) == ErrMaintenanceRange)
+	util.Must(t, ValidateMaintenance(&Maintenance{ProjectID: 1, Start: 1}) == ErrMaintenanceRange)
+	util.Must(t, ValidateMaintenance(&Maintenance{ProjectID: 1, Start: 1, End: 2}) == nil)
+	mt := &Maintenance{RuleID: 1, Weekdays: "1,7", TimeStart: "22:00", TimeEnd: "02:00"}
+	util.Must(t, ValidateMaintenance(mt) == ErrMaintenanceWeekdays)
+	mt.Weekdays = "1,5"
+	mt.TimeEnd = "2am"
+	util.Must(t, ValidateMaintenance(mt) == ErrMaintenanceTime)
+	mt.TimeEnd = "22:00"
+	util.Must(t, ValidateMaintenance(mt) == ErrMaintenanceTimeRange)
+	mt.TimeEnd = "02:00"
+	mt.Timezone = "Mars/Olympus"
+	util.Must(t, ValidateMaintenance(mt) == ErrMaintenanceTimezone)
+	mt.Timezone = "Asia/Shanghai"
+	util.Must(t, ValidateMaintenance(mt) == nil)
+}
+
+func TestValidateDetectorSetting(t *testing.T) {
+	util.Must(t, ValidateDetectorSetting(&DetectorSetting{Kind: "whitelist", Pattern: "a.*"}) == ErrDetectorSettingKind)
+	util.Must(t, ValidateDetectorSetting(&DetectorSetting{Kind: DetectorSettingBlackList}) == ErrRulePatternEmpty)
+	util.Must(t, ValidateDetectorSetting(&DetectorSetting{Kind: DetectorSettingBlackList, Pattern: "a.**.**"}) == ErrRulePatternFormat)
+	util.Must(t, ValidateDetectorSetting(&DetectorSetting{Kind: DetectorSettingBlackList, Pattern: "statsd.**"}) == nil)
+	util.Must(t, ValidateDetectorSetting(&DetectorSetting{Kind: DetectorSettingThresholdMax, Pattern: "a.*"}) == ErrDetectorSettingValue)
+	util.Must(t, ValidateDetectorSetting(&DetectorSetting{Kind: DetectorSettingThresholdMin, Pattern: "a.*", Value: 10}) == nil)
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/eleme/banshee/alerter"
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/detector"
+	"github.com/eleme/banshee/util/log"
+	"github.com/eleme/banshee/webapp"
+)
+
+// ErrNoConfigFile is returned on reloading if no config file is specified.
+var ErrNoConfigFile = errors.New("no config file specified")
+
+// reloader reloads the config file and applies the reloadable fields to the
+// running components.
+type reloader struct {
+	lock     sync.Mutex
+	detector *detector.Detector
+	alerter  *alerter.Alerter
+}
+
+// reload the config file, the new config is validated and the detector
+// settings are loaded before any other change is applied, and only the
+// fields safe to change are taken, see config.CopyReloadable.
+func (rl *reloader) reload() (*config.Config, error) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	if !configSpecified() {
+		return nil, ErrNoConfigFile
+	}
+	c := config.New()
+	if err := c.UpdateWithYamlFile(*fileName); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	next := cfg.CopyReloadable(c)
+	// Loading settings is the only step that may fail, nothing is changed
+	// on error.
+	if err := flt.LoadSettings(db, next); err != nil {
+		return nil, err
+	}
+	flt.SetIntervalHitLimit(next.Detector.IntervalHitLimit)
+	rl.detector.SetConfig(next)
+	rl.alerter.SetConfig(next)
+	webapp.SetConfig(next)
+	cfg = next
+	log.Infof("config %s reloaded", *fileName)
+	return next, nil
+}
+
+// watch reloads the config file on SIGHUP.
+func (rl *reloader) watch() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		if _, err := rl.reload(); err != nil {
+			log.Errorf("failed to reload config: %v", err)
+		}
+	}
+}
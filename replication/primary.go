@@ -0,0 +1,177 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/util/log"
+)
+
+// replica is a replica connected to the primary.
+type replica struct {
+	addr string
+	conn net.Conn
+	ch   chan *record
+	once sync.Once
+}
+
+// close the replica connection, safe to call multiple times.
+func (rep *replica) close() {
+	rep.once.Do(func() { rep.conn.Close() })
+}
+
+// serve listens on the port and streams records to replicas.
+func (r *Replication) serve() {
+	addr := fmt.Sprintf("0.0.0.0:%d", r.cfg.Replication.Port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+	log.Infof("replication is listening on tcp://%s", addr)
+	if r.replicateAdmin() {
+		go r.syncAdmin()
+	}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Errorf("cannot accept conn: %v, skipping..", err)
+			continue
+		}
+		go r.handle(conn)
+	}
+}
+
+// replicateAdmin returns true if admin data should be replicated, only on
+// sqlite3.
+func (r *Replication) replicateAdmin() bool {
+	return r.cfg.Storage.Admin.Dialect == config.StorageAdminDialectSQLite3
+}
+
+// syncAdmin publishes the admin data to replicas on changes.
+func (r *Replication) syncAdmin() {
+	var last []byte
+	ticker := time.NewTicker(adminSyncInterval)
+	for _ = range ticker.C {
+		d, err := r.db.Admin.Dump()
+		if err != nil {
+			log.Errorf("replication dump admin: %v", err)
+			continue
+		}
+		b, err := json.Marshal(d)
+		if err != nil {
+			log.Errorf("replication dump admin: %v", err)
+			continue
+		}
+		if last != nil && !bytes.Equal(b, last) {
+			r.publish(&record{Admin: d})
+		}
+		last = b
+	}
+}
+
+// handle a replica connection, it will:
+//
+//	1. Read the handshake.
+//	2. Send the admin data and metrics since the handshake stamp.
+//	3. Send live records and heartbeats until errors.
+//
+func (r *Replication) handle(conn net.Conn) {
+	rep := &replica{
+		addr: conn.RemoteAddr().String(),
+		conn: conn,
+		ch:   make(chan *record, bufferSize),
+	}
+	defer rep.close()
+	// Handshake
+	hs := &handshake{}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	if err := json.NewDecoder(conn).Decode(hs); err != nil {
+		log.Errorf("replica %s handshake: %v, closing conn..", rep.addr, err)
+		return
+	}
+	// Register before catching up, live records are buffered meanwhile.
+	r.lock.Lock()
+	r.replicas[rep] = true
+	r.lock.Unlock()
+	defer func() {
+		r.lock.Lock()
+		delete(r.replicas, rep)
+		r.lock.Unlock()
+		log.Infof("replica %s disconnected", rep.addr)
+	}()
+	log.Infof("replica %s connected, catching up since %d..", rep.addr, hs.Since)
+	w := bufio.NewWriter(conn)
+	enc := json.NewEncoder(w)
+	send := func(rec *record) error {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		return enc.Encode(rec)
+	}
+	// Catch up
+	err := r.catchup(hs.Since, send)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		log.Errorf("replica %s catch up: %v, closing conn..", rep.addr, err)
+		return
+	}
+	// Stream
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		var rec *record
+		select {
+		case rec = <-rep.ch:
+		case <-ticker.C:
+			rec = &record{}
+		}
+		err = send(rec)
+		if err == nil && len(rep.ch) == 0 {
+			// Flush if no more records.
+			err = w.Flush()
+		}
+		if err != nil {
+			log.Errorf("replica %s write: %v, closing conn..", rep.addr, err)
+			return
+		}
+	}
+}
+
+// catchup sends the admin data and metrics stored since the stamp.
+func (r *Replication) catchup(since uint32, send func(*record) error) error {
+	if r.replicateAdmin() {
+		d, err := r.db.Admin.Dump()
+		if err != nil {
+			return err
+		}
+		if err := send(&record{Admin: d}); err != nil {
+			return err
+		}
+	}
+	// Only raw metrics.
+	now := uint32(time.Now().Unix())
+	if since+r.cfg.Expiration < now {
+		since = now - r.cfg.Expiration
+	}
+	for _, idx := range r.db.Index.All() {
+		if idx.Stamp < since {
+			continue
+		}
+		ms, err := r.db.Metric.GetRaw(idx.Name, idx.Link, since, now+1)
+		if err != nil {
+			return err
+		}
+		if err := send(&record{Index: idx, Metrics: ms}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package replication
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/storage/admindb"
+	"github.com/eleme/banshee/storage/indexdb"
+	"github.com/eleme/banshee/util/log"
+)
+
+// follow connects to the primary and applies records, reconnects with
+// backoff on errors until promoted.
+func (r *Replication) follow() {
+	addr := r.cfg.Replication.Primary
+	delay := time.Second
+	for {
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err == nil {
+			log.Infof("replication connected to primary %s", addr)
+			delay = time.Second
+			err = r.receive(conn)
+		}
+		select {
+		case <-r.promoted:
+			return
+		default:
+		}
+		log.Errorf("replication from primary %s: %v, retrying in %s..", addr, err, delay)
+		select {
+		case <-r.promoted:
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// receive sends the handshake and applies records from the connection until
+// errors, the connection is closed then.
+func (r *Replication) receive(conn net.Conn) error {
+	r.lock.Lock()
+	if r.role != config.ReplicationRoleReplica {
+		// Promoted meanwhile.
+		r.lock.Unlock()
+		conn.Close()
+		return ErrNotReplica
+	}
+	r.conn = conn
+	r.lock.Unlock()
+	defer func() {
+		r.lock.Lock()
+		r.conn = nil
+		r.lock.Unlock()
+		conn.Close()
+	}()
+	// Handshake
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	if err := json.NewEncoder(conn).Encode(&handshake{Since: r.since()}); err != nil {
+		return err
+	}
+	// Records
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	for {
+		conn.SetReadDeadline(time.Now().Add(3 * heartbeatInterval))
+		rec := &record{}
+		if err := dec.Decode(rec); err != nil {
+			return err
+		}
+		if err := r.apply(rec); err != nil {
+			log.Errorf("replication apply: %v, skipping..", err)
+		}
+	}
+}
+
+// since returns the stamp to catch up since, a bit earlier than the latest
+// index.
+func (r *Replication) since() uint32 {
+	var stamp uint32
+	for _, idx := range r.db.Index.All() {
+		if idx.Stamp > stamp {
+			stamp = idx.Stamp
+		}
+	}
+	if stamp < catchupSlack {
+		return 0
+	}
+	return stamp - catchupSlack
+}
+
+// expireMetrics deletes metrics not updated in the expiration every hour
+// until promoted, the same as the detector of the primary.
+func (r *Replication) expireMetrics() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.promoted:
+			// The detector takes over.
+			return
+		case <-ticker.C:
+		}
+		n, err := r.expire(uint32(time.Now().Unix()) - r.cfg.MetricExpiration())
+		if err != nil {
+			log.Errorf("replication expire metrics: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Infof("replication: %d metrics expired", n)
+		}
+	}
+}
+
+// expire deletes metrics whose index is not updated since the stamp, returns
+// the number of metrics deleted.
+func (r *Replication) expire(stamp uint32) (int, error) {
+	n := 0
+	for _, idx := range r.db.Index.All() {
+		if idx.Stamp >= stamp {
+			continue
+		}
+		r.applyLock.Lock()
+		ok, err := r.db.ExpireMetric(idx.Name, stamp)
+		r.applyLock.Unlock()
+		if err == indexdb.ErrNotFound {
+			// Deleted meanwhile.
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// apply a record to storage.
+func (r *Replication) apply(rec *record) error {
+	r.applyLock.Lock()
+	defer r.applyLock.Unlock()
+	switch {
+	case rec.Admin != nil:
+		err := r.db.Admin.Load(rec.Admin)
+		if err == admindb.ErrLoadDialect {
+			// Shared admin db.
+			return nil
+		}
+		return err
+	case rec.Index != nil:
+		// Links are allocated locally.
+		idx := rec.Index
+		idx.Link = 0
+		if old, err := r.db.Index.Get(idx.Name); err == nil {
+			idx.Link = old.Link
+		}
+		if err := r.db.Index.Put(idx); err != nil {
+			return err
+		}
+		for _, m := range rec.Metrics {
+			m.Name = idx.Name
+			m.LinkTo(idx)
+			if err := r.db.Metric.Put(m); err != nil {
+				return err
+			}
+		}
+	case len(rec.Delete) > 0:
+		if err := r.db.DeleteMetric(rec.Delete); err != nil && err != indexdb.ErrNotFound {
+			return err
+		}
+	case len(rec.DeletePattern) > 0:
+		if _, err := r.db.DeleteMetrics(rec.DeletePattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+// Package replication replicates the storage of a banshee node to replicas
+// for high availability.
+//
+// A primary accepts replicas on replication.port, and streams the metrics
+// detected with their indexes, the metrics deleted and the admin data to
+// them. A replica follows the primary at replication.primary and applies the
+// stream to its storage, with the detector and alerter inactive. Metrics
+// expired are deleted by a replica itself as well, for the deletions missed
+// while disconnected. Once the primary is down, a replica can be promoted to
+// primary via the webapp api:
+//
+//	POST /api/replication/promote
+//
+// Protocol
+//
+// Messages are newline delimited json over tcp. A replica sends a handshake
+// with the stamp to catch up since, which is a bit earlier than the latest
+// index it has. The primary sends the admin data and the metrics stored since
+// the stamp, then the live records, and heartbeats if idle:
+//
+//	replica -> primary: {"since": 1449481993}
+//	primary -> replica: {"admin": {...}}
+//	primary -> replica: {"index": {...}, "metrics": [...]}
+//	primary -> replica: {"delete": "timer.count_ps.foo"}
+//	primary -> replica: {}
+//
+// A replica too slow to keep up is disconnected, it catches up again after
+// reconnected. A new replica gets the raw metrics in the expiration, rollups
+// are built by itself.
+//
+// Admin data is replicated only if both nodes are on sqlite3, a mysql or
+// postgres admin db should be shared by the nodes already. Event history is
+// not replicated.
+package replication
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/storage/admindb"
+	"github.com/eleme/banshee/util/log"
+)
+
+const (
+	// Max number of records buffered for a replica.
+	bufferSize = 64 * 1024
+	// Timeout to connect, handshake or write.
+	timeout = 5 * time.Second
+	// Interval to send heartbeats if idle, a connection is closed if nothing
+	// is received in 3 intervals.
+	heartbeatInterval = 5 * time.Second
+	// Interval to check admin data changes.
+	adminSyncInterval = 10 * time.Second
+	// Max delay to reconnect to the primary.
+	maxRetryDelay = 30 * time.Second
+	// Time to catch up before the latest index on a replica, for the records
+	// lost on disconnected.
+	catchupSlack = 10 * config.Minute
+)
+
+// ErrNotReplica is returned on promoting a node not a replica.
+var ErrNotReplica = errors.New("replication: not a replica")
+
+// handshake is sent by a replica on connected.
+type handshake struct {
+	// Stamp to catch up since.
+	Since uint32 `json:"since"`
+}
+
+// record is a change streamed from a primary to replicas, an empty record is
+// a heartbeat.
+type record struct {
+	// Admin data.
+	Admin *admindb.Dump `json:"admin,omitempty"`
+	// Index and its metrics saved.
+	Index   *models.Index    `json:"index,omitempty"`
+	Metrics []*models.Metric `json:"metrics,omitempty"`
+	// Metric deleted by name.
+	Delete string `json:"delete,omitempty"`
+	// Metrics deleted by pattern.
+	DeletePattern string `json:"deletePattern,omitempty"`
+}
+
+// Status is the replication status of a node.
+type Status struct {
+	Role string `json:"role"`
+	// Primary address and if it's connected, on replica.
+	Primary   string `json:"primary,omitempty"`
+	Connected bool   `json:"connected"`
+	// Replica addresses connected, on primary.
+	Replicas []string `json:"replicas"`
+}
+
+// Replication is to replicate a node, as either a primary or a replica.
+type Replication struct {
+	cfg  *config.Config
+	db   *storage.DB
+	lock sync.RWMutex // protects fields below
+	role string
+	// Replicas connected, on primary.
+	replicas map[*replica]bool
+	// Connection to primary, on replica.
+	conn net.Conn
+	// Closed on the node becomes primary.
+	promoted chan struct{}
+	// Serializes applying records and expiring metrics, on replica.
+	applyLock sync.Mutex
+}
+
+// New creates a Replication with the role in config.
+func New(cfg *config.Config, db *storage.DB) *Replication {
+	r := &Replication{
+		cfg:      cfg,
+		db:       db,
+		role:     cfg.Replication.Role,
+		replicas: make(map[*replica]bool),
+		promoted: make(chan struct{}),
+	}
+	if r.role == config.ReplicationRolePrimary {
+		close(r.promoted)
+	}
+	return r
+}
+
+// Start serving replicas if the node is primary, or following the primary if
+// the node is replica.
+func (r *Replication) Start() {
+	if !r.IsPrimary() {
+		go r.follow()
+		go r.expireMetrics()
+		return
+	}
+	if r.cfg.Replication.Port != 0 {
+		go r.serve()
+	}
+}
+
+// IsPrimary returns true if the node is primary.
+func (r *Replication) IsPrimary() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.role == config.ReplicationRolePrimary
+}
+
+// Promoted returns a channel closed once the node is primary.
+func (r *Replication) Promoted() <-chan struct{} {
+	return r.promoted
+}
+
+// Promote the replica to primary, it stops following and starts serving
+// replicas. Returns ErrNotReplica if the node is primary already.
+func (r *Replication) Promote() error {
+	r.lock.Lock()
+	if r.role == config.ReplicationRolePrimary {
+		r.lock.Unlock()
+		return ErrNotReplica
+	}
+	r.role = config.ReplicationRolePrimary
+	close(r.promoted)
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	r.lock.Unlock()
+	log.Warnf("replication: promoted to primary")
+	if r.cfg.Replication.Port != 0 {
+		go r.serve()
+	}
+	return nil
+}
+
+// Status returns the replication status.
+func (r *Replication) Status() *Status {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	st := &Status{Role: r.role, Replicas: make([]string, 0, len(r.replicas))}
+	if r.role == config.ReplicationRoleReplica {
+		st.Primary = r.cfg.Replication.Primary
+		st.Connected = r.conn != nil
+	}
+	for rep := range r.replicas {
+		st.Replicas = append(st.Replicas, rep.addr)
+	}
+	sort.Strings(st.Replicas)
+	return st
+}
+
+// Put publishes a metric and its index saved to replicas.
+func (r *Replication) Put(m *models.Metric, idx *models.Index) {
+	if !r.hasReplicas() {
+		return
+	}
+	mc := *m
+	mc.TestedRules = nil
+	r.publish(&record{Index: idx.Copy(), Metrics: []*models.Metric{&mc}})
+}
+
+// Delete publishes a metric deleted by name to replicas.
+func (r *Replication) Delete(name string) {
+	if r.hasReplicas() {
+		r.publish(&record{Delete: name})
+	}
+}
+
+// DeletePattern publishes metrics deleted by pattern to replicas.
+func (r *Replication) DeletePattern(pattern string) {
+	if r.hasReplicas() {
+		r.publish(&record{DeletePattern: pattern})
+	}
+}
+
+// hasReplicas returns true if any replicas connected.
+func (r *Replication) hasReplicas() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return len(r.replicas) > 0
+}
+
+// publish a record to replicas, replicas with the buffer full are
+// disconnected.
+func (r *Replication) publish(rec *record) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for rep := range r.replicas {
+		select {
+		case rep.ch <- rec:
+		default:
+			log.Errorf("replica %s is too slow, disconnecting..", rep.addr)
+			rep.close()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package replication
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/util"
+)
+
+// openTestDB opens a storage for testing.
+func openTestDB(fileName string) *storage.DB {
+	opts := &storage.Options{Interval: 10, Period: 86400, Expiration: 86400 * 7}
+	db, err := storage.Open(fileName, opts)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// waitFor waits until the function returns true or timeout.
+func waitFor(fn func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if fn() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestReplicate(t *testing.T) {
+	// Primary
+	db1 := openTestDB("replication_test1")
+	defer os.RemoveAll("replication_test1")
+	defer db1.Close()
+	cfg1 := config.New()
+	p := New(cfg1, db1)
+	util.Must(t, p.IsPrimary())
+	// Stored before the replica connected.
+	now := uint32(time.Now().Unix())
+	idx := &models.Index{Name: "foo;host=a", Tags: map[string]string{"host": "a"}, Stamp: now - 10, Score: 1.2}
+	util.Must(t, db1.Index.Put(idx) == nil)
+	util.Must(t, db1.Metric.Put(&models.Metric{Link: idx.Link, Stamp: now - 10, Value: 1}) == nil)
+	util.Must(t, db1.Admin.DB().Create(&models.Rule{Pattern: "foo", TrendUp: true}).Error == nil)
+	// Replica
+	db2 := openTestDB("replication_test2")
+	defer os.RemoveAll("replication_test2")
+	defer db2.Close()
+	db2.Index.Put(&models.Index{Name: "bar", Stamp: now})
+	cfg2 := config.New()
+	cfg2.Replication.Role = config.ReplicationRoleReplica
+	cfg2.Replication.Primary = "127.0.0.1:0"
+	r := New(cfg2, db2)
+	util.Must(t, !r.IsPrimary())
+	// Connect
+	c1, c2 := net.Pipe()
+	go p.handle(c1)
+	go r.receive(c2)
+	// Catch up
+	util.Must(t, waitFor(func() bool { return db2.Index.Has("foo;host=a") }))
+	util.Must(t, waitFor(func() bool { return db2.Admin.RulesCache.Len() == 1 }))
+	util.Must(t, waitFor(func() bool { return len(p.Status().Replicas) == 1 }))
+	util.Must(t, r.Status().Connected)
+	idx2, _ := db2.Index.Get("foo;host=a")
+	util.Must(t, idx2.Score == 1.2 && idx2.Tags["host"] == "a")
+	ms, err := db2.Metric.Get(idx2.Name, idx2.Link, now-20, now+1)
+	util.Must(t, err == nil && len(ms) == 1 && ms[0].Value == 1)
+	// Live
+	idx.Stamp = now
+	util.Must(t, db1.Index.Put(idx) == nil)
+	m := &models.Metric{Name: idx.Name, Link: idx.Link, Stamp: now, Value: 2}
+	util.Must(t, db1.Metric.Put(m) == nil)
+	p.Put(m, idx)
+	util.Must(t, waitFor(func() bool {
+		ms, _ := db2.Metric.Get(idx2.Name, idx2.Link, now-20, now+1)
+		return len(ms) == 2
+	}))
+	p.Delete("bar")
+	util.Must(t, waitFor(func() bool { return !db2.Index.Has("bar") }))
+	// Expire on replica.
+	util.Must(t, db2.Index.Put(&models.Index{Name: "baz", Stamp: now - 2*cfg2.Expiration}) == nil)
+	n, err := r.expire(now - cfg2.Expiration)
+	util.Must(t, err == nil && n == 1 && !db2.Index.Has("baz") && db2.Index.Has("foo;host=a"))
+	// Promote
+	util.Must(t, p.Promote() == ErrNotReplica)
+	util.Must(t, r.Promote() == nil)
+	util.Must(t, r.IsPrimary())
+	<-r.Promoted()
+	util.Must(t, waitFor(func() bool { return len(p.Status().Replicas) == 0 || !r.Status().Connected }))
+}
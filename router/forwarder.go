@@ -0,0 +1,82 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package router
+
+import (
+	"bufio"
+	"net"
+	"time"
+
+	"github.com/eleme/banshee/util/log"
+)
+
+const (
+	// Max number of lines buffered for a node.
+	bufferSize = 64 * 1024
+	// Timeout to connect or write to a node.
+	timeout = 3 * time.Second
+	// Max delay to reconnect to a node.
+	maxRetryDelay = 30 * time.Second
+)
+
+// forwarder forwards lines to a node over a persistent tcp connection.
+type forwarder struct {
+	addr string
+	ch   chan string
+}
+
+// newForwarder creates a forwarder for the address.
+func newForwarder(addr string) *forwarder {
+	return &forwarder{addr: addr, ch: make(chan string, bufferSize)}
+}
+
+// forward queues a line, the line is dropped if the buffer is full.
+func (f *forwarder) forward(line string) {
+	select {
+	case f.ch <- line:
+	default:
+		log.Errorf("router buffer for %s is full, dropping..", f.addr)
+	}
+}
+
+// start connects to the node and writes lines, reconnects with backoff on
+// errors.
+func (f *forwarder) start() {
+	delay := time.Second
+	for {
+		conn, err := net.DialTimeout("tcp", f.addr, timeout)
+		if err != nil {
+			log.Errorf("router cannot connect to %s: %v, retrying in %s..", f.addr, err, delay)
+			time.Sleep(delay)
+			if delay *= 2; delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+			continue
+		}
+		log.Infof("router connected to %s", f.addr)
+		delay = time.Second
+		if err := f.write(conn); err != nil {
+			log.Errorf("router write to %s: %v, reconnecting..", f.addr, err)
+		}
+		conn.Close()
+	}
+}
+
+// write writes lines to the connection until an error occurs, the line
+// failed to write is lost.
+func (f *forwarder) write(conn net.Conn) error {
+	w := bufio.NewWriter(conn)
+	for line := range f.ch {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := w.WriteString(line); err != nil {
+			return err
+		}
+		if len(f.ch) == 0 {
+			// Flush if no more lines.
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+// Package router forwards metrics to banshee nodes in cluster mode.
+//
+// Each metric is owned by a node, by consistent hashing of the metric name
+// with tags sorted, so indexes and data of a metric are on the same node.
+// The router accepts the detector's tcp protocol and forwards each line to
+// the detector of the node owning the metric:
+//
+//	Name	Stamp		Value	\n
+//	foo		1449481993	3.145	\n
+//
+// Lines are buffered for each node, and dropped if the node is down long
+// enough to fill the buffer.
+//
+// Nodes also forward the metrics received but owned by other nodes with the
+// router, from the detector's udp, graphite and influxdb servers and the
+// webapp, see Forward.
+package router
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/hashring"
+	"github.com/eleme/banshee/util/log"
+)
+
+// ErrProtocol is returned on invalid input lines.
+var ErrProtocol = errors.New("router: invalid protocol")
+
+// Router is to forward metrics to nodes.
+type Router struct {
+	cfg  *config.Config
+	ring *hashring.Ring
+	fwds map[string]*forwarder
+}
+
+// New creates a router for the cluster nodes.
+func New(cfg *config.Config) *Router {
+	r := &Router{cfg: cfg, fwds: make(map[string]*forwarder, len(cfg.Cluster.Nodes))}
+	names := make([]string, 0, len(cfg.Cluster.Nodes))
+	for _, node := range cfg.Cluster.Nodes {
+		names = append(names, node.Name)
+		r.fwds[node.Name] = newForwarder(node.Detector)
+	}
+	r.ring = hashring.New(hashring.DefaultReplicas, names...)
+	return r
+}
+
+// Node returns the name of the node owning the metric.
+func (r *Router) Node(name string) (string, error) {
+	m := &models.Metric{Name: name}
+	if err := m.NormalizeTags(); err != nil {
+		return "", err
+	}
+	return r.ring.Get(m.Name), nil
+}
+
+// Owns returns true if the metric is owned by this node, the metric name
+// should be normalized.
+func (r *Router) Owns(m *models.Metric) bool {
+	return r.ring.Get(m.Name) == r.cfg.Cluster.Node
+}
+
+// Forward forwards the metric to the node owning it in the detector's tcp
+// protocol, the metric name should be normalized. Returns false if the
+// metric is owned by this node.
+func (r *Router) Forward(m *models.Metric) bool {
+	node := r.ring.Get(m.Name)
+	if node == r.cfg.Cluster.Node {
+		return false
+	}
+	value := strconv.FormatFloat(m.Value, 'f', -1, 64)
+	r.fwds[node].forward(fmt.Sprintf("%s %d %s\n", m.Name, m.Stamp, value))
+	return true
+}
+
+// Start the forwarders, and the tcp server if the router port is set.
+func (r *Router) Start() {
+	for _, fwd := range r.fwds {
+		go fwd.start()
+	}
+	if r.cfg.Cluster.RouterPort == 0 {
+		return
+	}
+	// Listen
+	addr := fmt.Sprintf("0.0.0.0:%d", r.cfg.Cluster.RouterPort)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+	log.Infof("router is listening on tcp://%s", addr)
+	// Accept
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Errorf("cannot accept conn: %v, skipping..", err)
+			continue
+		}
+		go r.handle(conn)
+	}
+}
+
+// handle reads lines from the connection and routes them.
+func (r *Router) handle(conn net.Conn) {
+	addr := conn.RemoteAddr()
+	log.Infof("router conn %s established", addr)
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		if err := r.route(scanner.Text()); err != nil {
+			log.Errorf("route error: %v, skipping..", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Errorf("read error: %v, closing conn..", err)
+	}
+	conn.Close()
+	log.Infof("router conn %s disconnected", addr)
+}
+
+// route forwards a line to the node owning the metric, empty lines are
+// ignored.
+func (r *Router) route(line string) error {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return nil
+	}
+	if len(words) != 3 {
+		return ErrProtocol
+	}
+	node, err := r.Node(words[0])
+	if err != nil {
+		return err
+	}
+	r.fwds[node].forward(strings.Join(words, " ") + "\n")
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package router
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util"
+	"gopkg.in/yaml.v2"
+)
+
+// newTestConfig creates a config with the nodes.
+func newTestConfig(nodes ...string) *config.Config {
+	cfg := config.New()
+	s := "cluster:\n  node: node1\n  nodes:\n"
+	for i, addr := range nodes {
+		s += fmt.Sprintf("    - {name: node%d, detector: \"%s\", webapp: \"%s\"}\n", i+1, addr, addr)
+	}
+	if err := yaml.Unmarshal([]byte(s), cfg); err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+func TestNode(t *testing.T) {
+	r := New(newTestConfig("127.0.0.1:2015", "127.0.0.2:2015", "127.0.0.3:2015"))
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		n, err := r.Node(fmt.Sprintf("foo.%d;b=2;a=1", i))
+		util.Must(t, err == nil)
+		// Tags are sorted before hashing.
+		m, _ := r.Node(fmt.Sprintf("foo.%d;a=1;b=2", i))
+		util.Must(t, n == m)
+		counts[n]++
+	}
+	util.Must(t, len(counts) == 3)
+	// Invalid tags.
+	_, err := r.Node("foo;a")
+	util.Must(t, err != nil)
+}
+
+func TestRoute(t *testing.T) {
+	// Fake node.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	util.Must(t, err == nil)
+	defer ln.Close()
+	r := New(newTestConfig(ln.Addr().String()))
+	go r.fwds["node1"].start()
+	// Route
+	util.Must(t, r.route("") == nil)
+	util.Must(t, r.route("foo 1") == ErrProtocol)
+	util.Must(t, r.route("foo  1449481993\t3.14") == nil)
+	// Receive
+	conn, err := ln.Accept()
+	util.Must(t, err == nil)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	util.Must(t, err == nil)
+	util.Must(t, line == "foo 1449481993 3.14\n")
+}
+
+func TestForward(t *testing.T) {
+	r := New(newTestConfig("127.0.0.1:2015", "127.0.0.2:2015", "127.0.0.3:2015"))
+	n := 0
+	for i := 0; i < 30; i++ {
+		m := &models.Metric{Name: fmt.Sprintf("foo.%d", i), Stamp: 1449481993, Value: 3.14}
+		node, _ := r.Node(m.Name)
+		util.Must(t, r.Owns(m) == (node == "node1"))
+		util.Must(t, r.Forward(m) == !r.Owns(m))
+		if node != "node1" {
+			n++
+			line := <-r.fwds[node].ch
+			util.Must(t, line == fmt.Sprintf("foo.%d 1449481993 3.14\n", i))
+		}
+	}
+	util.Must(t, n > 0 && len(r.fwds["node1"].ch) == 0)
+}
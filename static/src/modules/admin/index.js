@@ -42,6 +42,13 @@ var app = angular.module('banshee.admin', [])
         }
       })
 
+      // Detector setting router
+      .state('banshee.admin.setting', {
+        url: '/setting',
+        templateUrl: 'modules/admin/setting/list.html',
+        controller: 'AdminSettingListCtrl'
+      })
+
       // Config router
       .state('banshee.admin.config', {
         url: '/config',
@@ -68,6 +75,9 @@ var app = angular.module('banshee.admin', [])
   .controller('AdminUserDetailCtrl', require('./user/AdminUserDetailCtrl'))
   .controller('UserAddModalCtrl', require('./user/UserAddModalCtrl'))
 
+  .controller('AdminSettingListCtrl', require('./setting/AdminSettingListCtrl'))
+  .controller('SettingModalCtrl', require('./setting/SettingModalCtrl'))
+
   .controller('AdminConfigCtrl', require('./config/AdminConfigCtrl'))
   .controller('AdminInfoCtrl', require('./info/AdminInfoCtrl'));
 
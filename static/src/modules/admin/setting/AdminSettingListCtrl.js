@@ -0,0 +1,62 @@
+/*@ngInject*/
+module.exports = function ($scope, $mdDialog, $translate, toastr, DetectorSetting) {
+  $scope.kinds = ['blacklist', 'default_threshold_max', 'default_threshold_min', 'fill_blank_zeros'];
+  $scope.search = {
+    kind: ''
+  };
+
+  $scope.loadData = function () {
+    DetectorSetting.getAll().$promise
+      .then(function (res) {
+        $scope.settings = res;
+      });
+  };
+
+  $scope.hasValue = function (setting) {
+    return setting.kind === 'default_threshold_max' || setting.kind === 'default_threshold_min';
+  };
+
+  $scope.openModal = function (event, setting) {
+    $mdDialog.show({
+        controller: 'SettingModalCtrl',
+        templateUrl: 'modules/admin/setting/settingModal.html',
+        parent: angular.element(document.body),
+        targetEvent: event,
+        clickOutsideToClose: true,
+        fullscreen: true,
+        locals: {
+          params: {
+            obj: setting ? angular.copy(setting) : null,
+            kinds: $scope.kinds
+          }
+        }
+      })
+      .then(function () {
+        $scope.loadData();
+      });
+  };
+
+  $scope.deleteSetting = function (event, setting) {
+    var confirm = $mdDialog.confirm()
+      .title($translate.instant('ADMIN_SETTING_DELETE_TEXT'))
+      .textContent($translate.instant('ADMIN_SETTING_DELETE_WARN'))
+      .ariaLabel($translate.instant('ADMIN_SETTING_DELETE_TEXT'))
+      .targetEvent(event)
+      .ok($translate.instant('YES'))
+      .cancel($translate.instant('NO'));
+    $mdDialog.show(confirm).then(function () {
+      DetectorSetting.delete({
+          id: setting.id
+        }).$promise
+        .then(function () {
+          $scope.settings.splice($scope.settings.indexOf(setting), 1);
+          toastr.success($translate.instant('DELETE_SUCCESS'));
+        })
+        .catch(function (err) {
+          toastr.error(err.msg);
+        });
+    });
+  };
+
+  $scope.loadData();
+};
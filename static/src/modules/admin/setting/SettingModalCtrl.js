@@ -0,0 +1,31 @@
+/*@ngInject*/
+module.exports = function ($scope, toastr, $mdDialog, DetectorSetting, params) {
+  $scope.kinds = params.kinds;
+  $scope.isEdit = !!params.obj;
+
+  $scope.setting = params.obj ? params.obj : {kind: params.kinds[0]};
+
+  $scope.hasValue = function () {
+    return $scope.setting.kind === 'default_threshold_max' || $scope.setting.kind === 'default_threshold_min';
+  };
+
+  $scope.cancel = function() {
+    $mdDialog.cancel();
+  };
+
+  $scope.submit = function() {
+    if (!$scope.hasValue()) {
+      $scope.setting.value = 0;
+    }
+    var req = $scope.isEdit ?
+      DetectorSetting.update($scope.setting) :
+      DetectorSetting.save($scope.setting);
+    req.$promise
+      .then(function(res) {
+        $mdDialog.hide(res);
+      })
+      .catch(function(err) {
+        toastr.error(err.msg);
+      });
+  };
+};
@@ -253,6 +253,7 @@ module.exports = function($scope, $rootScope, $timeout, $stateParams, $translate
           '<a href="#/main?pattern=' + currentEl.name + '" class="' + className + '">',
           getTextByTrend(currentEl.score),
           currentEl.name,
+          currentEl.algorithm ? ' <small>(' + currentEl.algorithm + ')</small>' : '',
           '</a>',
           _box.join('')
       ].join('');
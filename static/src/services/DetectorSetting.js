@@ -0,0 +1,14 @@
+/*@ngInject*/
+module.exports = function ($resource) {
+  return $resource('/api/detector/setting/:id', {id: '@id'}, {
+    getAll: {
+      method: 'GET',
+      url: '/api/detector/settings',
+      isArray: true
+    },
+    update: {
+      method: 'PATCH',
+      url: '/api/detector/setting/:id'
+    }
+  });
+};
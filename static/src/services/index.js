@@ -14,6 +14,7 @@ app
   .factory('User', require('./User'))
   .factory('Rule', require('./Rule'))
   .factory('Config', require('./Config'))
+  .factory('DetectorSetting', require('./DetectorSetting'))
   .factory('Metric', require('./Metric'))
   .factory('Info', require('./Info'))
   .factory('Version', require('./Version'))
@@ -7,6 +7,7 @@ import (
 	"github.com/eleme/banshee/util/log"
 	"github.com/eleme/banshee/util/safemap"
 	"github.com/jinzhu/gorm"
+	"sync"
 )
 
 type rulesCache struct {
@@ -15,6 +16,9 @@ type rulesCache struct {
 	// Listeners
 	lnsAdd []chan *models.Rule
 	lnsDel []chan *models.Rule
+	// Rules in db on last loading, by id.
+	synced map[int]*models.Rule
+	lock   sync.Mutex // protects synced
 }
 
 // newRulesCache creates a rulesCache.
@@ -36,14 +40,63 @@ func (c *rulesCache) Init(db *gorm.DB) error {
 		return err
 	}
 	// Load
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.synced = make(map[int]*models.Rule, len(rules))
 	for i := 0; i < len(rules); i++ {
 		rule := &rules[i]
+		c.synced[rule.ID] = rule.Copy()
 		rule.Share()
 		c.rules.Set(rule.ID, rule)
 	}
 	return nil
 }
 
+// Sync loads rules from db and applies the changes since the last loading
+// to cache, listeners are notified as well. Changes made in this instance
+// are already in cache, so only the changes by other instances sharing the
+// db take effect.
+func (c *rulesCache) Sync(db *gorm.DB) error {
+	// Rules in cache before the query, the ones put later are kept.
+	cached := c.rules.Items()
+	// Query
+	var rules []models.Rule
+	err := db.Find(&rules).Error
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	synced := make(map[int]*models.Rule, len(rules))
+	for i := 0; i < len(rules); i++ {
+		rule := &rules[i]
+		synced[rule.ID] = rule
+		if last, ok := c.synced[rule.ID]; ok && last.Equal(rule) {
+			// Not changed.
+			continue
+		}
+		if r, ok := c.Get(rule.ID); ok {
+			if r.Equal(rule) {
+				// Already in cache.
+				continue
+			}
+			// Updated.
+			c.Delete(rule.ID)
+		}
+		c.Put(rule)
+	}
+	// Deleted, also the ones put in this instance and deleted by others
+	// before the sync.
+	for k := range cached {
+		id := k.(int)
+		if _, ok := synced[id]; !ok {
+			c.Delete(id)
+		}
+	}
+	c.synced = synced
+	return nil
+}
+
 // Len returns the number of rules in cache.
 func (c *rulesCache) Len() int {
 	return c.rules.Len()
@@ -11,7 +11,7 @@ import (
 
 func TestInit(t *testing.T) {
 	fileName := "db-testing"
-	db, _ := Open(fileName)
+	db, _ := Open(fileName, nil)
 	defer db.Close()
 	defer os.RemoveAll(fileName)
 	rule1 := &models.Rule{Pattern: "a.b.*"}
@@ -36,3 +36,62 @@ func TestInit(t *testing.T) {
 	util.Must(t, ok)
 	util.Must(t, r3.Pattern == rule3.Pattern)
 }
+
+func TestSync(t *testing.T) {
+	// Two instances sharing a db.
+	fileName := "db-testing"
+	db1, _ := Open(fileName, nil)
+	defer db1.Close()
+	defer os.RemoveAll(fileName)
+	db2, _ := Open(fileName, nil)
+	defer db2.Close()
+	addCh := make(chan *models.Rule, 8)
+	delCh := make(chan *models.Rule, 8)
+	db2.RulesCache.OnAdd(addCh)
+	db2.RulesCache.OnDel(delCh)
+	// Add by db1.
+	rule := &models.Rule{Pattern: "a.b.*"}
+	util.Must(t, db1.DB().Create(rule).Error == nil)
+	db1.RulesCache.Put(rule)
+	util.Must(t, db1.RulesCache.Sync(db1.DB()) == nil)
+	util.Must(t, db2.RulesCache.Sync(db2.DB()) == nil)
+	r, ok := db2.RulesCache.Get(rule.ID)
+	util.Must(t, ok && r.Pattern == "a.b.*")
+	util.Must(t, len(addCh) == 1 && len(delCh) == 0)
+	<-addCh
+	// Nothing changed.
+	util.Must(t, db2.RulesCache.Sync(db2.DB()) == nil)
+	util.Must(t, len(addCh) == 0 && len(delCh) == 0)
+	// Update by db1.
+	util.Must(t, db1.DB().Model(rule).Update("comment", "foo").Error == nil)
+	util.Must(t, db2.RulesCache.Sync(db2.DB()) == nil)
+	r, _ = db2.RulesCache.Get(rule.ID)
+	util.Must(t, r.Comment == "foo")
+	util.Must(t, len(addCh) == 1 && len(delCh) == 1)
+	<-addCh
+	<-delCh
+	// Delete by db1.
+	util.Must(t, db1.DB().Delete(rule).Error == nil)
+	util.Must(t, db2.RulesCache.Sync(db2.DB()) == nil)
+	_, ok = db2.RulesCache.Get(rule.ID)
+	util.Must(t, !ok)
+	util.Must(t, len(addCh) == 0 && len(delCh) == 1)
+	<-delCh
+	// Changes made by itself are not notified again.
+	rule = &models.Rule{Pattern: "a.c.*"}
+	util.Must(t, db2.DB().Create(rule).Error == nil)
+	db2.RulesCache.Put(rule)
+	<-addCh
+	util.Must(t, db2.RulesCache.Sync(db2.DB()) == nil)
+	util.Must(t, len(addCh) == 0 && len(delCh) == 0)
+	// Put by itself and deleted by db1 before the sync.
+	rule = &models.Rule{Pattern: "a.d.*"}
+	util.Must(t, db2.DB().Create(rule).Error == nil)
+	db2.RulesCache.Put(rule)
+	<-addCh
+	util.Must(t, db1.DB().Delete(rule).Error == nil)
+	util.Must(t, db2.RulesCache.Sync(db2.DB()) == nil)
+	_, ok = db2.RulesCache.Get(rule.ID)
+	util.Must(t, !ok)
+	util.Must(t, len(addCh) == 0 && len(delCh) == 1)
+}
@@ -3,35 +3,66 @@
 package admindb
 
 import (
+	"sync"
+	"time"
+
 	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/dbutil"
 	"github.com/eleme/banshee/util/log"
+	_ "github.com/go-sql-driver/mysql" // Import but no use
 	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"           // Import but no use
 	_ "github.com/mattn/go-sqlite3" // Import but no use
 )
 
-// SQL db dialect
+// Default SQL db dialect
 const dialect = "sqlite3"
 
 // Gorm logging?
 const gormLogMode = false
 
+// Options is to open DB.
+type Options struct {
+	// SQL dialect, one of sqlite3, mysql and postgres, default sqlite3.
+	Dialect string
+	// Data source name for mysql and postgres, sqlite3 uses the fileName.
+	DSN string
+	// Interval in seconds to sync changes by other instances sharing a
+	// mysql or postgres db, 0 for no syncing.
+	SyncInterval uint32
+}
+
 // DB handles admin storage.
 type DB struct {
 	// DB
-	db *gorm.DB
+	db      *gorm.DB
+	dialect string
 	// Cache
 	RulesCache *rulesCache
+	// Rules syncing goroutine.
+	quit chan struct{}
+	wg   sync.WaitGroup
+	// Listeners of syncing.
+	lnsSync []chan struct{}
+	lnsLock sync.Mutex // protects lnsSync
 }
 
-// Open DB by fileName.
-func Open(fileName string) (*DB, error) {
+// Open DB by fileName and options.
+func Open(fileName string, opts *Options) (*DB, error) {
+	// Dialect
+	dia, dsn := dialect, fileName
+	if opts != nil && len(opts.Dialect) > 0 && opts.Dialect != dialect {
+		dia, dsn = opts.Dialect, opts.DSN
+	}
 	// Open
-	gdb, err := gorm.Open(dialect, fileName)
+	gdb, err := gorm.Open(dia, dsn)
 	if err != nil {
 		return nil, err
 	}
 	db := new(DB)
 	db.db = &gdb
+	db.dialect = dia
+	db.quit = make(chan struct{})
 	// Migration
 	if err := db.migrate(); err != nil {
 		return nil, err
@@ -43,14 +74,31 @@ func Open(fileName string) (*DB, error) {
 	}
 	// Log Mode
 	db.db.LogMode(gormLogMode)
+	// Sync rules only if the db may be shared.
+	if dia != dialect && opts.SyncInterval > 0 {
+		db.wg.Add(1)
+		go db.syncRules(time.Duration(opts.SyncInterval) * time.Second)
+	}
+	log.Debugf("admindb opened on %s", dia)
 	return db, nil
 }
 
 // Close DB.
 func (db *DB) Close() error {
+	close(db.quit)
+	db.wg.Wait()
 	return db.db.Close()
 }
 
+// Backup copies the db into a new sqlite file by fileName while running.
+// Returns ErrBackupDialect if the db is not on sqlite3.
+func (db *DB) Backup(fileName string) error {
+	if db.dialect != dialect {
+		return ErrBackupDialect
+	}
+	return dbutil.BackupSQLite(db.db.DB(), fileName)
+}
+
 // DB returns db handle.
 func (db *DB) DB() *gorm.DB {
 	return db.db
@@ -62,5 +110,48 @@ func (db *DB) migrate() error {
 	rule := &models.Rule{}
 	user := &models.User{}
 	proj := &models.Project{}
-	return db.db.AutoMigrate(rule, user, proj).Error
+	snooze := &models.Snooze{}
+	mt := &models.Maintenance{}
+	setting := &models.DetectorSetting{}
+	return db.db.AutoMigrate(rule, user, proj, snooze, mt, setting).Error
+}
+
+// syncRules syncs changes by other instances every interval.
+func (db *DB) syncRules(interval time.Duration) {
+	defer db.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-db.quit:
+			return
+		case <-ticker.C:
+			db.sync()
+		}
+	}
+}
+
+// sync syncs rules changes into cache, and then notifies the listeners of
+// syncing, e.g. to reload the detector settings.
+func (db *DB) sync() {
+	if err := db.RulesCache.Sync(db.db); err != nil {
+		log.Errorf("sync rules: %v", err)
+	}
+	db.lnsLock.Lock()
+	defer db.lnsLock.Unlock()
+	for _, ch := range db.lnsSync {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// Pending
+		}
+	}
+}
+
+// OnSync listens the syncing of changes by other instances, which happens
+// every SyncInterval for a mysql or postgres db.
+func (db *DB) OnSync(ch chan struct{}) {
+	db.lnsLock.Lock()
+	defer db.lnsLock.Unlock()
+	db.lnsSync = append(db.lnsSync, ch)
 }
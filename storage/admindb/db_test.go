@@ -11,7 +11,7 @@ import (
 
 func TestOpen(t *testing.T) {
 	fileName := "db-testing"
-	db, err := Open(fileName)
+	db, err := Open(fileName, nil)
 	util.Must(t, nil == err)
 	util.Must(t, db != nil)
 	util.Must(t, util.IsFileExist(fileName))
@@ -20,4 +20,34 @@ func TestOpen(t *testing.T) {
 	util.Must(t, db.DB().HasTable(&models.User{}))
 	util.Must(t, db.DB().HasTable(&models.Rule{}))
 	util.Must(t, db.DB().HasTable(&models.Project{}))
+	util.Must(t, db.DB().HasTable(&models.Snooze{}))
+	util.Must(t, db.DB().HasTable(&models.Maintenance{}))
+}
+
+func TestOpenDialect(t *testing.T) {
+	fileName := "db-testing"
+	db, err := Open(fileName, &Options{Dialect: "sqlite3", SyncInterval: 1})
+	util.Must(t, err == nil)
+	defer os.RemoveAll(fileName)
+	util.Must(t, db.Backup(fileName+"-backup") == nil)
+	os.Remove(fileName + "-backup")
+	util.Must(t, db.Close() == nil)
+	// Unknown dialect.
+	_, err = Open(fileName, &Options{Dialect: "oracle"})
+	util.Must(t, err != nil)
+}
+
+func TestOnSync(t *testing.T) {
+	fileName := "db-testing"
+	db, err := Open(fileName, nil)
+	util.Must(t, err == nil)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	ch := make(chan struct{}, 1)
+	db.OnSync(ch)
+	db.sync()
+	util.Must(t, len(ch) == 1)
+	// Not blocked by pending notifications.
+	db.sync()
+	util.Must(t, len(ch) == 1)
 }
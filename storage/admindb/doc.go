@@ -2,7 +2,7 @@
 
 /*
 
-Package admindb handles the admin storage on SQLite3.
+Package admindb handles the admin storage on SQLite3, MySQL or PostgreSQL.
 
 Auto Migration
 
@@ -11,12 +11,16 @@ will be automatically created on the first time banshee starts.
 
 Persistence
 
-Users, Rules and Projects are stored on disk in sqlite3, the
+Users, Rules and Projects are stored on disk in sqlite3 by default, the
 relation between them is:
 
 	User:Project    N:M
 	Rule:Project    N:1
 
+Snoozes (including acknowledgements) of metrics on rules and maintenance
+windows of projects or rules are also stored in admindb, and checked by the
+alerter before sending notifications.
+
 To get gorm DB handle:
 
 	adminDBInstance.DB()
@@ -30,5 +34,13 @@ To get the rulesCache handle:
 
 	adminDBInstance.RulesCache
 
+Shared DB
+
+Multiple banshee instances can share the admin storage on MySQL or
+PostgreSQL. Each instance reloads rules every sync interval, and applies the
+changes made by other instances to its rules cache, with the listeners
+notified just like the changes made by itself. Listeners of OnSync are
+notified after each syncing, e.g. the filter reloads the detector settings.
+
 */
 package admindb
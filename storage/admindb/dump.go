@@ -0,0 +1,112 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package admindb
+
+import (
+	"github.com/eleme/banshee/models"
+	"github.com/jinzhu/gorm"
+)
+
+// Dump is all the data in admindb, to replicate the db.
+type Dump struct {
+	Projects     []models.Project     `json:"projects"`
+	Users        []models.User        `json:"users"`
+	Rules        []models.Rule        `json:"rules"`
+	Snoozes      []models.Snooze      `json:"snoozes"`
+	Maintenances []models.Maintenance `json:"maintenances"`
+	// Detector settings.
+	DetectorSettings []models.DetectorSetting `json:"detectorSettings"`
+	// Project and user id pairs.
+	ProjectUsers [][2]int `json:"projectUsers"`
+}
+
+// Dump returns all the data in db.
+func (db *DB) Dump() (*Dump, error) {
+	d := &Dump{}
+	for _, v := range []interface{}{&d.Projects, &d.Users, &d.Rules, &d.Snoozes, &d.Maintenances, &d.DetectorSettings} {
+		if err := db.db.Find(v).Error; err != nil {
+			return nil, err
+		}
+	}
+	rows, err := db.db.Raw("SELECT project_id, user_id FROM project_users ORDER BY project_id, user_id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pair [2]int
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, err
+		}
+		d.ProjectUsers = append(d.ProjectUsers, pair)
+	}
+	return d, rows.Err()
+}
+
+// Load replaces all the data in db with the dump in a transaction, rules
+// changes are synced into cache then. Returns ErrLoadDialect if the db is not
+// on sqlite3.
+func (db *DB) Load(d *Dump) error {
+	if db.dialect != dialect {
+		return ErrLoadDialect
+	}
+	tx := db.db.Begin()
+	if err := load(tx, d); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	return db.RulesCache.Sync(db.db)
+}
+
+// load replaces all the data in the transaction with the dump.
+func load(tx *gorm.DB, d *Dump) error {
+	// Delete
+	if err := tx.Exec("DELETE FROM project_users").Error; err != nil {
+		return err
+	}
+	for _, v := range []interface{}{&models.Project{}, &models.User{}, &models.Rule{}, &models.Snooze{}, &models.Maintenance{}, &models.DetectorSetting{}} {
+		if err := tx.Delete(v).Error; err != nil {
+			return err
+		}
+	}
+	// Create
+	for i := 0; i < len(d.Projects); i++ {
+		if err := tx.Create(&d.Projects[i]).Error; err != nil {
+			return err
+		}
+	}
+	for i := 0; i < len(d.Users); i++ {
+		if err := tx.Create(&d.Users[i]).Error; err != nil {
+			return err
+		}
+	}
+	for i := 0; i < len(d.Rules); i++ {
+		if err := tx.Create(&d.Rules[i]).Error; err != nil {
+			return err
+		}
+	}
+	for i := 0; i < len(d.Snoozes); i++ {
+		if err := tx.Create(&d.Snoozes[i]).Error; err != nil {
+			return err
+		}
+	}
+	for i := 0; i < len(d.Maintenances); i++ {
+		if err := tx.Create(&d.Maintenances[i]).Error; err != nil {
+			return err
+		}
+	}
+	for i := 0; i < len(d.DetectorSettings); i++ {
+		if err := tx.Create(&d.DetectorSettings[i]).Error; err != nil {
+			return err
+		}
+	}
+	for _, pair := range d.ProjectUsers {
+		if err := tx.Exec("INSERT INTO project_users (project_id, user_id) VALUES (?, ?)", pair[0], pair[1]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
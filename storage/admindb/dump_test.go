@@ -0,0 +1,56 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package admindb
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util"
+)
+
+func TestDumpLoad(t *testing.T) {
+	fileName := "db-testing"
+	db, _ := Open(fileName, nil)
+	defer db.Close()
+	defer os.RemoveAll(fileName)
+	// Data
+	proj := &models.Project{Name: "foo"}
+	util.Must(t, db.DB().Create(proj).Error == nil)
+	user := &models.User{Name: "bar", Email: "bar@example.com"}
+	util.Must(t, db.DB().Create(user).Error == nil)
+	util.Must(t, db.DB().Model(proj).Association("Users").Append(user).Error == nil)
+	rule := &models.Rule{ProjectID: proj.ID, Pattern: "a.*", TrendUp: true}
+	util.Must(t, db.DB().Create(rule).Error == nil)
+	setting := &models.DetectorSetting{Kind: models.DetectorSettingBlackList, Pattern: "statsd.**"}
+	util.Must(t, db.DB().Create(setting).Error == nil)
+	// Dump
+	d, err := db.Dump()
+	util.Must(t, err == nil)
+	util.Must(t, len(d.Projects) == 1 && len(d.Users) == 1 && len(d.Rules) == 1)
+	util.Must(t, len(d.ProjectUsers) == 1 && d.ProjectUsers[0] == [2]int{proj.ID, user.ID})
+	util.Must(t, len(d.DetectorSettings) == 1)
+	b, err := json.Marshal(d)
+	util.Must(t, err == nil)
+	// Load into another db.
+	fileName1 := "db-testing1"
+	db1, _ := Open(fileName1, nil)
+	defer db1.Close()
+	defer os.RemoveAll(fileName1)
+	db1.DB().Create(&models.Rule{Pattern: "b.*", TrendDown: true})
+	d1 := &Dump{}
+	util.Must(t, json.Unmarshal(b, d1) == nil)
+	util.Must(t, db1.Load(d1) == nil)
+	var users []models.User
+	util.Must(t, db1.DB().Model(&models.Project{ID: proj.ID}).Association("Users").Find(&users).Error == nil)
+	util.Must(t, len(users) == 1 && users[0].Name == "bar")
+	var settings []models.DetectorSetting
+	util.Must(t, db1.DB().Find(&settings).Error == nil)
+	util.Must(t, len(settings) == 1 && settings[0].Pattern == "statsd.**")
+	// Rules synced into cache.
+	util.Must(t, db1.RulesCache.Len() == 1)
+	r, ok := db1.RulesCache.Get(rule.ID)
+	util.Must(t, ok && r.Pattern == "a.*" && r.ProjectID == proj.ID)
+}
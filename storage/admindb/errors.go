@@ -0,0 +1,14 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package admindb
+
+import "errors"
+
+var (
+	// ErrBackupDialect is returned when backup a db not on sqlite3, which
+	// should be backed up by the tools of its own.
+	ErrBackupDialect = errors.New("admindb: backup is only supported on sqlite3")
+	// ErrLoadDialect is returned when load a dump into a db not on sqlite3,
+	// which may be shared by other instances.
+	ErrLoadDialect = errors.New("admindb: load is only supported on sqlite3")
+)
@@ -0,0 +1,181 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/eleme/banshee/storage/admindb"
+	"github.com/eleme/banshee/util"
+	"github.com/eleme/banshee/util/log"
+)
+
+// Snapshot copies all child dbs into a new storage directory by fileName
+// while running, the copy can be opened as a storage directly.
+//
+// The leveldb snapshots of indexdb and metricdb are taken back to back before
+// copying, so the indexes and metrics are consistent with each other except
+// for the few metrics put or deleted in between. The sqlite dbs are copied
+// separately, and may be a bit newer.
+func (db *DB) Snapshot(fileName string) error {
+	if util.IsFileExist(fileName) {
+		return ErrBackupExist
+	}
+	// Indexes are put before their metrics, take the metricdb snapshot
+	// first, so that metrics put in between are not left in the copy
+	// without indexes.
+	metricSnap, err := db.Metric.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer metricSnap.Release()
+	indexSnap, err := db.Index.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer indexSnap.Release()
+	if err := os.Mkdir(fileName, filemode); err != nil {
+		return err
+	}
+	// Admindb, skipped if it is not on sqlite3.
+	err = db.Admin.Backup(path.Join(fileName, admindbFileName))
+	if err == admindb.ErrBackupDialect {
+		log.Warnf("storage snapshot: %v, skipping..", err)
+	} else if err != nil {
+		return err
+	}
+	// Indexdb.
+	if err := indexSnap.Backup(path.Join(fileName, indexdbFileName)); err != nil {
+		return err
+	}
+	// Metricdb.
+	if err := metricSnap.Backup(path.Join(fileName, metricdbFileName)); err != nil {
+		return err
+	}
+	// Eventdb.
+	if err := db.Event.Backup(path.Join(fileName, eventdbFileName)); err != nil {
+		return err
+	}
+	log.Infof("storage snapshot %s created", fileName)
+	return nil
+}
+
+// Pack writes the storage directory by fileName as a gzipped tarball to w,
+// the directory should not be in use.
+func Pack(w io.Writer, fileName string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err := filepath.Walk(fileName, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(fileName, name)
+		if err != nil || rel == "." {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Unpack restores a gzipped tarball written by Pack into a new storage
+// directory by fileName.
+func Unpack(r io.Reader, fileName string) error {
+	if util.IsFileExist(fileName) {
+		return ErrRestoreExist
+	}
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	if err := os.Mkdir(fileName, filemode); err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !isBackupFileName(hdr.Name) {
+			return ErrBackupFileName
+		}
+		name := filepath.Join(fileName, filepath.FromSlash(hdr.Name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(name, filemode); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := unpackFile(tr, name, os.FileMode(hdr.Mode)); err != nil {
+				return err
+			}
+		default:
+			return ErrBackupFileName
+		}
+	}
+	log.Infof("storage %s restored", fileName)
+	return nil
+}
+
+// unpackFile writes a regular file from the tarball.
+func unpackFile(r io.Reader, name string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), filemode); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// isBackupFileName returns true if the name in tarball is under a child db.
+func isBackupFileName(name string) bool {
+	if name != path.Clean(name) || path.IsAbs(name) {
+		return false
+	}
+	top := strings.SplitN(name, "/", 2)[0]
+	switch top {
+	case admindbFileName, indexdbFileName, metricdbFileName, eventdbFileName:
+		return true
+	}
+	return false
+}
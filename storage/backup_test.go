@@ -0,0 +1,70 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage/eventdb"
+	"github.com/eleme/banshee/util"
+)
+
+func TestBackupRestore(t *testing.T) {
+	fileName := "storage_test"
+	snapName := "storage_test_snapshot"
+	restoreName := "storage_test_restore"
+	defer os.RemoveAll(fileName)
+	defer os.RemoveAll(snapName)
+	defer os.RemoveAll(restoreName)
+	// Open db and put data.
+	opts := &Options{Interval: 10, Period: 86400, Expiration: 86400 * 7}
+	db, err := Open(fileName, opts)
+	util.Must(t, err == nil)
+	defer db.Close()
+	proj := &models.Project{Name: "foo"}
+	util.Must(t, db.Admin.DB().Create(proj).Error == nil)
+	idx := &models.Index{Name: "foo", Stamp: uint32(time.Now().Unix()), Score: 1.2}
+	util.Must(t, db.Index.Put(idx) == nil)
+	m := &models.Metric{Name: "foo", Link: idx.Link, Stamp: idx.Stamp, Value: 3}
+	util.Must(t, db.Metric.Put(m) == nil)
+	util.Must(t, db.Event.Put(&models.EventRecord{EventID: "foo", Name: "foo", Stamp: idx.Stamp}) == nil)
+	// Snapshot and pack.
+	util.Must(t, db.Snapshot(snapName) == nil)
+	util.Must(t, db.Snapshot(snapName) == ErrBackupExist)
+	var buf bytes.Buffer
+	util.Must(t, Pack(&buf, snapName) == nil)
+	// Unpack and open.
+	util.Must(t, Unpack(bytes.NewReader(buf.Bytes()), restoreName) == nil)
+	util.Must(t, Unpack(bytes.NewReader(buf.Bytes()), restoreName) == ErrRestoreExist)
+	rdb, err := Open(restoreName, opts)
+	util.Must(t, err == nil)
+	defer rdb.Close()
+	var projs []models.Project
+	util.Must(t, rdb.Admin.DB().Find(&projs).Error == nil)
+	util.Must(t, len(projs) == 1 && projs[0].Name == "foo")
+	idx1, err := rdb.Index.Get("foo")
+	util.Must(t, err == nil && idx1.Link == idx.Link && idx1.Score == 1.2)
+	ms, err := rdb.Metric.Get("foo", idx.Link, idx.Stamp, idx.Stamp+1)
+	util.Must(t, err == nil && len(ms) == 1 && ms[0].Value == 3)
+	rs, err := rdb.Event.Query(&eventdb.QueryOptions{Stop: idx.Stamp + 1})
+	util.Must(t, err == nil && len(rs) == 1 && rs[0].EventID == "foo")
+}
+
+func TestUnpackBadFileName(t *testing.T) {
+	restoreName := "storage_test_restore"
+	defer os.RemoveAll(restoreName)
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0644, Typeflag: tar.TypeReg})
+	tw.Close()
+	gw.Close()
+	util.Must(t, Unpack(&buf, restoreName) == ErrBackupFileName)
+	util.Must(t, !util.IsFileExist("evil"))
+}
@@ -3,7 +3,9 @@
 package storage
 
 import (
+	"github.com/eleme/banshee/models"
 	"github.com/eleme/banshee/storage/admindb"
+	"github.com/eleme/banshee/storage/eventdb"
 	"github.com/eleme/banshee/storage/indexdb"
 	"github.com/eleme/banshee/storage/metricdb"
 	"github.com/eleme/banshee/util/log"
@@ -19,12 +21,31 @@ const (
 	admindbFileName  = "admin"
 	indexdbFileName  = "index"
 	metricdbFileName = "metric"
+	eventdbFileName  = "event"
 )
 
 // Options is to open DB.
 type Options struct {
+	Interval   uint32
 	Period     uint32
 	Expiration uint32
+	// Metric rollup tiers.
+	Rollups []RollupOptions
+	// Admin sql db.
+	Admin AdminOptions
+}
+
+// AdminOptions is to open the admin sql db.
+type AdminOptions struct {
+	Dialect      string
+	DSN          string
+	SyncInterval uint32
+}
+
+// RollupOptions is to open a metric rollup tier.
+type RollupOptions struct {
+	Interval   uint32
+	Expiration uint32
 }
 
 // DB handles the storage on leveldb.
@@ -33,6 +54,7 @@ type DB struct {
 	Admin  *admindb.DB
 	Index  *indexdb.DB
 	Metric *metricdb.DB
+	Event  *eventdb.DB
 }
 
 // Open a DB by fileName and options.
@@ -48,7 +70,15 @@ func Open(fileName string, opts *Options) (*DB, error) {
 	}
 	// Admindb.
 	db := new(DB)
-	db.Admin, err = admindb.Open(path.Join(fileName, admindbFileName))
+	var adminOptions *admindb.Options
+	if opts != nil {
+		adminOptions = &admindb.Options{
+			Dialect:      opts.Admin.Dialect,
+			DSN:          opts.Admin.DSN,
+			SyncInterval: opts.Admin.SyncInterval,
+		}
+	}
+	db.Admin, err = admindb.Open(path.Join(fileName, admindbFileName), adminOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -64,11 +94,33 @@ func Open(fileName string, opts *Options) (*DB, error) {
 			Period:     opts.Period,
 			Expiration: opts.Expiration,
 		}
+		for _, rollup := range opts.Rollups {
+			// Each storage of a tier holds the same number of metrics as
+			// the raw ones.
+			factor := uint32(1)
+			if opts.Interval > 0 && rollup.Interval > opts.Interval {
+				factor = rollup.Interval / opts.Interval
+			}
+			options.Rollups = append(options.Rollups, metricdb.RollupOptions{
+				Interval:   rollup.Interval,
+				Period:     opts.Period * factor,
+				Expiration: rollup.Expiration,
+			})
+		}
 	}
 	db.Metric, err = metricdb.Open(path.Join(fileName, metricdbFileName), options)
 	if err != nil {
 		return nil, err
 	}
+	// Eventdb.
+	var eventOptions *eventdb.Options
+	if opts != nil {
+		eventOptions = &eventdb.Options{Expiration: opts.Expiration}
+	}
+	db.Event, err = eventdb.Open(path.Join(fileName, eventdbFileName), eventOptions)
+	if err != nil {
+		return nil, err
+	}
 	log.Debugf("storage is opened successfully")
 	return db, nil
 }
@@ -87,5 +139,67 @@ func (db *DB) Close() error {
 	if err := db.Metric.Close(); err != nil {
 		return err
 	}
+	// Eventdb.
+	if err := db.Event.Close(); err != nil {
+		return err
+	}
 	return nil
 }
+
+// DeleteMetric purges the data of a metric by name from metricdb, including
+// the rollup tiers, and then deletes its index, the link of the index is
+// released for reuse. Metrics of the name should not be put meanwhile.
+func (db *DB) DeleteMetric(name string) error {
+	idx, err := db.Index.Get(name)
+	if err != nil {
+		return err
+	}
+	return db.deleteMetric(idx)
+}
+
+// deleteMetric deletes a metric by index, the data is purged while the link
+// is still reserved, so it is never reused by another metric with data left.
+func (db *DB) deleteMetric(idx *models.Index) error {
+	if err := db.Metric.Delete(idx.Link); err != nil {
+		return err
+	}
+	return db.Index.Delete(idx.Name)
+}
+
+// DeleteMetrics deletes metrics matching the pattern, returns the number of
+// metrics deleted.
+func (db *DB) DeleteMetrics(pattern string) (int, error) {
+	return db.deleteMetrics(db.Index.Filter(pattern))
+}
+
+// ExpireMetric deletes a metric by name the same as DeleteMetric if its index
+// is not updated since the stamp, returns true if deleted.
+func (db *DB) ExpireMetric(name string, stamp uint32) (bool, error) {
+	idx, err := db.Index.Get(name)
+	if err != nil {
+		return false, err
+	}
+	if idx.Stamp >= stamp {
+		return false, nil
+	}
+	if err := db.deleteMetric(idx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// deleteMetrics deletes metrics by indexes.
+func (db *DB) deleteMetrics(idxs []*models.Index) (n int, err error) {
+	for _, idx := range idxs {
+		err = db.DeleteMetric(idx.Name)
+		if err == indexdb.ErrNotFound {
+			// Deleted meanwhile.
+			continue
+		}
+		if err != nil {
+			return
+		}
+		n++
+	}
+	return
+}
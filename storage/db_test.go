@@ -3,10 +3,13 @@
 package storage
 
 import (
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage/indexdb"
 	"github.com/eleme/banshee/util"
 	"os"
 	"path"
 	"testing"
+	"time"
 )
 
 func TestOpen(t *testing.T) {
@@ -22,4 +25,44 @@ func TestOpen(t *testing.T) {
 	util.Must(t, util.IsFileExist(path.Join(fileName, admindbFileName)))
 	util.Must(t, util.IsFileExist(path.Join(fileName, indexdbFileName)))
 	util.Must(t, util.IsFileExist(path.Join(fileName, metricdbFileName)))
+	util.Must(t, util.IsFileExist(path.Join(fileName, eventdbFileName)))
+}
+
+func TestDeleteMetric(t *testing.T) {
+	// Open db.
+	fileName := "storage_test"
+	opts := &Options{Interval: 10, Period: 86400, Expiration: 86400 * 7}
+	db, err := Open(fileName, opts)
+	util.Must(t, err == nil)
+	defer db.Close()
+	defer os.RemoveAll(fileName)
+	// Put indexes and metrics.
+	now := uint32(time.Now().Unix())
+	for i, name := range []string{"foo.a", "foo.b", "bar.a"} {
+		idx := &models.Index{Name: name, Stamp: now - uint32(i)*86400*4}
+		util.Must(t, db.Index.Put(idx) == nil)
+		util.Must(t, db.Metric.Put(&models.Metric{Link: idx.Link, Stamp: now, Value: 1}) == nil)
+	}
+	idx, _ := db.Index.Get("foo.a")
+	// Delete one.
+	util.Must(t, db.DeleteMetric("foo.a") == nil)
+	util.Must(t, db.DeleteMetric("foo.a") == indexdb.ErrNotFound)
+	util.Must(t, !db.Index.Has("foo.a"))
+	ms, err := db.Metric.Get("foo.a", idx.Link, now, now+1)
+	util.Must(t, err == nil && len(ms) == 0)
+	// Delete by pattern.
+	n, err := db.DeleteMetrics("foo.*")
+	util.Must(t, err == nil && n == 1)
+	util.Must(t, !db.Index.Has("foo.b") && db.Index.Has("bar.a"))
+	// Expire.
+	util.Must(t, db.Index.Put(&models.Index{Name: "baz", Stamp: now}) == nil)
+	ok, err := db.ExpireMetric("baz", now-86400*7)
+	util.Must(t, err == nil && !ok && db.Index.Has("baz"))
+	ok, err = db.ExpireMetric("bar.a", now-86400*7)
+	util.Must(t, err == nil && ok && !db.Index.Has("bar.a"))
+	_, err = db.ExpireMetric("bar.a", now-86400*7)
+	util.Must(t, err == indexdb.ErrNotFound)
+	// The link is reused.
+	idx1, _ := db.Index.Get("baz")
+	util.Must(t, idx1.Link == idx.Link)
 }
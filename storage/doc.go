@@ -6,17 +6,32 @@ Package storage implements banshee's persistence storage.
 
 Structure
 
-Storage handles 4 kinds of data: index, metric and admin, the directory
+Storage handles 4 kinds of data: index, metric, admin and event, the directory
 structure is:
 
 	storage/
 	    |--index/               --- Metric index          LevelDB
 	    |--metric/              --- Metric data           LevelDB
-	    |--admin                --- Rules/Users/Projects  SQLite3
+	    |--admin                --- Rules/Users/Projects  SQLite3 (default)
+	    |--event                --- Alerting events       SQLite3
 
 The storage directory will be created if not exists.
 
+The admin db can be on MySQL or PostgreSQL instead, to share rules, users and
+projects between banshee instances.
+
 For each child database, see its package's documentation for more.
 
+Backup
+
+A running storage can be snapshotted into another directory by DB.Snapshot,
+sqlite dbs are copied via the sqlite online backup api and leveldb dbs are
+copied from leveldb snapshots, so writes are not blocked. The indexdb and
+metricdb snapshots are taken together before copying, the sqlite dbs are
+copied one by one and may be a bit newer. The snapshot is
+packed into a gzipped tarball by Pack, and restored by Unpack. An admin db
+on MySQL or PostgreSQL is not included, and should be backed up by its own
+tools.
+
 */
 package storage
@@ -0,0 +1,12 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package storage
+
+import "errors"
+
+// Errors
+var (
+	ErrBackupExist    = errors.New("storage: backup destination already exists")
+	ErrRestoreExist   = errors.New("storage: restore destination already exists")
+	ErrBackupFileName = errors.New("storage: invalid file name in backup")
+)
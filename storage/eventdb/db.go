@@ -0,0 +1,207 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package eventdb
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/dbutil"
+	"github.com/eleme/banshee/util/log"
+	"github.com/eleme/banshee/util/wildcard"
+	"github.com/jinzhu/gorm"
+	_ "github.com/mattn/go-sqlite3" // Import but no use
+)
+
+// SQL db dialect
+const dialect = "sqlite3"
+
+// Gorm logging?
+const gormLogMode = false
+
+// Interval in seconds to expire records.
+const expireInterval = 3600
+
+// Limitations of query.
+const (
+	// Default limit of the number of records.
+	DefaultQueryLimit = 100
+	// Max limit of the number of records.
+	MaxQueryLimit = 1000
+)
+
+// Options is to open DB.
+type Options struct {
+	Expiration uint32
+}
+
+// DB handles events history storage.
+type DB struct {
+	db   *gorm.DB
+	opts *Options
+	// Last expiration stamp
+	expiredAt uint32
+	lock      sync.Mutex // protects expiredAt
+}
+
+// Open a DB by fileName and options.
+func Open(fileName string, opts *Options) (*DB, error) {
+	gdb, err := gorm.Open(dialect, fileName)
+	if err != nil {
+		return nil, err
+	}
+	db := &DB{db: &gdb, opts: opts}
+	// Migration
+	if err := db.migrate(); err != nil {
+		return nil, err
+	}
+	// Log Mode
+	db.db.LogMode(gormLogMode)
+	return db, nil
+}
+
+// Close DB.
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+// Backup copies the db into a new sqlite file by fileName while running.
+func (db *DB) Backup(fileName string) error {
+	return dbutil.BackupSQLite(db.db.DB(), fileName)
+}
+
+// migrate db schema.
+func (db *DB) migrate() error {
+	log.Debugf("migrate event sql schemas..")
+	return db.db.AutoMigrate(&models.EventRecord{}).Error
+}
+
+// Put a record into db.
+func (db *DB) Put(r *models.EventRecord) error {
+	if err := db.db.Create(r).Error; err != nil {
+		return err
+	}
+	return db.expire(r.Stamp)
+}
+
+// expire deletes records older than expiration, at most once an interval.
+func (db *DB) expire(stamp uint32) error {
+	if db.opts == nil || db.opts.Expiration == 0 {
+		return nil
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if stamp < db.expiredAt+expireInterval || stamp < db.opts.Expiration {
+		return nil
+	}
+	db.expiredAt = stamp
+	return db.db.Where("stamp < ?", stamp-db.opts.Expiration).Delete(&models.EventRecord{}).Error
+}
+
+// QueryOptions is to query records.
+type QueryOptions struct {
+	// Optional project id and rule id, 0 for any.
+	ProjectID int
+	RuleID    int
+	// Optional wildcard pattern of metric names, empty for any.
+	Pattern string
+	// Stamp range, [Start, Stop), Stop 0 for now.
+	Start uint32
+	Stop  uint32
+	// Max number of records, 0 for DefaultQueryLimit.
+	Limit int
+}
+
+// Query records with options, latest first.
+func (db *DB) Query(opts *QueryOptions) ([]*models.EventRecord, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+	stop := opts.Stop
+	if stop == 0 {
+		stop = uint32(time.Now().Unix()) + 1
+	}
+	if len(opts.Pattern) > 0 && !wildcard.Valid(opts.Pattern) {
+		return nil, ErrBadPattern
+	}
+	q := db.db.Where("stamp >= ? AND stamp < ?", opts.Start, stop)
+	if opts.ProjectID > 0 {
+		q = q.Where("project_id = ?", opts.ProjectID)
+	}
+	if opts.RuleID > 0 {
+		q = q.Where("rule_id = ?", opts.RuleID)
+	}
+	rs := make([]*models.EventRecord, 0)
+	prefix := patternPrefix(opts.Pattern)
+	if prefix == opts.Pattern {
+		// No wildcards, limit in sql.
+		if len(prefix) > 0 {
+			q = q.Where("name = ?", prefix)
+		}
+		err := q.Order("stamp desc, id desc").Limit(limit).Find(&rs).Error
+		return rs, err
+	}
+	if len(prefix) > 0 {
+		q = q.Where("name LIKE ? ESCAPE '\\'", escapeLike(prefix)+"%")
+	}
+	// Filter by pattern in batches, seeking after the last record of the
+	// previous batch.
+	var last *models.EventRecord
+	for len(rs) < limit {
+		bq := q
+		if last != nil {
+			bq = bq.Where("stamp < ? OR (stamp = ? AND id < ?)", last.Stamp, last.Stamp, last.ID)
+		}
+		var batch []*models.EventRecord
+		if err := bq.Order("stamp desc, id desc").Limit(MaxQueryLimit).Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range batch {
+			if wildcard.Match(opts.Pattern, r.Name) {
+				rs = append(rs, r)
+				if len(rs) >= limit {
+					break
+				}
+			}
+		}
+		if len(batch) < MaxQueryLimit {
+			break
+		}
+		last = batch[len(batch)-1]
+	}
+	return rs, nil
+}
+
+// patternPrefix returns the literal prefix of a wildcard pattern, the
+// pattern itself if it has no wildcards.
+func patternPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "*{"); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// escapeLike escapes the special characters of sql LIKE with backslash.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Get a record by id.
+func (db *DB) Get(id int) (*models.EventRecord, error) {
+	r := &models.EventRecord{}
+	if err := db.db.First(r, id).Error; err != nil {
+		if err == gorm.RecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package eventdb
+
+import (
+	"os"
+	"testing"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util"
+)
+
+func TestOpen(t *testing.T) {
+	fileName := "db-testing"
+	db, err := Open(fileName, nil)
+	util.Must(t, nil == err)
+	util.Must(t, db != nil)
+	util.Must(t, util.IsFileExist(fileName))
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	util.Must(t, db.db.HasTable(&models.EventRecord{}))
+}
+
+func TestPutAndQuery(t *testing.T) {
+	fileName := "db-testing"
+	db, _ := Open(fileName, nil)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	// Put
+	util.Must(t, db.Put(&models.EventRecord{ProjectID: 1, RuleID: 1, Name: "timer.count_ps.foo", Stamp: 100}) == nil)
+	util.Must(t, db.Put(&models.EventRecord{ProjectID: 1, RuleID: 2, Name: "timer.count_ps.bar", Stamp: 200}) == nil)
+	util.Must(t, db.Put(&models.EventRecord{ProjectID: 2, RuleID: 3, Name: "counter.foo", Stamp: 300, Silenced: true}) == nil)
+	// Query all, latest first.
+	rs, err := db.Query(&QueryOptions{})
+	util.Must(t, err == nil)
+	util.Must(t, len(rs) == 3)
+	util.Must(t, rs[0].Stamp == 300 && rs[0].Silenced)
+	// By project and rule.
+	rs, _ = db.Query(&QueryOptions{ProjectID: 1})
+	util.Must(t, len(rs) == 2)
+	rs, _ = db.Query(&QueryOptions{RuleID: 2})
+	util.Must(t, len(rs) == 1 && rs[0].Name == "timer.count_ps.bar")
+	// By pattern.
+	rs, _ = db.Query(&QueryOptions{Pattern: "timer.*.foo"})
+	util.Must(t, len(rs) == 1 && rs[0].Stamp == 100)
+	rs, _ = db.Query(&QueryOptions{Pattern: "timer.**"})
+	util.Must(t, len(rs) == 2)
+	rs, _ = db.Query(&QueryOptions{Pattern: "**.{foo,baz}"})
+	util.Must(t, len(rs) == 2 && rs[0].Name == "counter.foo")
+	rs, _ = db.Query(&QueryOptions{Pattern: "counter.foo"})
+	util.Must(t, len(rs) == 1 && rs[0].Stamp == 300)
+	rs, _ = db.Query(&QueryOptions{Pattern: "timer_*"})
+	util.Must(t, len(rs) == 0)
+	_, err = db.Query(&QueryOptions{Pattern: "timer.{foo"})
+	util.Must(t, err == ErrBadPattern)
+	// By time range and limit.
+	rs, _ = db.Query(&QueryOptions{Start: 100, Stop: 300})
+	util.Must(t, len(rs) == 2)
+	rs, _ = db.Query(&QueryOptions{Limit: 1})
+	util.Must(t, len(rs) == 1 && rs[0].Stamp == 300)
+}
+
+func TestExpire(t *testing.T) {
+	fileName := "db-testing"
+	db, _ := Open(fileName, &Options{Expiration: 3600 * 24})
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	base := uint32(1452674178)
+	util.Must(t, db.Put(&models.EventRecord{Name: "foo", Stamp: base}) == nil)
+	util.Must(t, db.Put(&models.EventRecord{Name: "foo", Stamp: base + 3600*24 + 1}) == nil)
+	rs, _ := db.Query(&QueryOptions{})
+	util.Must(t, len(rs) == 1 && rs[0].Stamp == base+3600*24+1)
+}
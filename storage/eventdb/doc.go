@@ -0,0 +1,16 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+/*
+
+Package eventdb handles the alerting events history storage on SQLite3.
+
+Every alerting event received by alerter is recorded for each of its rules,
+whether it was sent, silenced or rate limited.
+
+Expiration
+
+Records older than the expiration are deleted on writing, at most once an
+hour.
+
+*/
+package eventdb
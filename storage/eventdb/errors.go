@@ -0,0 +1,12 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package eventdb
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when requested data not found.
+	ErrNotFound = errors.New("eventdb: not found")
+	// ErrBadPattern is returned when the query pattern is malformed.
+	ErrBadPattern = errors.New("eventdb: bad pattern")
+)
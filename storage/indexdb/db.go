@@ -8,10 +8,10 @@ Design
 
 The DB is a leveldb instance, and the key-value format is:
 
-	|--- Key --|------------------ Value (24) -------------------|
-	+----------+-----------+-----------+-----------+-------------+
-	| Name (X) |  Link (4) | Stamp (4) | Score (8) | Average (8) |
-	+----------+-----------+-----------+-----------+-------------+
+	|--- Key --|------------------------- Value (24+X) -----------------------|
+	+----------+-----------+-----------+-----------+-------------+-------------+
+	| Name (X) |  Link (4) | Stamp (4) | Score (8) | Average (8) | Algorithm(X)|
+	+----------+-----------+-----------+-----------+-------------+-------------+
 
 Cache
 
@@ -26,7 +26,10 @@ Write operations are to persistence and cache.
 package indexdb
 
 import (
+	"strings"
+
 	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/dbutil"
 	"github.com/eleme/banshee/util/idpool"
 	"github.com/eleme/banshee/util/log"
 	"github.com/eleme/banshee/util/trie"
@@ -69,6 +72,42 @@ func (db *DB) Close() error {
 	return db.db.Close()
 }
 
+// Snapshot is a point in time view of the db, to be copied by Backup.
+type Snapshot struct {
+	snap *leveldb.Snapshot
+}
+
+// Snapshot takes a snapshot of the db, the snapshot should be copied by
+// Backup or released.
+func (db *DB) Snapshot() (*Snapshot, error) {
+	snap, err := db.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{snap}, nil
+}
+
+// Backup copies the snapshot into a new leveldb by fileName, the snapshot is
+// released on return.
+func (s *Snapshot) Backup(fileName string) error {
+	return dbutil.CopySnapshot(s.snap, fileName)
+}
+
+// Release the snapshot.
+func (s *Snapshot) Release() {
+	s.snap.Release()
+}
+
+// Backup copies a snapshot of the db into a new leveldb by fileName while
+// running.
+func (db *DB) Backup(fileName string) error {
+	snap, err := db.Snapshot()
+	if err != nil {
+		return err
+	}
+	return snap.Backup(fileName)
+}
+
 // load indexes from db to cache.
 func (db *DB) load() {
 	log.Debugf("init index from indexdb..")
@@ -80,7 +119,14 @@ func (db *DB) load() {
 		value := iter.Value()
 		idx := &models.Index{}
 		idx.Name = string(key)
-		err := decode(value, idx)
+		_, tags, err := models.SplitTaggedName(idx.Name)
+		if err != nil {
+			// Skip corrupted keys
+			log.Warn("corrupted key found, skipping..")
+			continue
+		}
+		idx.Tags = tags
+		err = decode(value, idx)
 		if err != nil {
 			// Skip corrupted values
 			log.Warn("corrupted data found, skipping..")
@@ -158,9 +204,17 @@ func (db *DB) Has(name string) bool {
 	return db.tr.Has(name)
 }
 
-// Filter indexes by pattern.
+// Filter indexes by pattern, the pattern is matched with metric names
+// without tags.
 func (db *DB) Filter(pattern string) (l []*models.Index) {
-	for _, v := range db.tr.Match(pattern) {
+	m := db.tr.Match(pattern)
+	// Tagged names end with tags in the last segment.
+	for k, v := range db.tr.Match(pattern + models.TagsDelim + "*") {
+		if strings.Contains(k, models.TagsDelim) {
+			m[k] = v
+		}
+	}
+	for _, v := range m {
 		idx := v.(*models.Index)
 		l = append(l, idx.Copy())
 	}
@@ -141,6 +141,28 @@ func TestFilter(t *testing.T) {
 	util.Must(t, l[0].Name != excludeName && l[1].Name != excludeName)
 }
 
+func TestFilterTagged(t *testing.T) {
+	// Open db.
+	fileName := "db-testing"
+	db, _ := Open(fileName)
+	defer os.RemoveAll(fileName)
+	// Add indexes.
+	tags := map[string]string{"host": "web1"}
+	db.Put(&models.Index{Name: "a.b"})
+	db.Put(&models.Index{Name: models.TaggedName("a.b", tags), Tags: tags})
+	db.Put(&models.Index{Name: models.TaggedName("a.c", tags), Tags: tags})
+	// Filter
+	util.Must(t, len(db.Filter("a.b")) == 2)
+	util.Must(t, len(db.Filter("a.*")) == 3)
+	util.Must(t, len(db.Filter("a.**")) == 3)
+	// Tags are loaded from names.
+	db.Close()
+	db, _ = Open(fileName)
+	defer db.Close()
+	idx, err := db.Get("a.b;host=web1")
+	util.Must(t, err == nil && idx.Tags["host"] == "web1")
+}
+
 func TestLen(t *testing.T) {
 	// Open db.
 	fileName := "db-testing"
@@ -11,28 +11,31 @@ import (
 
 // Format
 //
-//	|--- Key --|------------------ Value (24) -------------------|
-//	+----------+-----------+-----------+-----------+-------------+
-//	| Name (X) |  Link (4) | Stamp (4) | Score (8) | Average (8) |
-//	+----------+-----------+-----------+-----------+-------------+
+//	|--- Key --|------------------------- Value (24+X) -----------------------|
+//	+----------+-----------+-----------+-----------+-------------+-------------+
+//	| Name (X) |  Link (4) | Stamp (4) | Score (8) | Average (8) | Algorithm(X)|
+//	+----------+-----------+-----------+-----------+-------------+-------------+
 //
+// The trailing algorithm name is optional, values written by older versions
+// have no algorithm and are still decodable.
 
 // encode encodes db value from index.
 func encode(idx *models.Index) []byte {
-	b := make([]byte, 4+4+8+8)
-	binary.BigEndian.PutUint32(b[:4], idx.Link)                           // 4
-	binary.BigEndian.PutUint32(b[4:4+4], idx.Stamp)                       // 4
-	binary.BigEndian.PutUint64(b[4+4:4+4+8], math.Float64bits(idx.Score)) // 8
-	binary.BigEndian.PutUint64(b[4+4+8:], math.Float64bits(idx.Average))  // 8
+	b := make([]byte, 4+4+8+8+len(idx.Algorithm))
+	binary.BigEndian.PutUint32(b[:4], idx.Link)                                 // 4
+	binary.BigEndian.PutUint32(b[4:4+4], idx.Stamp)                             // 4
+	binary.BigEndian.PutUint64(b[4+4:4+4+8], math.Float64bits(idx.Score))       // 8
+	binary.BigEndian.PutUint64(b[4+4+8:4+4+8+8], math.Float64bits(idx.Average)) // 8
+	copy(b[4+4+8+8:], idx.Algorithm)                                            // X
 	return b
 }
 
 // decode decodes db value into index.
 func decode(value []byte, idx *models.Index) error {
-	if len(value) != 4+4+8+8 {
+	if len(value) < 4+4+8+8 {
 		return ErrCorrupted
 	}
-	r := bytes.NewReader(value)
+	r := bytes.NewReader(value[:4+4+8+8])
 	if err := binary.Read(r, binary.BigEndian, &idx.Link); err != nil {
 		return err
 	}
@@ -45,5 +48,6 @@ func decode(value []byte, idx *models.Index) error {
 	if err := binary.Read(r, binary.BigEndian, &idx.Average); err != nil {
 		return err
 	}
+	idx.Algorithm = string(value[4+4+8+8:])
 	return nil
 }
@@ -18,3 +18,18 @@ func TestEncoding(t *testing.T) {
 	util.Must(t, idx1.Score == 0.678888)
 	util.Must(t, idx1.Average == 877.234)
 }
+
+func TestEncodingAlgorithm(t *testing.T) {
+	idx := &models.Index{Stamp: 1450426828, Score: 1.2, Algorithm: "mad"}
+	value := encode(idx)
+	idx1 := &models.Index{}
+	util.Must(t, decode(value, idx1) == nil)
+	util.Must(t, idx1.Algorithm == "mad")
+	// Values without algorithm.
+	idx2 := &models.Index{}
+	util.Must(t, decode(value[:4+4+8+8], idx2) == nil)
+	util.Must(t, idx2.Algorithm == "")
+	util.Must(t, idx2.Score == 1.2)
+	// Corrupted values.
+	util.Must(t, decode(value[:4+4+8], idx2) == ErrCorrupted)
+}
@@ -5,7 +5,9 @@
 Package metricdb handles the metrics storage.
 
 The DB contains multiple leveldb instances, a new leveldb instance would be
-created and also an old instance would be expired every day.
+created and also an old instance would be expired every day. Instances older
+than the active one are created on putting older metrics (e.g. imported), if
+not expired.
 
 File Structure
 
@@ -26,6 +28,29 @@ Example file structure for period=1day, expiration=7days:
 	        |- 16920 -- Active
 
 
+Rollup Tiers
+
+Metrics are also aggregated into rollup tiers by longer intervals, each tier
+is a DB in directory "rollup-<interval>" with its own period and expiration:
+
+	metricdb/
+	   |- 16919
+	   |- 16920
+	   |- rollup-60/
+	   |     |- 2819
+	   |     |- 2820
+	   |- rollup-600/
+	         |- 281
+	         |- 282
+
+A rollup metric holds the averages of the raw values and averages, the score
+with the max absolute value, and the count, min and max of the raw values
+(20 more bytes in the entry value). Get
+serves the range by the finest tier that holds the range start, and whose
+storage period is not shorter than the range, so that a query returns at
+most about as many metrics as a storage holds. GetRaw always serves raw
+metrics.
+
 Entry Format
 
 Key-Value design in leveldb:
@@ -35,11 +60,29 @@ Key-Value design in leveldb:
 	| Link (4) | Stamp (4) | Value (8) | Score (8) | Average (8) |
 	+----------+-----------+-----------+-----------+-------------+
 
+Sealed Storages
+
+Once a new storage is created, the older storages are sealed in background:
+raw metrics are compressed into blocks of at most 120 metrics by Gorilla
+style encoding (delta of delta stamps and XOR floats), keyed by the link and
+the first stamp with a suffix:
+
+	|----------- Key (9) -------------|------- Value -------|
+	+----------+-----------+----------+---------------------+
+	| Link (4) | Stamp (4) | 'B' (1)  | Compressed block    |
+	+----------+-----------+----------+---------------------+
+
+Blocks are decoded transparently on reading, and raw metrics put after
+sealing are read as well.
+
 */
 package metricdb
 
 import (
+	"bytes"
+	"encoding/binary"
 	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/dbutil"
 	"github.com/eleme/banshee/util/log"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/util"
@@ -55,6 +98,10 @@ import (
 type storage struct {
 	id uint32
 	db *leveldb.DB
+	// Sealed or being sealed.
+	sealing bool
+	// Protects writes of blocks against deletions.
+	lock sync.Mutex
 }
 
 const filemode = 0755
@@ -70,14 +117,20 @@ func (b byID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 type Options struct {
 	Period     uint32
 	Expiration uint32
+	// Rollup tiers, sorted by interval.
+	Rollups []RollupOptions
 }
 
 // DB is the top level metric storage handler.
 type DB struct {
-	name string // dirname
-	opts *Options
-	pool []*storage   // sorted byID
-	lock sync.RWMutex // protects runtime pool
+	name    string // dirname
+	opts    *Options
+	pool    []*storage   // sorted byID
+	lock    sync.RWMutex // protects runtime pool
+	rollups []*rollup    // sorted by interval
+	// Sealing goroutines.
+	quit chan struct{}
+	wg   sync.WaitGroup
 }
 
 // openStorage opens a storage by filename.
@@ -108,13 +161,34 @@ func Open(fileName string, opts *Options) (*DB, error) {
 		}
 		log.Debugf("dir %s created", fileName)
 	}
-	db := &DB{opts: opts, name: fileName}
+	db := &DB{opts: opts, name: fileName, quit: make(chan struct{})}
 	if err = db.init(); err != nil {
 		return nil, err
 	}
+	if err = db.openRollups(); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
+// openRollups opens all rollup tiers on DB open.
+func (db *DB) openRollups() error {
+	if db.opts == nil {
+		return nil
+	}
+	for _, opts := range db.opts.Rollups {
+		fileName := path.Join(db.name, rollupDirName(opts.Interval))
+		rdb, err := Open(fileName, &Options{Period: opts.Period, Expiration: opts.Expiration})
+		if err != nil {
+			return err
+		}
+		r := newRollup(opts.Interval, rdb, db)
+		db.rollups = append(db.rollups, r)
+		log.Debugf("rollup %s opened", rollupDirName(opts.Interval))
+	}
+	return nil
+}
+
 // init opens all storages on DB open.
 func (db *DB) init() error {
 	infos, err := ioutil.ReadDir(db.name)
@@ -122,28 +196,122 @@ func (db *DB) init() error {
 		return err
 	}
 	for _, info := range infos {
+		if isRollupDirName(info.Name()) {
+			continue
+		}
 		fileName := path.Join(db.name, info.Name())
 		s, err := openStorage(fileName)
 		if err != nil {
 			return err
 		}
+		if s.sealing, err = s.isSealed(); err != nil {
+			return err
+		}
 		db.pool = append(db.pool, s)
 		log.Debugf("storage %d opened", s.id)
 	}
 	sort.Sort(byID(db.pool))
+	db.sealStorages()
 	return nil
 }
 
 // Close the DB.
 func (db *DB) Close() (err error) {
+	// Abort sealings.
+	close(db.quit)
+	db.wg.Wait()
 	for _, s := range db.pool {
 		if err = s.close(); err != nil {
 			return
 		}
 	}
+	for _, r := range db.rollups {
+		if err = r.db.Close(); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// Snapshot is a point in time view of all storages and rollup tiers of a
+// DB, to be copied by Backup.
+type Snapshot struct {
+	ids     []uint32
+	snaps   []*leveldb.Snapshot
+	rollups []*Snapshot
+	// Intervals of the rollup tiers.
+	intervals []uint32
+}
+
+// Snapshot takes leveldb snapshots of all storages and rollup tiers at once,
+// the snapshot should be copied by Backup or released.
+func (db *DB) Snapshot() (*Snapshot, error) {
+	snap := &Snapshot{}
+	db.lock.RLock()
+	for _, s := range db.pool {
+		ss, err := s.db.GetSnapshot()
+		if err != nil {
+			db.lock.RUnlock()
+			snap.Release()
+			return nil, err
+		}
+		snap.ids = append(snap.ids, s.id)
+		snap.snaps = append(snap.snaps, ss)
+	}
+	db.lock.RUnlock()
+	for _, r := range db.rollups {
+		rs, err := r.db.Snapshot()
+		if err != nil {
+			snap.Release()
+			return nil, err
+		}
+		snap.rollups = append(snap.rollups, rs)
+		snap.intervals = append(snap.intervals, r.interval)
+	}
+	return snap, nil
+}
+
+// Backup copies the snapshot into a new directory by fileName, the snapshot
+// is released on return.
+func (snap *Snapshot) Backup(fileName string) error {
+	defer snap.Release()
+	if err := os.Mkdir(fileName, filemode); err != nil {
+		return err
+	}
+	for i, ss := range snap.snaps {
+		baseName := strconv.FormatUint(uint64(snap.ids[i]), 10)
+		if err := dbutil.CopySnapshot(ss, path.Join(fileName, baseName)); err != nil {
+			return err
+		}
+	}
+	for i, rs := range snap.rollups {
+		if err := rs.Backup(path.Join(fileName, rollupDirName(snap.intervals[i]))); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Release the snapshot, releasing twice is safe.
+func (snap *Snapshot) Release() {
+	for _, ss := range snap.snaps {
+		ss.Release()
+	}
+	for _, rs := range snap.rollups {
+		rs.Release()
+	}
+}
+
+// Backup copies snapshots of all storages and rollup tiers into a new
+// directory by fileName while running.
+func (db *DB) Backup(fileName string) error {
+	snap, err := db.Snapshot()
+	if err != nil {
+		return err
+	}
+	return snap.Backup(fileName)
+}
+
 // createStorage creates a storage for given stamp.
 // Dose nothing if the stamp is not large enough.
 func (db *DB) createStorage(stamp uint32) error {
@@ -161,9 +329,30 @@ func (db *DB) createStorage(stamp uint32) error {
 	s := &storage{db: ldb, id: id}
 	db.pool = append(db.pool, s)
 	log.Infof("storage %d created", id)
+	db.sealStorages()
 	return nil
 }
 
+// sealStorages seals all storages except the active one in background.
+func (db *DB) sealStorages() {
+	for i := 0; i < len(db.pool)-1; i++ {
+		s := db.pool[i]
+		if s.sealing {
+			continue
+		}
+		s.sealing = true
+		db.wg.Add(1)
+		go func() {
+			defer db.wg.Done()
+			if err := s.seal(db.quit); err != nil {
+				log.Warnf("storage %d seal: %v", s.id, err)
+				return
+			}
+			log.Infof("storage %d sealed", s.id)
+		}()
+	}
+}
+
 // expireStorage expire storages.
 // Dose nothing if the pool needs no expiration.
 func (db *DB) expireStorages() error {
@@ -190,9 +379,22 @@ func (db *DB) expireStorages() error {
 	return nil
 }
 
-// Put a metric into db.
+// Put a metric into db, and aggregate it into rollup tiers.
 // Returns ErrNoStorage if no storage is available.
-func (db *DB) Put(m *models.Metric) (err error) {
+func (db *DB) Put(m *models.Metric) error {
+	if err := db.put(m); err != nil {
+		return err
+	}
+	for _, r := range db.rollups {
+		if err := r.put(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put a metric into the storage pool.
+func (db *DB) put(m *models.Metric) (err error) {
 	db.lock.Lock()
 	defer db.lock.Unlock()
 	// Adjust storage pool.
@@ -212,7 +414,35 @@ func (db *DB) Put(m *models.Metric) (err error) {
 			return s.put(m)
 		}
 	}
-	return ErrNoStorage
+	// Older metrics, e.g. imported.
+	s, err := db.createOldStorage(m.Stamp)
+	if err != nil {
+		return
+	}
+	return s.put(m)
+}
+
+// createOldStorage creates a storage older than the active one for given
+// stamp, the storage is sealed in background.
+// Returns ErrNoStorage if the stamp is expired.
+func (db *DB) createOldStorage(stamp uint32) (*storage, error) {
+	id := stamp / db.opts.Period
+	if id+db.opts.Expiration/db.opts.Period < db.pool[len(db.pool)-1].id {
+		// Expired
+		return nil, ErrNoStorage
+	}
+	baseName := strconv.FormatUint(uint64(id), 10)
+	fileName := path.Join(db.name, baseName)
+	ldb, err := leveldb.OpenFile(fileName, nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &storage{db: ldb, id: id}
+	db.pool = append(db.pool, s)
+	sort.Sort(byID(db.pool))
+	log.Infof("storage %d created", id)
+	db.sealStorages()
+	return s, nil
 }
 
 // put a metric into storage.
@@ -226,29 +456,167 @@ func (s *storage) put(m *models.Metric) error {
 	return s.db.Put(key, value, nil)
 }
 
-// get metrics in a timestamp range.
+// get metrics in a timestamp range, metrics in blocks are decoded
+// transparently.
 func (s *storage) get(name string, link, start, end uint32) ([]*models.Metric, error) {
 	startKey := encodeKey(&models.Metric{Link: link, Stamp: start})
 	endKey := encodeKey(&models.Metric{Link: link, Stamp: end})
-	iter := s.db.NewIterator(&util.Range{Start: startKey, Limit: endKey}, nil)
-	var ms []*models.Metric
-	for iter.Next() {
-		m := &models.Metric{Name: name}
+	iter := s.db.NewIterator(nil, nil)
+	defer iter.Release()
+	// Start from the block holding the start stamp, if any: the last block
+	// of the link before the start stamp, over raw metrics put after sealing.
+	ok := iter.Seek(startKey)
+	if ok {
+		ok = iter.Prev()
+	} else {
+		ok = iter.Last()
+	}
+	for ok && !isBlockKey(iter.Key()) && binary.BigEndian.Uint32(iter.Key()[:4]) == link {
+		ok = iter.Prev()
+	}
+	if !ok || !isBlockKey(iter.Key()) || binary.BigEndian.Uint32(iter.Key()[:4]) != link {
+		ok = iter.Seek(startKey)
+	}
+	// Raw metrics and metrics in blocks, raw ones may be put after sealing.
+	var ms, bms []*models.Metric
+	for ; ok && bytes.Compare(iter.Key(), endKey) < 0; ok = iter.Next() {
 		key := iter.Key()
-		value := iter.Value()
+		if isBlockKey(key) {
+			l, err := decodeBlock(iter.Value(), link)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range l {
+				if start <= m.Stamp && m.Stamp < end {
+					m.Name = name
+					bms = append(bms, m)
+				}
+			}
+			continue
+		}
+		m := &models.Metric{Name: name}
 		if err := decodeKey(key, m); err != nil {
 			return nil, err
 		}
-		if err := decodeValue(value, m); err != nil {
+		if m.Stamp < start {
+			continue
+		}
+		if err := decodeValue(iter.Value(), m); err != nil {
 			return nil, err
 		}
 		ms = append(ms, m)
 	}
-	return ms, nil
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	if len(bms) == 0 {
+		return ms, nil
+	}
+	// Merge by stamp, the raw metric wins if a stamp is both in a block and
+	// put again after sealing.
+	ms = append(ms, bms...)
+	sort.Stable(byStamp(ms))
+	n := 0
+	for i, m := range ms {
+		if i > 0 && m.Stamp == ms[n-1].Stamp {
+			continue
+		}
+		ms[n] = m
+		n++
+	}
+	return ms[:n], nil
+}
+
+// delete all metrics and blocks of a link in the storage in a batch.
+func (s *storage) delete(link uint32) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	prefix := make([]byte, 4)
+	binary.BigEndian.PutUint32(prefix, link)
+	iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return s.db.Write(batch, nil)
 }
 
+// byStamp implements sort.Interface.
+type byStamp []*models.Metric
+
+func (b byStamp) Len() int           { return len(b) }
+func (b byStamp) Less(i, j int) bool { return b[i].Stamp < b[j].Stamp }
+func (b byStamp) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
 // Get metrics in a timestamp range, the range is left open and right closed.
+// Raw metrics are returned if the range start is not expired and the range
+// is not longer than the period, otherwise the metrics are from the finest
+// rollup tier holding the range.
 func (db *DB) Get(name string, link, start, end uint32) ([]*models.Metric, error) {
+	if r := db.rollupFor(start, end); r != nil {
+		return r.get(name, link, start, end)
+	}
+	return db.get(name, link, start, end)
+}
+
+// GetRaw gets raw metrics in a timestamp range, the range is left open and
+// right closed.
+func (db *DB) GetRaw(name string, link, start, end uint32) ([]*models.Metric, error) {
+	return db.get(name, link, start, end)
+}
+
+// Delete all metrics of a link from all storages and rollup tiers.
+func (db *DB) Delete(link uint32) error {
+	if link == 0 {
+		return ErrNoLink
+	}
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	for _, s := range db.pool {
+		if err := s.delete(link); err != nil {
+			return err
+		}
+	}
+	for _, r := range db.rollups {
+		if err := r.delete(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollupFor returns the rollup tier to serve the range, nil for raw.
+func (db *DB) rollupFor(start, end uint32) *rollup {
+	if len(db.rollups) == 0 || db.holds(start, end) {
+		return nil
+	}
+	for _, r := range db.rollups {
+		if r.db.holds(start, end) {
+			return r
+		}
+	}
+	return db.rollups[len(db.rollups)-1]
+}
+
+// covers returns true if the storage pool holds the stamp.
+func (db *DB) covers(stamp uint32) bool {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return len(db.pool) > 0 && db.pool[0].id*db.opts.Period <= stamp
+}
+
+// holds returns true if the storage pool holds the range start, and the
+// range is not longer than the period.
+func (db *DB) holds(start, end uint32) bool {
+	return end <= start+db.opts.Period && db.covers(start)
+}
+
+// get metrics in a timestamp range from the storage pool.
+func (db *DB) get(name string, link, start, end uint32) ([]*models.Metric, error) {
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 	var ms []*models.Metric
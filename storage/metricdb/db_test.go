@@ -196,3 +196,264 @@ func BenchmarkGet100K(b *testing.B) {
 		db.Get("whatever", uint32(i%10), base, base+100)
 	}
 }
+
+func TestRollup(t *testing.T) {
+	// Open db.
+	fileName := "db-testing"
+	opts := &Options{
+		Period:     86400,
+		Expiration: 86400 * 2,
+		Rollups:    []RollupOptions{{Interval: 60, Period: 86400 * 6, Expiration: 86400 * 30}},
+	}
+	db, _ := Open(fileName, opts)
+	defer os.RemoveAll(fileName)
+	base := uint32(time.Now().Unix()) / 60 * 60
+	// Put 6 metrics in a minute.
+	for i := uint32(0); i < 6; i++ {
+		util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base + i*10, Value: float64(i), Score: float64(i) / 10}) == nil)
+	}
+	// Raw metrics.
+	ms, err := db.Get("foo", 1, base, base+60)
+	util.Must(t, err == nil)
+	util.Must(t, len(ms) == 6 && ms[0].Rollup == nil)
+	// Reopen and put one more to the same minute.
+	db.Close()
+	db, _ = Open(fileName, opts)
+	defer db.Close()
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base + 59, Value: 7}) == nil)
+	// Expire raw metrics.
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base + 86400*4}) == nil)
+	ms, err = db.Get("foo", 1, base, base+60)
+	util.Must(t, err == nil)
+	util.Must(t, len(ms) == 1)
+	m := ms[0]
+	util.Must(t, m.Stamp == base && m.Rollup != nil)
+	util.Must(t, m.Rollup.Interval == 60 && m.Rollup.Count == 7)
+	util.Must(t, m.Rollup.Min == 0 && m.Rollup.Max == 7)
+	util.Must(t, m.Value == 22.0/7 && m.Score == 0.5)
+	// Recent metrics are still raw.
+	ms, _ = db.Get("foo", 1, base+86400*4, base+86400*4+1)
+	util.Must(t, len(ms) == 1 && ms[0].Rollup == nil)
+}
+
+func TestRollupPutAgain(t *testing.T) {
+	// Open db.
+	fileName := "db-testing"
+	opts := &Options{
+		Period:     86400,
+		Expiration: 86400 * 2,
+		Rollups:    []RollupOptions{{Interval: 60, Period: 86400 * 6, Expiration: 86400 * 30}},
+	}
+	db, _ := Open(fileName, opts)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	base := uint32(time.Now().Unix()) / 60 * 60
+	for i := uint32(0); i < 3; i++ {
+		util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base + i*10, Value: float64(i)}) == nil)
+	}
+	// Put again and out of order.
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base + 10, Value: 4}) == nil)
+	ms, err := db.rollups[0].get("foo", 1, base, base+60)
+	util.Must(t, err == nil && len(ms) == 1)
+	util.Must(t, ms[0].Rollup.Count == 3 && ms[0].Rollup.Max == 4 && ms[0].Value == 2)
+	// Continue in order.
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base + 30, Value: 6}) == nil)
+	ms, _ = db.rollups[0].get("foo", 1, base, base+60)
+	util.Must(t, ms[0].Rollup.Count == 4 && ms[0].Value == 3)
+}
+
+func TestRollupEvict(t *testing.T) {
+	// Open db.
+	fileName := "db-testing"
+	opts := &Options{
+		Period:     86400,
+		Expiration: 86400 * 2,
+		Rollups:    []RollupOptions{{Interval: 60, Period: 86400 * 6, Expiration: 86400 * 30}},
+	}
+	db, _ := Open(fileName, opts)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	base := uint32(time.Now().Unix()) / 60 * 60
+	// 2 links in the same shard.
+	link := uint32(1 + rollupShards)
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base}) == nil)
+	util.Must(t, db.Put(&models.Metric{Link: link, Stamp: base + 120}) == nil)
+	sh := &db.rollups[0].shards[1]
+	util.Must(t, len(sh.aggs) == 2)
+	// Link 1 is idle for more than an interval.
+	util.Must(t, db.Put(&models.Metric{Link: link, Stamp: base + 240}) == nil)
+	_, ok := sh.aggs[1]
+	util.Must(t, !ok && len(sh.aggs) == 1)
+}
+
+func TestGetByRange(t *testing.T) {
+	// Open db.
+	fileName := "db-testing"
+	opts := &Options{
+		Period:     86400,
+		Expiration: 86400 * 2,
+		Rollups:    []RollupOptions{{Interval: 60, Period: 86400 * 6, Expiration: 86400 * 30}},
+	}
+	db, _ := Open(fileName, opts)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	base := uint32(time.Now().Unix()) / 60 * 60
+	for i := uint32(0); i < 12; i++ {
+		util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base + i*10, Value: float64(i)}) == nil)
+	}
+	// Short ranges are raw.
+	ms, err := db.Get("foo", 1, base, base+120)
+	util.Must(t, err == nil && len(ms) == 12 && ms[0].Rollup == nil)
+	// Long ranges are from the rollup tier.
+	ms, err = db.Get("foo", 1, base, base+86400*2)
+	util.Must(t, err == nil && len(ms) == 2 && ms[0].Rollup != nil)
+	// Unless raw metrics are required.
+	ms, err = db.GetRaw("foo", 1, base, base+86400*2)
+	util.Must(t, err == nil && len(ms) == 12)
+}
+
+func TestSeal(t *testing.T) {
+	// Open db.
+	fileName := "db-testing"
+	opts := &Options{Period: 86400, Expiration: 86400 * 7}
+	db, _ := Open(fileName, opts)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	base := uint32(time.Now().Unix()) / 86400 * 86400
+	// Put metrics of 2 links.
+	var ms []*models.Metric
+	for i := uint32(0); i < 300; i++ {
+		m := &models.Metric{Name: "foo", Link: 1, Stamp: base + i*10, Value: float64(i % 7), Score: 0.1, Average: 3}
+		ms = append(ms, m)
+		util.Must(t, db.Put(m) == nil)
+		util.Must(t, db.Put(&models.Metric{Link: 2, Stamp: base + i*10, Value: 1}) == nil)
+	}
+	s := db.pool[0]
+	util.Must(t, s.seal(db.quit) == nil)
+	sealed, err := s.isSealed()
+	util.Must(t, err == nil && sealed)
+	// Put after sealing.
+	m := &models.Metric{Name: "foo", Link: 1, Stamp: base + 2995, Value: 8}
+	util.Must(t, db.Put(m) == nil)
+	// Get all.
+	ms1, err := db.Get("foo", 1, base, base+86400)
+	util.Must(t, err == nil)
+	util.Must(t, len(ms1) == 301)
+	util.Must(t, reflect.DeepEqual(ms1[:300], ms))
+	util.Must(t, reflect.DeepEqual(ms1[300], m))
+	// Get a range across blocks.
+	ms1, err = db.Get("foo", 1, base+1195, base+1505)
+	util.Must(t, err == nil)
+	util.Must(t, reflect.DeepEqual(ms1, ms[120:151]))
+	// Get empty range.
+	ms1, err = db.Get("foo", 1, base+5, base+10)
+	util.Must(t, err == nil && len(ms1) == 0)
+}
+
+func TestSealPutInBlock(t *testing.T) {
+	// Open db.
+	fileName := "db-testing"
+	opts := &Options{Period: 86400, Expiration: 86400 * 7}
+	db, _ := Open(fileName, opts)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	base := uint32(time.Now().Unix()) / 86400 * 86400
+	for i := uint32(0); i < 100; i++ {
+		util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base + i*10, Value: float64(i)}) == nil)
+	}
+	s := db.pool[0]
+	util.Must(t, s.seal(db.quit) == nil)
+	// Put into the range of the block after sealing.
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base + 15, Value: 1}) == nil)
+	ms, err := db.Get("foo", 1, base+200, base+400)
+	util.Must(t, err == nil && len(ms) == 20)
+	util.Must(t, ms[0].Stamp == base+200 && ms[19].Stamp == base+390)
+	ms, err = db.Get("foo", 1, base+10, base+30)
+	util.Must(t, err == nil && len(ms) == 3 && ms[1].Stamp == base+15)
+	// Put a stamp in the block again, the raw one wins.
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base + 20, Value: 100}) == nil)
+	ms, err = db.Get("foo", 1, base+10, base+30)
+	util.Must(t, err == nil && len(ms) == 3 && ms[2].Stamp == base+20 && ms[2].Value == 100)
+}
+
+func TestSealOnNewStorage(t *testing.T) {
+	// Open db.
+	fileName := "db-testing"
+	opts := &Options{Period: 86400, Expiration: 86400 * 7}
+	db, _ := Open(fileName, opts)
+	defer os.RemoveAll(fileName)
+	base := uint32(time.Now().Unix())
+	db.Put(&models.Metric{Link: 1, Stamp: base, Value: 1})
+	db.Put(&models.Metric{Link: 1, Stamp: base + 86400, Value: 2})
+	// Wait for sealing.
+	db.wg.Wait()
+	sealed, _ := db.pool[0].isSealed()
+	util.Must(t, sealed)
+	sealed, _ = db.pool[1].isSealed()
+	util.Must(t, !sealed)
+	db.Close()
+	// Reopen.
+	db, _ = Open(fileName, opts)
+	defer db.Close()
+	util.Must(t, db.pool[0].sealing && !db.pool[1].sealing)
+	ms, err := db.Get("foo", 1, base, base+86400+1)
+	util.Must(t, err == nil && len(ms) == 2)
+	util.Must(t, ms[0].Value == 1 && ms[1].Value == 2)
+}
+
+func TestPutOld(t *testing.T) {
+	// Open db.
+	fileName := "db-testing"
+	opts := &Options{Period: 86400, Expiration: 86400 * 7}
+	db, _ := Open(fileName, opts)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	base := uint32(time.Now().Unix())
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base, Value: 1}) == nil)
+	// Older storages are created in order.
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base - 86400*3, Value: 2}) == nil)
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base - 86400, Value: 3}) == nil)
+	util.Must(t, len(db.pool) == 3)
+	util.Must(t, db.pool[0].id < db.pool[1].id && db.pool[1].id < db.pool[2].id)
+	util.Must(t, db.pool[2].id == base/86400)
+	ms, err := db.Get("foo", 1, base-86400*3, base+1)
+	util.Must(t, err == nil && len(ms) == 3)
+	util.Must(t, ms[0].Value == 2 && ms[1].Value == 3 && ms[2].Value == 1)
+	// Expired
+	util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: base - 86400*8, Value: 4}) == ErrNoStorage)
+	util.Must(t, len(db.pool) == 3)
+}
+
+func TestDelete(t *testing.T) {
+	// Open db.
+	fileName := "db-testing"
+	opts := &Options{
+		Period:     86400,
+		Expiration: 86400 * 7,
+		Rollups:    []RollupOptions{{Interval: 60, Period: 86400 * 6, Expiration: 86400 * 30}},
+	}
+	db, _ := Open(fileName, opts)
+	defer os.RemoveAll(fileName)
+	defer db.Close()
+	base := uint32(time.Now().Unix()) / 86400 * 86400
+	// Put metrics of 2 links into 2 storages, the older one is sealed.
+	for _, stamp := range []uint32{base, base + 10, base + 86400} {
+		util.Must(t, db.Put(&models.Metric{Link: 1, Stamp: stamp, Value: 1}) == nil)
+		util.Must(t, db.Put(&models.Metric{Link: 2, Stamp: stamp, Value: 2}) == nil)
+	}
+	db.wg.Wait()
+	// Delete link 1.
+	util.Must(t, db.Delete(1) == nil)
+	util.Must(t, db.Delete(0) == ErrNoLink)
+	ms, err := db.get("foo", 1, base, base+86400*2)
+	util.Must(t, err == nil && len(ms) == 0)
+	ms, err = db.rollups[0].get("foo", 1, base, base+86400*2)
+	util.Must(t, err == nil && len(ms) == 0)
+	_, ok := db.rollups[0].shards[1].aggs[1]
+	util.Must(t, !ok)
+	// Link 2 is kept.
+	ms, err = db.get("foo", 2, base, base+86400*2)
+	util.Must(t, err == nil && len(ms) == 3)
+	ms, err = db.rollups[0].get("foo", 2, base, base+86400*2)
+	util.Must(t, err == nil && len(ms) == 2)
+}
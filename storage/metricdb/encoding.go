@@ -16,6 +16,13 @@ import (
 //	| Link (4) | Stamp (4) | Value (8) | Score (8) | Average (8) |
 //	+----------+-----------+-----------+-----------+-------------+
 //
+// Rollup metrics have additional 20 bytes in value:
+//
+//	|-------------- Value (24) -----------|------ Rollup (20) -------|
+//	+-----------+-----------+-------------+-----------+------+------+
+//	| Value (8) | Score (8) | Average (8) | Count (4) | Min  | Max  |
+//	+-----------+-----------+-------------+-----------+------+------+
+//
 
 // encodeKey encodes db key from metric.
 func encodeKey(m *models.Metric) []byte {
@@ -27,10 +34,20 @@ func encodeKey(m *models.Metric) []byte {
 
 // encodeValue encodes db value from metric.
 func encodeValue(m *models.Metric) []byte {
-	b := make([]byte, 8+8+8)
+	n := 8 + 8 + 8
+	if m.Rollup != nil {
+		n += 4 + 8 + 8
+	}
+	b := make([]byte, n)
 	binary.BigEndian.PutUint64(b[:8], math.Float64bits(m.Value))
 	binary.BigEndian.PutUint64(b[8:8+8], math.Float64bits(m.Score))
-	binary.BigEndian.PutUint64(b[8+8:], math.Float64bits(m.Average))
+	binary.BigEndian.PutUint64(b[8+8:8+8+8], math.Float64bits(m.Average))
+	if m.Rollup != nil {
+		r := b[8+8+8:]
+		binary.BigEndian.PutUint32(r[:4], m.Rollup.Count)
+		binary.BigEndian.PutUint64(r[4:4+8], math.Float64bits(m.Rollup.Min))
+		binary.BigEndian.PutUint64(r[4+8:], math.Float64bits(m.Rollup.Max))
+	}
 	return b
 }
 
@@ -51,9 +68,9 @@ func decodeKey(key []byte, m *models.Metric) (err error) {
 }
 
 // decodeValue decodes db value into metric, this will fill metric value,
-// average and stddev.
+// average and stddev, and the rollup for rollup metrics.
 func decodeValue(value []byte, m *models.Metric) (err error) {
-	if len(value) != 8+8+8 {
+	if len(value) != 8+8+8 && len(value) != 8+8+8+4+8+8 {
 		return ErrCorrupted
 	}
 	r := bytes.NewReader(value)
@@ -66,5 +83,21 @@ func decodeValue(value []byte, m *models.Metric) (err error) {
 	if err = binary.Read(r, binary.BigEndian, &m.Average); err != nil {
 		return
 	}
+	if r.Len() == 0 {
+		return nil
+	}
+	// Rollup
+	if m.Rollup == nil {
+		m.Rollup = &models.MetricRollup{}
+	}
+	if err = binary.Read(r, binary.BigEndian, &m.Rollup.Count); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &m.Rollup.Min); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &m.Rollup.Max); err != nil {
+		return
+	}
 	return nil
 }
@@ -31,3 +31,18 @@ func TestEncodingValue(t *testing.T) {
 	util.Must(t, nil == decodeValue(value, m1))
 	util.Must(t, reflect.DeepEqual(m, m1))
 }
+
+func TestEncodingValueRollup(t *testing.T) {
+	m := &models.Metric{
+		Value:   3.1415926,
+		Score:   0.1892,
+		Average: 3.1333333,
+		Rollup:  &models.MetricRollup{Count: 6, Min: 1.2, Max: 5.3},
+	}
+	value := encodeValue(m)
+	util.Must(t, len(value) == 8+8+8+4+8+8)
+	m1 := &models.Metric{}
+	util.Must(t, nil == decodeValue(value, m1))
+	util.Must(t, reflect.DeepEqual(m, m1))
+	util.Must(t, decodeValue(value[:8+8+8+4], m1) == ErrCorrupted)
+}
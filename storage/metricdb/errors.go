@@ -14,4 +14,7 @@ var (
 	// ErrNoStorage is returned when no storage is able to serve, which
 	// indicates that given stamp or stamp range may be invalid.
 	ErrNoStorage = errors.New("metricdb: no storage")
+	// ErrSealAborted is returned when the storage sealing is aborted on DB
+	// closing.
+	ErrSealAborted = errors.New("metricdb: seal aborted")
 )
@@ -0,0 +1,371 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package metricdb
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	"github.com/eleme/banshee/models"
+)
+
+// Gorilla-style block encoding, see the paper "Gorilla: A Fast, Scalable,
+// In-Memory Time Series Database" by Facebook.
+//
+// Block format:
+//
+//	+-----------+-----------+----------------------------------------+
+//	| Flags (1) | Count (n) | Bit stream of metrics                  |
+//	+-----------+-----------+----------------------------------------+
+//
+// The count is an uvarint, each metric in the bit stream is encoded as the
+// stamp (delta of delta) followed by the value, score and average (XOR),
+// and the rollup count, min and max (XOR) for rollup metrics.
+//
+// Delta of delta encoding of stamps:
+//
+//	First stamp               32 bits
+//	dod == 0                  '0'
+//	dod in [-63, 64]          '10'   + 7 bits
+//	dod in [-255, 256]        '110'  + 9 bits
+//	dod in [-2047, 2048]      '1110' + 12 bits
+//	Otherwise                 '1111' + 64 bits
+//
+// XOR encoding of floats:
+//
+//	First value                    64 bits
+//	xor == 0                       '0'
+//	Meaningful bits in window      '10' + meaningful bits
+//	Otherwise                      '11' + 5 bits leading zeros
+//	                                    + 6 bits meaningful length
+//	                                    + meaningful bits
+
+// Block flags.
+const blockFlagRollup = 1 << 0
+
+// bitWriter writes bits into a byte slice.
+type bitWriter struct {
+	b []byte
+	n uint // number of bits used in the last byte
+}
+
+// writeBit writes a single bit.
+func (w *bitWriter) writeBit(bit bool) {
+	if w.n == 0 || w.n == 8 {
+		w.b = append(w.b, 0)
+		w.n = 0
+	}
+	if bit {
+		w.b[len(w.b)-1] |= 1 << (7 - w.n)
+	}
+	w.n++
+}
+
+// writeBits writes the lowest nbits of v, the highest bit first.
+func (w *bitWriter) writeBits(v uint64, nbits uint) {
+	for i := nbits; i > 0; i-- {
+		w.writeBit(v>>(i-1)&1 == 1)
+	}
+}
+
+// bitReader reads bits from a byte slice.
+type bitReader struct {
+	b []byte
+	i int  // index of the byte to read
+	n uint // number of bits read in the byte
+}
+
+// readBit reads a single bit.
+func (r *bitReader) readBit() (bool, error) {
+	if r.i >= len(r.b) {
+		return false, ErrCorrupted
+	}
+	bit := r.b[r.i]>>(7-r.n)&1 == 1
+	r.n++
+	if r.n == 8 {
+		r.i++
+		r.n = 0
+	}
+	return bit, nil
+}
+
+// readBits reads nbits into the lowest bits of an uint64.
+func (r *bitReader) readBits(nbits uint) (uint64, error) {
+	var v uint64
+	for i := uint(0); i < nbits; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v <<= 1
+		if bit {
+			v |= 1
+		}
+	}
+	return v, nil
+}
+
+// Delta of delta buckets.
+var dodBuckets = []struct {
+	min, max int64
+	nbits    uint
+}{
+	{-63, 64, 7},
+	{-255, 256, 9},
+	{-2047, 2048, 12},
+}
+
+// stampEncoder encodes stamps by delta of delta.
+type stampEncoder struct {
+	prev  uint32
+	delta int64
+	n     int
+}
+
+// encode a stamp.
+func (e *stampEncoder) encode(w *bitWriter, stamp uint32) {
+	if e.n == 0 {
+		w.writeBits(uint64(stamp), 32)
+		e.prev = stamp
+		e.n++
+		return
+	}
+	delta := int64(stamp) - int64(e.prev)
+	dod := delta - e.delta
+	e.prev, e.delta = stamp, delta
+	e.n++
+	if dod == 0 {
+		w.writeBit(false)
+		return
+	}
+	for _, bucket := range dodBuckets {
+		w.writeBit(true)
+		if bucket.min <= dod && dod <= bucket.max {
+			w.writeBit(false)
+			w.writeBits(uint64(dod-bucket.min), bucket.nbits)
+			return
+		}
+	}
+	w.writeBit(true)
+	w.writeBits(uint64(dod), 64)
+}
+
+// decode a stamp.
+func (e *stampEncoder) decode(r *bitReader) (uint32, error) {
+	if e.n == 0 {
+		v, err := r.readBits(32)
+		if err != nil {
+			return 0, err
+		}
+		e.prev = uint32(v)
+		e.n++
+		return e.prev, nil
+	}
+	var dod int64
+	bit, err := r.readBit()
+	if err != nil {
+		return 0, err
+	}
+	if bit {
+		found := false
+		for _, bucket := range dodBuckets {
+			if bit, err = r.readBit(); err != nil {
+				return 0, err
+			}
+			if !bit {
+				v, err := r.readBits(bucket.nbits)
+				if err != nil {
+					return 0, err
+				}
+				dod = int64(v) + bucket.min
+				found = true
+				break
+			}
+		}
+		if !found {
+			v, err := r.readBits(64)
+			if err != nil {
+				return 0, err
+			}
+			dod = int64(v)
+		}
+	}
+	e.delta += dod
+	e.prev = uint32(int64(e.prev) + e.delta)
+	e.n++
+	return e.prev, nil
+}
+
+// floatEncoder encodes floats by XOR with the previous value.
+type floatEncoder struct {
+	prev     uint64
+	leading  uint
+	trailing uint
+	n        int
+}
+
+// encode a float.
+func (e *floatEncoder) encode(w *bitWriter, f float64) {
+	v := math.Float64bits(f)
+	if e.n == 0 {
+		w.writeBits(v, 64)
+		e.prev = v
+		e.n++
+		return
+	}
+	x := v ^ e.prev
+	e.prev = v
+	e.n++
+	if x == 0 {
+		w.writeBit(false)
+		return
+	}
+	w.writeBit(true)
+	leading := uint(bits.LeadingZeros64(x))
+	trailing := uint(bits.TrailingZeros64(x))
+	if leading > 31 {
+		leading = 31 // 5 bits
+	}
+	if e.n > 2 && leading >= e.leading && trailing >= e.trailing {
+		// In the previous window.
+		w.writeBit(false)
+		w.writeBits(x>>e.trailing, 64-e.leading-e.trailing)
+		return
+	}
+	e.leading, e.trailing = leading, trailing
+	w.writeBit(true)
+	w.writeBits(uint64(leading), 5)
+	sig := 64 - leading - trailing
+	w.writeBits(uint64(sig), 6) // 64 => 0
+	w.writeBits(x>>trailing, sig)
+}
+
+// decode a float.
+func (e *floatEncoder) decode(r *bitReader) (float64, error) {
+	if e.n == 0 {
+		v, err := r.readBits(64)
+		if err != nil {
+			return 0, err
+		}
+		e.prev = v
+		e.n++
+		return math.Float64frombits(v), nil
+	}
+	e.n++
+	bit, err := r.readBit()
+	if err != nil {
+		return 0, err
+	}
+	if !bit {
+		return math.Float64frombits(e.prev), nil
+	}
+	if bit, err = r.readBit(); err != nil {
+		return 0, err
+	}
+	if bit {
+		leading, err := r.readBits(5)
+		if err != nil {
+			return 0, err
+		}
+		sig, err := r.readBits(6)
+		if err != nil {
+			return 0, err
+		}
+		if sig == 0 {
+			sig = 64
+		}
+		if leading+sig > 64 {
+			return 0, ErrCorrupted
+		}
+		e.leading = uint(leading)
+		e.trailing = 64 - uint(leading) - uint(sig)
+	}
+	x, err := r.readBits(64 - e.leading - e.trailing)
+	if err != nil {
+		return 0, err
+	}
+	e.prev ^= x << e.trailing
+	return math.Float64frombits(e.prev), nil
+}
+
+// blockEncoders holds encoders for all columns of a block.
+type blockEncoders struct {
+	stamp                 stampEncoder
+	value, score, average floatEncoder
+	count, min, max       floatEncoder
+}
+
+// encodeBlock encodes metrics of the same link into a block, the metrics
+// should be sorted by stamp, and all or none of them are rollup metrics.
+func encodeBlock(ms []*models.Metric) []byte {
+	var flags byte
+	if len(ms) > 0 && ms[0].Rollup != nil {
+		flags |= blockFlagRollup
+	}
+	head := make([]byte, 1+binary.MaxVarintLen64)
+	head[0] = flags
+	n := binary.PutUvarint(head[1:], uint64(len(ms)))
+	w := &bitWriter{b: head[:1+n]}
+	w.n = 8
+	var e blockEncoders
+	for _, m := range ms {
+		e.stamp.encode(w, m.Stamp)
+		e.value.encode(w, m.Value)
+		e.score.encode(w, m.Score)
+		e.average.encode(w, m.Average)
+		if flags&blockFlagRollup != 0 {
+			e.count.encode(w, float64(m.Rollup.Count))
+			e.min.encode(w, m.Rollup.Min)
+			e.max.encode(w, m.Rollup.Max)
+		}
+	}
+	return w.b
+}
+
+// decodeBlock decodes a block into metrics with the link.
+func decodeBlock(b []byte, link uint32) ([]*models.Metric, error) {
+	if len(b) < 1 {
+		return nil, ErrCorrupted
+	}
+	flags := b[0]
+	count, n := binary.Uvarint(b[1:])
+	if n <= 0 {
+		return nil, ErrCorrupted
+	}
+	r := &bitReader{b: b[1+n:]}
+	var e blockEncoders
+	ms := make([]*models.Metric, 0, count)
+	for i := uint64(0); i < count; i++ {
+		m := &models.Metric{Link: link}
+		var err error
+		if m.Stamp, err = e.stamp.decode(r); err != nil {
+			return nil, err
+		}
+		if m.Value, err = e.value.decode(r); err != nil {
+			return nil, err
+		}
+		if m.Score, err = e.score.decode(r); err != nil {
+			return nil, err
+		}
+		if m.Average, err = e.average.decode(r); err != nil {
+			return nil, err
+		}
+		if flags&blockFlagRollup != 0 {
+			m.Rollup = &models.MetricRollup{}
+			count, err := e.count.decode(r)
+			if err != nil {
+				return nil, err
+			}
+			m.Rollup.Count = uint32(count)
+			if m.Rollup.Min, err = e.min.decode(r); err != nil {
+				return nil, err
+			}
+			if m.Rollup.Max, err = e.max.decode(r); err != nil {
+				return nil, err
+			}
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
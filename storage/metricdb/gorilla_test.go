@@ -0,0 +1,61 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package metricdb
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util"
+)
+
+func TestBlockEncoding(t *testing.T) {
+	var ms []*models.Metric
+	stamp := uint32(1452758773)
+	for i := 0; i < maxBlockSize; i++ {
+		// Irregular intervals and values.
+		stamp += uint32(10 + i%3*(i%7)*100)
+		if i == 50 {
+			stamp += 86400
+		}
+		v := 100 + 10*math.Sin(float64(i))
+		if i%5 == 0 {
+			v = 0
+		}
+		ms = append(ms, &models.Metric{Link: 1, Stamp: stamp, Value: v, Score: v / 1000, Average: 100})
+	}
+	b := encodeBlock(ms)
+	ms1, err := decodeBlock(b, 1)
+	util.Must(t, err == nil)
+	util.Must(t, reflect.DeepEqual(ms, ms1))
+	// Compressed.
+	util.Must(t, len(b) < len(ms)*(4+4+8+8+8))
+	// Corrupted.
+	_, err = decodeBlock(b[:len(b)/2], 1)
+	util.Must(t, err == ErrCorrupted)
+}
+
+func TestBlockEncodingRegular(t *testing.T) {
+	var ms []*models.Metric
+	for i := 0; i < maxBlockSize; i++ {
+		ms = append(ms, &models.Metric{Link: 1, Stamp: 1452758773 + uint32(i*10), Value: 3, Average: 3})
+	}
+	b := encodeBlock(ms)
+	ms1, err := decodeBlock(b, 1)
+	util.Must(t, err == nil)
+	util.Must(t, reflect.DeepEqual(ms, ms1))
+	// About 4 bits per regular metric.
+	util.Must(t, len(b) < 100)
+}
+
+func TestBlockEncodingRollup(t *testing.T) {
+	ms := []*models.Metric{
+		{Link: 2, Stamp: 1452758760, Value: 1.5, Rollup: &models.MetricRollup{Count: 6, Min: 1, Max: 2}},
+		{Link: 2, Stamp: 1452758820, Value: 2.5, Rollup: &models.MetricRollup{Count: 5, Min: 2, Max: 3}},
+	}
+	ms1, err := decodeBlock(encodeBlock(ms), 2)
+	util.Must(t, err == nil)
+	util.Must(t, reflect.DeepEqual(ms, ms1))
+}
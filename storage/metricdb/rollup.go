@@ -0,0 +1,179 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package metricdb
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eleme/banshee/models"
+)
+
+// Dirname prefix of rollup tiers.
+const rollupDirPrefix = "rollup-"
+
+// RollupOptions is to open a rollup tier.
+type RollupOptions struct {
+	// Interval in seconds to aggregate metrics.
+	Interval uint32
+	// Period of each storage and expiration of the tier.
+	Period     uint32
+	Expiration uint32
+}
+
+// Number of lock shards of a rollup tier, by link.
+const rollupShards = 64
+
+// rollup is a tier of metrics aggregated by an interval.
+type rollup struct {
+	interval uint32
+	db       *DB
+	// Raw metrics DB.
+	raw    *DB
+	shards [rollupShards]rollupShard
+}
+
+// rollupShard holds pending aggregations of a part of the links.
+type rollupShard struct {
+	// Pending aggregations by link.
+	aggs map[uint32]*rollupAgg
+	// Stamp of the last eviction.
+	evicted uint32
+	lock    sync.Mutex // protects aggs
+}
+
+// rollupAgg is a pending aggregation.
+type rollupAgg struct {
+	m *models.Metric
+	// Stamp of the last raw metric aggregated.
+	last uint32
+}
+
+// newRollup creates a rollup tier.
+func newRollup(interval uint32, db, raw *DB) *rollup {
+	r := &rollup{interval: interval, db: db, raw: raw}
+	for i := range r.shards {
+		r.shards[i].aggs = make(map[uint32]*rollupAgg)
+	}
+	return r
+}
+
+// rollupDirName returns the dirname of the rollup tier by interval.
+func rollupDirName(interval uint32) string {
+	return rollupDirPrefix + strconv.FormatUint(uint64(interval), 10)
+}
+
+// isRollupDirName returns true if the dirname is of a rollup tier.
+func isRollupDirName(name string) bool {
+	return strings.HasPrefix(name, rollupDirPrefix)
+}
+
+// put aggregates a raw metric into the tier.
+func (r *rollup) put(m *models.Metric) error {
+	stamp := m.Stamp / r.interval * r.interval
+	sh := &r.shards[m.Link%rollupShards]
+	sh.lock.Lock()
+	defer sh.lock.Unlock()
+	sh.evict(stamp, r.interval)
+	agg, ok := sh.aggs[m.Link]
+	if ok && agg.m.Stamp == stamp && agg.last < m.Stamp {
+		aggregate(agg.m, m)
+		agg.last = m.Stamp
+	} else {
+		// Restarts, metrics out of order or put again.
+		a, err := r.load(m, stamp)
+		if err != nil {
+			return err
+		}
+		if !ok || agg.m.Stamp <= stamp {
+			sh.aggs[m.Link] = a
+		}
+		agg = a
+	}
+	return r.db.Put(agg.m)
+}
+
+// load the aggregation of an interval with given raw metric. It is
+// aggregated again from the raw metrics if they are not expired, so that
+// metrics put again are not counted twice.
+func (r *rollup) load(m *models.Metric, stamp uint32) (*rollupAgg, error) {
+	agg := &rollupAgg{m: &models.Metric{Link: m.Link, Stamp: stamp, Rollup: &models.MetricRollup{}}}
+	if r.raw.covers(stamp) {
+		ms, err := r.raw.get(m.Name, m.Link, stamp, stamp+r.interval)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range ms {
+			aggregate(agg.m, raw)
+			agg.last = raw.Stamp
+		}
+		return agg, nil
+	}
+	// Raw metrics expired, e.g. imported.
+	ms, err := r.db.get(m.Name, m.Link, stamp, stamp+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(ms) > 0 {
+		agg.m = ms[0]
+	}
+	aggregate(agg.m, m)
+	agg.last = m.Stamp
+	return agg, nil
+}
+
+// evict pending aggregations without metrics in the last interval, at most
+// once an interval.
+func (sh *rollupShard) evict(stamp, interval uint32) {
+	if stamp < sh.evicted+interval {
+		return
+	}
+	for link, agg := range sh.aggs {
+		if agg.m.Stamp < sh.evicted {
+			delete(sh.aggs, link)
+		}
+	}
+	sh.evicted = stamp
+}
+
+// delete all rollup metrics of a link, also the pending aggregation.
+func (r *rollup) delete(link uint32) error {
+	sh := &r.shards[link%rollupShards]
+	sh.lock.Lock()
+	defer sh.lock.Unlock()
+	delete(sh.aggs, link)
+	return r.db.Delete(link)
+}
+
+// aggregate a raw metric into the rollup metric: the value and average are
+// averaged, and the score with the max absolute value is kept.
+func aggregate(agg, m *models.Metric) {
+	rl := agg.Rollup
+	if rl.Count == 0 || m.Value < rl.Min {
+		rl.Min = m.Value
+	}
+	if rl.Count == 0 || m.Value > rl.Max {
+		rl.Max = m.Value
+	}
+	rl.Count++
+	n := float64(rl.Count)
+	agg.Value += (m.Value - agg.Value) / n
+	agg.Average += (m.Average - agg.Average) / n
+	if math.Abs(m.Score) >= math.Abs(agg.Score) {
+		agg.Score = m.Score
+	}
+}
+
+// get rollup metrics in a timestamp range.
+func (r *rollup) get(name string, link, start, end uint32) ([]*models.Metric, error) {
+	ms, err := r.db.Get(name, link, start/r.interval*r.interval, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range ms {
+		m.Rollup.Interval = r.interval
+	}
+	return ms, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package metricdb
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/eleme/banshee/models"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Max number of metrics in a block.
+const maxBlockSize = 120
+
+// Key to mark a storage as sealed.
+var sealedKey = []byte{0}
+
+// Suffix of block keys.
+const blockKeySuffix = 'B'
+
+// encodeBlockKey encodes the key of a block by link and the first stamp.
+//
+//	+----------+-----------+------------+
+//	| Link (4) | Stamp (4) | Suffix (1) |
+//	+----------+-----------+------------+
+//
+func encodeBlockKey(link, stamp uint32) []byte {
+	b := make([]byte, 4+4+1)
+	binary.BigEndian.PutUint32(b[:4], link)
+	binary.BigEndian.PutUint32(b[4:8], stamp)
+	b[8] = blockKeySuffix
+	return b
+}
+
+// isBlockKey returns true if the key is a block key.
+func isBlockKey(key []byte) bool {
+	return len(key) == 4+4+1 && key[8] == blockKeySuffix
+}
+
+// isSealed returns true if the storage is sealed.
+func (s *storage) isSealed() (bool, error) {
+	ok, err := s.db.Has(sealedKey, nil)
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// seal compresses all raw metrics in the storage into blocks, the metrics
+// of a block are written and deleted in a batch, so the storage is always
+// readable during sealing. Returns ErrSealAborted if quit is closed.
+func (s *storage) seal(quit <-chan struct{}) error {
+	iter := s.db.NewIterator(nil, nil)
+	defer iter.Release()
+	var (
+		link uint32
+		ms   []*models.Metric
+		keys [][]byte
+		vals [][]byte
+	)
+	flush := func() error {
+		if len(ms) == 0 {
+			return nil
+		}
+		defer func() { ms, keys, vals = nil, nil, nil }()
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		// Metrics may be put again or the link deleted meanwhile, skip the
+		// changed ones.
+		var bms []*models.Metric
+		batch := new(leveldb.Batch)
+		for i, key := range keys {
+			v, err := s.db.Get(key, nil)
+			if err == leveldb.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(v, vals[i]) {
+				continue
+			}
+			bms = append(bms, ms[i])
+			batch.Delete(key)
+		}
+		if len(bms) == 0 {
+			return nil
+		}
+		batch.Put(encodeBlockKey(link, bms[0].Stamp), encodeBlock(bms))
+		return s.db.Write(batch, nil)
+	}
+	for iter.Next() {
+		select {
+		case <-quit:
+			return ErrSealAborted
+		default:
+		}
+		key := iter.Key()
+		if len(key) != 4+4 {
+			// Blocks or the sealed marker.
+			continue
+		}
+		m := &models.Metric{}
+		if err := decodeKey(key, m); err != nil {
+			return err
+		}
+		if err := decodeValue(iter.Value(), m); err != nil {
+			return err
+		}
+		if len(ms) > 0 && (m.Link != link || len(ms) >= maxBlockSize ||
+			(m.Rollup == nil) != (ms[0].Rollup == nil)) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		link = m.Link
+		ms = append(ms, m)
+		keys = append(keys, append([]byte(nil), key...))
+		vals = append(vals, append([]byte(nil), iter.Value()...))
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if err := s.db.Put(sealedKey, []byte{1}, nil); err != nil {
+		return err
+	}
+	// Reclaim disk space.
+	return s.db.CompactRange(util.Range{})
+}
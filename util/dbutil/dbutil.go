@@ -0,0 +1,122 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+// Package dbutil implements online copying of leveldb and sqlite databases.
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Number of leveldb entries per batch on copying.
+const batchSize = 1024
+
+// Backoff to retry sqlite backup steps on busy or locked.
+const (
+	minBackupWait = 10 * time.Millisecond
+	maxBackupWait = time.Second
+)
+
+// ErrNotSQLite is returned if the connection is not a sqlite connection.
+var ErrNotSQLite = errors.New("dbutil: not a sqlite connection")
+
+// CopySnapshot copies all entries in the leveldb snapshot into a new leveldb
+// by fileName, the snapshot is released on return.
+func CopySnapshot(snap *leveldb.Snapshot, fileName string) error {
+	defer snap.Release()
+	if _, err := os.Stat(fileName); err == nil {
+		return os.ErrExist
+	}
+	ldb, err := leveldb.OpenFile(fileName, nil)
+	if err != nil {
+		return err
+	}
+	defer ldb.Close()
+	iter := snap.NewIterator(nil, nil)
+	defer iter.Release()
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		batch.Put(iter.Key(), iter.Value())
+		if batch.Len() >= batchSize {
+			if err := ldb.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if err := ldb.Write(batch, nil); err != nil {
+		return err
+	}
+	return ldb.Close()
+}
+
+// BackupSQLite copies the sqlite db into a new sqlite file by fileName via
+// the sqlite online backup api, writers are not blocked during the copying.
+func BackupSQLite(db *sql.DB, fileName string) error {
+	if _, err := os.Stat(fileName); err == nil {
+		return os.ErrExist
+	}
+	dst, err := sql.Open("sqlite3", fileName)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	ctx := context.Background()
+	srcConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+	return dstConn.Raw(func(dc interface{}) error {
+		return srcConn.Raw(func(sc interface{}) error {
+			d, ok := dc.(*sqlite3.SQLiteConn)
+			if !ok {
+				return ErrNotSQLite
+			}
+			s, ok := sc.(*sqlite3.SQLiteConn)
+			if !ok {
+				return ErrNotSQLite
+			}
+			return backupSQLite(d, s)
+		})
+	})
+}
+
+// backupSQLite copies the main database from src to dst.
+func backupSQLite(dst, src *sqlite3.SQLiteConn) error {
+	bk, err := dst.Backup("main", src, "main")
+	if err != nil {
+		return err
+	}
+	wait := minBackupWait
+	for {
+		// Step returns not done on busy or locked, retry with backoff.
+		done, err := bk.Step(-1)
+		if err != nil {
+			bk.Close()
+			return err
+		}
+		if done {
+			break
+		}
+		time.Sleep(wait)
+		if wait *= 2; wait > maxBackupWait {
+			wait = maxBackupWait
+		}
+	}
+	return bk.Close()
+}
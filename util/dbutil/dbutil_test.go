@@ -0,0 +1,62 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package dbutil
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/eleme/banshee/util"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func TestCopySnapshot(t *testing.T) {
+	src, dst := "dbutil-src", "dbutil-dst"
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+	db, err := leveldb.OpenFile(src, nil)
+	util.Must(t, err == nil)
+	defer db.Close()
+	for i := 0; i < batchSize*2+3; i++ {
+		db.Put([]byte(strconv.Itoa(i)), []byte("v"+strconv.Itoa(i)), nil)
+	}
+	snap, err := db.GetSnapshot()
+	util.Must(t, err == nil)
+	// Writes after the snapshot are not copied.
+	db.Put([]byte("after"), []byte("v"), nil)
+	util.Must(t, CopySnapshot(snap, dst) == nil)
+	cp, err := leveldb.OpenFile(dst, nil)
+	util.Must(t, err == nil)
+	defer cp.Close()
+	v, err := cp.Get([]byte("2050"), nil)
+	util.Must(t, err == nil && string(v) == "v2050")
+	_, err = cp.Get([]byte("after"), nil)
+	util.Must(t, err == leveldb.ErrNotFound)
+	// Existing destination.
+	snap, _ = db.GetSnapshot()
+	util.Must(t, CopySnapshot(snap, dst) == os.ErrExist)
+}
+
+func TestBackupSQLite(t *testing.T) {
+	src, dst := "dbutil-src.db", "dbutil-dst.db"
+	defer os.Remove(src)
+	defer os.Remove(dst)
+	db, err := sql.Open("sqlite3", src)
+	util.Must(t, err == nil)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE t (v INTEGER)")
+	util.Must(t, err == nil)
+	_, err = db.Exec("INSERT INTO t VALUES (1), (2)")
+	util.Must(t, err == nil)
+	util.Must(t, BackupSQLite(db, dst) == nil)
+	cp, err := sql.Open("sqlite3", dst)
+	util.Must(t, err == nil)
+	defer cp.Close()
+	var n int
+	util.Must(t, cp.QueryRow("SELECT SUM(v) FROM t").Scan(&n) == nil)
+	util.Must(t, n == 3)
+	// Existing destination.
+	util.Must(t, BackupSQLite(db, dst) == os.ErrExist)
+}
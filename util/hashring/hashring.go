@@ -0,0 +1,75 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+// Package hashring implements a consistent hash ring to map keys to nodes.
+//
+// Example
+//
+//	r := hashring.New(hashring.DefaultReplicas, "node1", "node2")
+//	r.Get("timer.count_ps.api") // "node2"
+//
+// Each node is placed on the ring by a number of virtual nodes, adding or
+// removing a node only remaps the keys of its neighbours on the ring.
+package hashring
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is the default number of virtual nodes for a node.
+const DefaultReplicas = 160
+
+// Ring is the consistent hash ring, it's immutable after created and safe for
+// concurrent use.
+type Ring struct {
+	hashes []uint32
+	nodes  map[uint32]string
+}
+
+// New creates a Ring with replicas virtual nodes for each node.
+func New(replicas int, nodes ...string) *Ring {
+	r := &Ring{nodes: make(map[uint32]string, replicas*len(nodes))}
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			h := hash(strconv.Itoa(i) + node)
+			if _, ok := r.nodes[h]; ok {
+				// Collision, first node wins.
+				continue
+			}
+			r.nodes[h] = node
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Sort(uint32Slice(r.hashes))
+	return r
+}
+
+// Get returns the node the key belongs to, empty string if the ring is empty.
+func (r *Ring) Get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.nodes[r.hashes[i]]
+}
+
+// Len returns the number of virtual nodes on the ring.
+func (r *Ring) Len() int {
+	return len(r.hashes)
+}
+
+func hash(key string) uint32 {
+	return crc32.ChecksumIEEE([]byte(key))
+}
+
+// uint32Slice implements sort.Interface.
+type uint32Slice []uint32
+
+func (s uint32Slice) Len() int           { return len(s) }
+func (s uint32Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint32Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
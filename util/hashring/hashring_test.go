@@ -0,0 +1,45 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package hashring
+
+import (
+	"github.com/eleme/banshee/util"
+	"strconv"
+	"testing"
+)
+
+func TestEmpty(t *testing.T) {
+	r := New(DefaultReplicas)
+	util.Must(t, r.Len() == 0)
+	util.Must(t, r.Get("foo") == "")
+}
+
+func TestGet(t *testing.T) {
+	r := New(DefaultReplicas, "node1", "node2", "node3")
+	util.Must(t, r.Len() <= 3*DefaultReplicas)
+	// Consistent
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := "timer.count_ps.api" + strconv.Itoa(i)
+		node := r.Get(key)
+		util.Must(t, node == r.Get(key))
+		counts[node]++
+	}
+	// Balanced
+	util.Must(t, len(counts) == 3)
+	for _, n := range counts {
+		util.Must(t, n > 500)
+	}
+}
+
+func TestRemap(t *testing.T) {
+	r1 := New(DefaultReplicas, "node1", "node2", "node3")
+	r2 := New(DefaultReplicas, "node1", "node2", "node3", "node4")
+	for i := 0; i < 3000; i++ {
+		key := "timer.count_ps.api" + strconv.Itoa(i)
+		// Keys only move to the new node.
+		if n := r2.Get(key); n != r1.Get(key) {
+			util.Must(t, n == "node4")
+		}
+	}
+}
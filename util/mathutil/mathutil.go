@@ -3,7 +3,10 @@
 // Package mathutil provides math util functions.
 package mathutil
 
-import "math"
+import (
+	"math"
+	"sort"
+)
 
 // Average returns the mean value of float64 values.
 func Average(vals []float64) float64 {
@@ -24,3 +27,31 @@ func StdDev(vals []float64, avg float64) float64 {
 	}
 	return math.Sqrt(sum / float64(len(vals)))
 }
+
+// Median returns the median value of float64 values, the input values won't
+// be modified.
+func Median(vals []float64) float64 {
+	return Percentile(vals, 50)
+}
+
+// Percentile returns the p-th (0~100) percentile of float64 values via
+// linear interpolation between closest ranks, the input values won't be
+// modified.
+func Percentile(vals []float64, p float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(vals))
+	copy(sorted, vals)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	if lo < 0 {
+		return sorted[0]
+	}
+	if hi >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
@@ -18,6 +18,22 @@ func TestStdDev(t *testing.T) {
 	util.Must(t, StdDev(vals, Average(vals)) == .5)
 }
 
+func TestMedian(t *testing.T) {
+	util.Must(t, Median([]float64{3, 1, 2}) == 2)
+	util.Must(t, Median([]float64{4, 1, 3, 2}) == 2.5)
+	util.Must(t, Median(nil) == 0)
+}
+
+func TestPercentile(t *testing.T) {
+	vals := []float64{5, 1, 4, 2, 3}
+	util.Must(t, Percentile(vals, 0) == 1)
+	util.Must(t, Percentile(vals, 100) == 5)
+	util.Must(t, Percentile(vals, 25) == 2)
+	util.Must(t, Percentile(vals, 90) == 4.6)
+	// Input should not be modified.
+	util.Must(t, vals[0] == 5 && vals[1] == 1)
+}
+
 func genValues(n int) []float64 {
 	var vals []float64
 	for i := 0; i < n; i++ {
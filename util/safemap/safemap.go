@@ -89,8 +89,8 @@ func (m *SafeMap) Len() int {
 
 // Clear the map.
 func (m *SafeMap) Clear() {
-	m.lock.RLock()
-	defer m.lock.RUnlock()
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	// Rely on GC
 	m.m = make(map[interface{}]interface{})
 }
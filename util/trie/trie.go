@@ -8,6 +8,8 @@ package trie
 import (
 	"strings"
 	"sync"
+
+	"github.com/eleme/banshee/util/wildcard"
 )
 
 // tree is the internal tree.
@@ -154,16 +156,23 @@ func (tr *Trie) Clear() {
 	tr.length = 0
 }
 
-// Match a wildcard like pattern in the trie, the pattern is not a traditional
-// wildcard, only "*" is supported.
+// Match a wildcard like pattern in the trie, see package wildcard for the
+// syntax: "*", "**" and "{a,b}" are supported.
 func (tr *Trie) Match(pattern string) map[string]interface{} {
-	return tr.root.match(tr.delim, nil, strings.Split(pattern, tr.delim))
+	m := make(map[string]interface{}, 0)
+	tr.root.match(tr.delim, nil, strings.Split(pattern, tr.delim), m)
+	return m
 }
 
-// match keys in the tree recursively.
-func (t *tree) match(delim string, keys []string, parts []string) map[string]interface{} {
-	m := make(map[string]interface{}, 0)
-	t.lock.RLock() // touch root.
+// match keys in the tree recursively into m.
+func (t *tree) match(delim string, keys []string, parts []string, m map[string]interface{}) {
+	t.lock.RLock() // touch root
+	defer t.lock.RUnlock()
+	t.matchLocked(delim, keys, parts, m)
+}
+
+// matchLocked matches keys in the tree with the lock held.
+func (t *tree) matchLocked(delim string, keys []string, parts []string, m map[string]interface{}) {
 	if len(parts) == 0 {
 		if t.value != nil {
 			// Generally, strings.Split() won't give us empty results. And the
@@ -171,38 +180,27 @@ func (t *tree) match(delim string, keys []string, parts []string) map[string]int
 			// should pick up all processed keys and return.
 			m[strings.Join(keys, delim)] = t.value
 		}
-		t.lock.RUnlock() // leave root
-		return m
+		return
 	}
-	for i, part := range parts {
-		if part == "*" {
-			for segment, child := range t.children {
-				v := child.match(delim, append(keys, segment), parts[i+1:])
-				for key, value := range v {
-					m[key] = value
-				}
-			}
-			t.lock.RUnlock() // leave parent
-			return m
+	part := parts[0]
+	switch {
+	case part == wildcard.Any:
+		// None of the segments, or one more and then any.
+		t.matchLocked(delim, keys, parts[1:], m)
+		for segment, child := range t.children {
+			child.match(delim, append(keys, segment), parts, m)
 		}
-		child, ok := t.children[part]
-		if !ok {
-			t.lock.RUnlock() // leave parent
-			return m
+	case wildcard.IsLiteral(part):
+		if child, ok := t.children[part]; ok {
+			child.match(delim, append(keys, part), parts[1:], m)
 		}
-		t.lock.RUnlock()   // leave parent
-		child.lock.RLock() // touch child
-		keys = append(keys, part)
-		if i == len(parts)-1 { // last part
-			if child.value != nil {
-				m[strings.Join(keys, delim)] = child.value
+	default:
+		for segment, child := range t.children {
+			if wildcard.MatchSegment(part, segment) {
+				child.match(delim, append(keys, segment), parts[1:], m)
 			}
-			child.lock.RUnlock() // leave child
-			return m
 		}
-		t = child // child as parent
 	}
-	return m
 }
 
 // Map returns the full trie as a map.
@@ -137,6 +137,23 @@ func TestMatch(t *testing.T) {
 	// Case x.*.x
 	m = tr.Match("a.*.*.d")
 	util.Must(t, len(m) == 1)
+	// Case x.**
+	m = tr.Match("a.**")
+	util.Must(t, len(m) == 4)
+	// Case x.**.x
+	m = tr.Match("a.**.f")
+	util.Must(t, len(m) == 2)
+	util.Must(t, m["a.b.c.f"].(int) == 9)
+	util.Must(t, m["a.b.c.d.e.f"].(int) == 6)
+	// Case **.x
+	m = tr.Match("**.p")
+	util.Must(t, len(m) == 1)
+	util.Must(t, m["m.n.o.p"].(int) == 43)
+	// Case in-segment wildcards and alternatives
+	m = tr.Match("{a,m}.*.{c,o}.{d,p}")
+	util.Must(t, len(m) == 2)
+	m = tr.Match("m.n.o.p*")
+	util.Must(t, len(m) == 1)
 }
 
 func TestMap(t *testing.T) {
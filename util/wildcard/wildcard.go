@@ -0,0 +1,132 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+// Package wildcard implements matching of dot delimited names against
+// wildcard patterns, like metric names against rule patterns.
+//
+// Syntax:
+//
+//	"*" matches a whole segment, or any characters in a segment,
+//	e.g. "api_*_latency".
+//	"**" matches any number of whole segments, including none.
+//	"{a,b}" matches any of the alternatives in a segment, e.g. "{get,set}".
+package wildcard
+
+import "strings"
+
+// Delim is the delimiter of segments.
+const Delim = "."
+
+// Any is the segment to match any number of segments.
+const Any = "**"
+
+// IsLiteral returns true if the segment has no wildcards.
+func IsLiteral(segment string) bool {
+	return !strings.ContainsAny(segment, "*{")
+}
+
+// Valid reports whether the pattern is well formed: Any should be a whole
+// segment and not repeated, braces should be paired without nesting.
+func Valid(pattern string) bool {
+	segments := strings.Split(pattern, Delim)
+	for i, segment := range segments {
+		if segment == Any {
+			if i > 0 && segments[i-1] == Any {
+				// Duplicate "**"
+				return false
+			}
+			continue
+		}
+		if strings.Contains(segment, Any) {
+			return false
+		}
+		inBraces := false
+		for j := 0; j < len(segment); j++ {
+			switch segment[j] {
+			case '{':
+				if inBraces {
+					// Nested braces
+					return false
+				}
+				inBraces = true
+			case '}':
+				if !inBraces {
+					// Unpaired braces
+					return false
+				}
+				inBraces = false
+			}
+		}
+		if inBraces {
+			// Unpaired braces
+			return false
+		}
+	}
+	return true
+}
+
+// MatchSegment reports whether the segment matches the segment pattern,
+// which should not be Any.
+func MatchSegment(pattern, segment string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				// Trailing stars match the rest.
+				return true
+			}
+			for i := 0; i <= len(segment); i++ {
+				if MatchSegment(pattern, segment[i:]) {
+					return true
+				}
+			}
+			return false
+		case '{':
+			end := strings.IndexByte(pattern, '}')
+			if end < 0 {
+				// Bad pattern.
+				return false
+			}
+			rest := pattern[end+1:]
+			for _, alt := range strings.Split(pattern[1:end], ",") {
+				if MatchSegment(alt+rest, segment) {
+					return true
+				}
+			}
+			return false
+		default:
+			if len(segment) == 0 || segment[0] != pattern[0] {
+				return false
+			}
+			pattern, segment = pattern[1:], segment[1:]
+		}
+	}
+	return len(segment) == 0
+}
+
+// Match reports whether the name matches the pattern.
+func Match(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, Delim), strings.Split(name, Delim))
+}
+
+// matchSegments reports whether the segments match the pattern segments.
+func matchSegments(patterns, segments []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == Any {
+			patterns = patterns[1:]
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(patterns, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 || !MatchSegment(patterns[0], segments[0]) {
+			return false
+		}
+		patterns, segments = patterns[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
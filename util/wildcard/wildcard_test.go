@@ -0,0 +1,62 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package wildcard
+
+import (
+	"testing"
+
+	"github.com/eleme/banshee/util"
+)
+
+func TestIsLiteral(t *testing.T) {
+	util.Must(t, IsLiteral("abc"))
+	util.Must(t, !IsLiteral("*"))
+	util.Must(t, !IsLiteral("a*c"))
+	util.Must(t, !IsLiteral("{a,b}"))
+}
+
+func TestMatchSegment(t *testing.T) {
+	util.Must(t, MatchSegment("abc", "abc"))
+	util.Must(t, !MatchSegment("abc", "abd"))
+	util.Must(t, MatchSegment("*", "abc"))
+	util.Must(t, MatchSegment("api_*_latency", "api_get_latency"))
+	util.Must(t, MatchSegment("api_*_latency", "api__latency"))
+	util.Must(t, !MatchSegment("api_*_latency", "api_get_count"))
+	util.Must(t, MatchSegment("*_latency", "get_latency"))
+	util.Must(t, MatchSegment("a**b", "axxb"))
+	util.Must(t, MatchSegment("{get,set}", "set"))
+	util.Must(t, !MatchSegment("{get,set}", "del"))
+	util.Must(t, MatchSegment("api_{get,set}_*", "api_get_latency"))
+	util.Must(t, MatchSegment("{api_*,web}", "api_get"))
+	util.Must(t, MatchSegment("x{,y}", "x"))
+	util.Must(t, !MatchSegment("{a,b", "a"))
+}
+
+func TestMatch(t *testing.T) {
+	util.Must(t, Match("a.b.c", "a.b.c"))
+	util.Must(t, Match("a.*.c", "a.b.c"))
+	util.Must(t, !Match("a.*.c", "a.b.b.c"))
+	util.Must(t, Match("a.**.c", "a.c"))
+	util.Must(t, Match("a.**.c", "a.b.c"))
+	util.Must(t, Match("a.**.c", "a.b.b.c"))
+	util.Must(t, !Match("a.**.c", "a.b.b.d"))
+	util.Must(t, Match("a.**", "a"))
+	util.Must(t, Match("a.**", "a.b.c"))
+	util.Must(t, Match("**.c", "a.b.c"))
+	util.Must(t, Match("**", "a.b.c"))
+	util.Must(t, Match("a.**.c.**.e", "a.b.c.d.d.e"))
+	util.Must(t, Match("svc.{api,web}.*.timer_*", "svc.web.foo.timer_p99"))
+	util.Must(t, !Match("svc.{api,web}.*.timer_*", "svc.db.foo.timer_p99"))
+}
+
+func TestValid(t *testing.T) {
+	util.Must(t, Valid("a.b.c"))
+	util.Must(t, Valid("a.**.c"))
+	util.Must(t, Valid("a.*_{get,set}.**"))
+	util.Must(t, !Valid("a.**.**.c"))
+	util.Must(t, !Valid("a**.c"))
+	util.Must(t, !Valid("a.{get,set.c"))
+	util.Must(t, !Valid("a.get}.c"))
+	util.Must(t, !Valid("a.{a,{b,c}}"))
+	util.Must(t, !Valid("a.{a.b}"))
+}
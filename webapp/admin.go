@@ -0,0 +1,44 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package webapp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/util/log"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Backups are taken one by one.
+var backupLock sync.Mutex
+
+// backupStorage writes a snapshot of the whole storage as a gzipped tarball.
+func backupStorage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	backupLock.Lock()
+	defer backupLock.Unlock()
+	dir, err := ioutil.TempDir("", "banshee-backup-")
+	if err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "storage")
+	if err := db.Snapshot(fileName); err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	name := fmt.Sprintf("banshee-%s.tar.gz", time.Now().Format("20060102150405"))
+	w.Header().Set("Content-Type", "application/x-gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	if err := storage.Pack(w, fileName); err != nil {
+		// Too late to response the error.
+		log.Errorf("backup: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package webapp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/eleme/banshee/util/log"
+)
+
+// Timeout for requests to other nodes in cluster.
+const clusterTimeout = 5 * time.Second
+
+// Query param to request a node only, without fanning out.
+const clusterLocalParam = "local"
+
+var clusterClient = &http.Client{Timeout: clusterTimeout}
+
+// errClusterNotFound is returned if the node responds not found.
+var errClusterNotFound = errors.New("not found")
+
+// fanout returns true if the request should be fanned out to other nodes in
+// cluster, requests from other nodes are never fanned out again.
+func fanout(r *http.Request) bool {
+	return currentConfig().IsCluster() && r.URL.Query().Get(clusterLocalParam) == ""
+}
+
+// fanoutGet requests the same path and query on other nodes in parallel, and
+// returns the response bodies. Nodes failed are logged and skipped, partial
+// results are better than nothing for queries.
+func fanoutGet(r *http.Request) [][]byte {
+	bodies, errs := fanoutRequest(r)
+	for name, err := range errs {
+		log.Errorf("cluster node %s: %v, skipping..", name, err)
+	}
+	return bodies
+}
+
+// fanoutDelete requests the same delete on other nodes in parallel with the
+// same authorization, and returns the response bodies of nodes found the
+// deletion target. Unlike queries, an error is returned if any node failed,
+// deletes can be retried.
+func fanoutDelete(r *http.Request) ([][]byte, error) {
+	bodies, errs := fanoutRequest(r)
+	for name, err := range errs {
+		if err != errClusterNotFound {
+			return nil, fmt.Errorf("cluster node %s: %v", name, err)
+		}
+	}
+	return bodies, nil
+}
+
+// fanoutRequest requests the same method, path and query on other nodes in
+// parallel, and returns the response bodies and the errors by node name.
+func fanoutRequest(r *http.Request) ([][]byte, map[string]error) {
+	query := r.URL.Query()
+	query.Set(clusterLocalParam, "1")
+	var (
+		lock   sync.Mutex
+		wg     sync.WaitGroup
+		bodies [][]byte
+		errs   = make(map[string]error)
+	)
+	c := currentConfig()
+	for _, node := range c.Cluster.Nodes {
+		if node.Name == c.Cluster.Node {
+			continue
+		}
+		u := url.URL{Scheme: "http", Host: node.Webapp, Path: r.URL.Path, RawQuery: query.Encode()}
+		wg.Add(1)
+		go func(name, u string) {
+			defer wg.Done()
+			b, err := clusterDo(r, u)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs[name] = err
+				return
+			}
+			bodies = append(bodies, b)
+		}(node.Name, u.String())
+	}
+	wg.Wait()
+	return bodies, errs
+}
+
+// clusterDo requests the url with the method and authorization of the
+// request, and returns the body of an ok response.
+func clusterDo(r *http.Request, u string) ([]byte, error) {
+	req, err := http.NewRequest(r.Method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := clusterClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errClusterNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", r.Method, u, resp.Status)
+	}
+	return b, nil
+}
@@ -3,16 +3,68 @@
 package webapp
 
 import (
+	"github.com/eleme/banshee/config"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
 )
 
-// getConfig returns config.
-func getConfig(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	c := cfg.Copy()
+// currentConfig returns the current config.
+func currentConfig() *config.Config {
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+	return cfg
+}
+
+// SetConfig replaces the config on reloading, only the reloadable fields
+// take effect, see config.CopyReloadable.
+func SetConfig(c *config.Config) {
+	cfgLock.Lock()
+	defer cfgLock.Unlock()
+	cfg = c
+}
+
+// SetConfigReloader sets the function to reload config, which returns the
+// new config applied.
+func SetConfigReloader(fn func() (*config.Config, error)) {
+	configReloader = fn
+}
+
+// maskConfig returns a copy of config with secrets masked.
+func maskConfig(c *config.Config) *config.Config {
+	c = c.Copy()
 	c.Webapp.Auth[0] = "******"
 	c.Webapp.Auth[1] = "******"
-	ResponseJSONOK(w, c)
+	c.Alerter.Email.Password = "******"
+	// Webhook urls may carry tokens.
+	for i := range c.Alerter.Webhooks {
+		c.Alerter.Webhooks[i] = "******"
+	}
+	for i := range c.Alerter.SlackWebhooks {
+		c.Alerter.SlackWebhooks[i] = "******"
+	}
+	if len(c.Storage.Admin.DSN) > 0 {
+		c.Storage.Admin.DSN = "******"
+	}
+	return c
+}
+
+// getConfig returns config.
+func getConfig(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ResponseJSONOK(w, maskConfig(currentConfig()))
+}
+
+// reloadConfig reloads config from file and returns the new config.
+func reloadConfig(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if configReloader == nil {
+		ResponseError(w, ErrConfigReload)
+		return
+	}
+	c, err := configReloader()
+	if err != nil {
+		ResponseError(w, NewValidationWebError(err))
+		return
+	}
+	ResponseJSONOK(w, maskConfig(c))
 }
 
 // getInterval returns config.interval.
@@ -21,7 +73,7 @@ type intervalResponse struct {
 }
 
 func getInterval(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ResponseJSONOK(w, &intervalResponse{cfg.Interval})
+	ResponseJSONOK(w, &intervalResponse{currentConfig().Interval})
 }
 
 // getLanguage returns config.webapp.language.
@@ -30,7 +82,7 @@ type languageResponse struct {
 }
 
 func getLanguage(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ResponseJSONOK(w, &languageResponse{cfg.Webapp.Language})
+	ResponseJSONOK(w, &languageResponse{currentConfig().Webapp.Language})
 }
 
 // getPrivateDocURL returns config.webapp.privateDocUrl.
@@ -39,5 +91,5 @@ type privateDocURLResponse struct {
 }
 
 func getPrivateDocURL(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ResponseJSONOK(w, &privateDocURLResponse{cfg.Webapp.PrivateDocURL})
+	ResponseJSONOK(w, &privateDocURLResponse{currentConfig().Webapp.PrivateDocURL})
 }
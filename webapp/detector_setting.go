@@ -0,0 +1,135 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package webapp
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/eleme/banshee/models"
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+)
+
+// getDetectorSettings returns detector settings in db, optionally by kind.
+func getDetectorSettings(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	q := db.Admin.DB()
+	if kind := r.URL.Query().Get("kind"); len(kind) > 0 {
+		q = q.Where("kind = ?", kind)
+	}
+	settings := make([]models.DetectorSetting, 0)
+	if err := q.Find(&settings).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	ResponseJSONOK(w, settings)
+}
+
+// detectorSetting request
+type detectorSettingRequest struct {
+	Kind    string  `json:"kind"`
+	Pattern string  `json:"pattern"`
+	Value   float64 `json:"value"`
+	Comment string  `json:"comment"`
+}
+
+// bindDetectorSetting binds the request into the setting and validates it.
+func bindDetectorSetting(r *http.Request, s *models.DetectorSetting) *WebError {
+	req := &detectorSettingRequest{}
+	if err := RequestBind(r, req); err != nil {
+		return ErrBadRequest
+	}
+	s.Kind = req.Kind
+	s.Pattern = req.Pattern
+	s.Value = req.Value
+	s.Comment = req.Comment
+	if err := models.ValidateDetectorSetting(s); err != nil {
+		return NewValidationWebError(err)
+	}
+	return nil
+}
+
+// loadDetectorSettings loads the changed settings into the filter.
+func loadDetectorSettings(w http.ResponseWriter, s *models.DetectorSetting) {
+	if err := flt.ReloadSettings(db); err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	ResponseJSONOK(w, s)
+}
+
+// createDetectorSetting creates a detector setting.
+func createDetectorSetting(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Request
+	s := &models.DetectorSetting{}
+	if err := bindDetectorSetting(r, s); err != nil {
+		ResponseError(w, err)
+		return
+	}
+	// Create
+	if err := db.Admin.DB().Create(s).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	loadDetectorSettings(w, s)
+}
+
+// updateDetectorSetting updates a detector setting.
+func updateDetectorSetting(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Params
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		ResponseError(w, ErrDetectorSettingID)
+		return
+	}
+	// Find
+	s := &models.DetectorSetting{}
+	if err := db.Admin.DB().First(s, id).Error; err != nil {
+		switch err {
+		case gorm.RecordNotFound:
+			ResponseError(w, ErrDetectorSettingNotFound)
+			return
+		default:
+			ResponseError(w, NewUnexceptedWebError(err))
+			return
+		}
+	}
+	// Patch
+	if err := bindDetectorSetting(r, s); err != nil {
+		ResponseError(w, err)
+		return
+	}
+	if err := db.Admin.DB().Save(s).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	loadDetectorSettings(w, s)
+}
+
+// deleteDetectorSetting deletes a detector setting.
+func deleteDetectorSetting(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Params
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		ResponseError(w, ErrDetectorSettingID)
+		return
+	}
+	// Find
+	s := &models.DetectorSetting{}
+	if err := db.Admin.DB().First(s, id).Error; err != nil {
+		switch err {
+		case gorm.RecordNotFound:
+			ResponseError(w, ErrDetectorSettingNotFound)
+			return
+		default:
+			ResponseError(w, NewUnexceptedWebError(err))
+			return
+		}
+	}
+	// Delete
+	if err := db.Admin.DB().Delete(s).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	loadDetectorSettings(w, s)
+}
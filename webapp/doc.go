@@ -201,9 +201,18 @@ Baisc auth required.
 		"trendDown": false,
 		"thresholdMax": 0,
 		"thresholdMin": 0,
+		"algorithm": "mad",
+		"tagSelectors": "host=web*;region!={us,eu}",
 		"repr": "trend ↑"
 	}
 
+The algorithm is optional, one of "3sigma", "mad" and "percentile", empty
+for the configured default.
+
+The tagSelectors is optional, selectors of tagged metrics delimited by ";",
+either "key=pattern" or "key!=pattern", empty to select all metrics. The
+pattern matches the metric name without tags.
+
 	200
 	{
 		"id": 1,
@@ -225,9 +234,13 @@ Baisc auth required.
 	Or
 	GET /api/metric/indexes?limit=<number>&sort=<up|down>&project=1
 
+The optional tags are tag selectors, e.g. tags=host=web*;region=us (url
+encoded).
+
 	200
 	[
-		{"name": "timer.mean_90.foo", "score": 1.21},
+		{"name": "timer.mean_90.foo", "score": 1.21, "algorithm": "3sigma"},
+		{"name": "cpu.usage;host=web1", "tags": {"host": "web1"}, ...},
 		...
 	]
 
@@ -241,6 +254,20 @@ Baisc auth required.
 		...
 	]
 
+Metrics older than the expiration, or of ranges longer than the period, are
+from the rollup tiers, with the aggregation:
+
+	{
+		"name": "timer.count_ps.foo",
+		"stamp": ...,
+		"value": ...,
+		"rollup": {"interval": 60, "count": 6, "min": ..., "max": ...}
+	}
+
+In cluster mode, metric indexes and values are queried from all nodes and
+merged, nodes failed to respond are skipped. Add local=1 to query only the
+node requested.
+
 22. Get metric matched rules.
 
 	GET /api/metric/rules/<name>
@@ -263,7 +290,9 @@ Baisc auth required.
 		"detectionCost": 5.480208245735771,
 		"numMetricIncomed": 1148119,
 		"numMetricDetected": 35880,
-		"numAlertingEvents": 2
+		"numAlertingEvents": 2,
+		"numDroppedLines": 0,
+		"numDroppedEvents": 0
 	}
 
 24. Get banshee version.
@@ -293,5 +322,381 @@ Baisc auth required.
 		"language": "zh"
 	}
 
+27. Post metrics.
+
+Basic auth required.
+
+	POST /api/metrics -d
+	[
+		{"name": "timer.count_ps.foo", "stamp": 1452674178, "value": 3.4},
+		...
+	]
+
+Or in newline delimited JSON (NDJSON):
+
+	{"name": "timer.count_ps.foo", "stamp": 1452674178, "value": 3.4}
+	{"name": "timer.count_ps.bar", "value": 1.2}
+	{"name": "cpu.usage", "tags": {"host": "web1"}, "value": 0.8}
+
+The stamp is optional, default to the time now. The tags are optional, and
+can also be in the name as "cpu.usage;host=web1". Metrics are validated and
+queued to detect the same way as the detector tcp protocol, the request waits
+if the queues are full, at most 10240 metrics in a request. In cluster mode,
+metrics owned by other nodes are forwarded to the owners.
+
+	200
+	{
+		"accepted": 1,
+		"errors": [{"index": 1, "msg": "metric name is empty"}]
+	}
+
+28. Get health info in prometheus text format.
+
+	GET /metrics
+
+	200
+	# HELP banshee_clients Number of detector clients.
+	# TYPE banshee_clients gauge
+	banshee_clients 40
+	...
+
+29. Get alerting events history.
+
+	GET /api/events?project=1&rule=2&pattern=timer.*&start=1452674100&stop=1452674200&limit=100
+
+All parameters are optional: project and rule ids, wildcard pattern of metric
+names, stamp range [start, stop) and the max number of events (default 100,
+at most 1000). Events are ordered by stamp, latest first, and kept for the
+same expiration as metrics.
+
+	200
+	[
+		{
+			"id": 1,
+			"eventID": "4d0d5b6bd4b9fc0ba0c3ab2ea3e0a61bd0a1a5d6",
+			"projectID": 1,
+			"ruleID": 2,
+			"rulePattern": "timer.count_ps.*",
+			"name": "timer.count_ps.foo",
+			"stamp": 1452674178,
+			"value": 3.4,
+			"average": 1.2,
+			"score": 1.3,
+			"algorithm": "3sigma",
+			"comment": "foo count",
+			"receivers": "jack,tom",
+			"silenced": false,
+			"rateLimited": false,
+			"snoozed": false,
+			"inMaintenance": false,
+			"resolved": false
+		},
+		...
+	]
+
+30. Get active snoozes and acknowledgements.
+
+	GET /api/snoozes
+
+	200
+	[
+		{
+			"id": 1,
+			"metric": "timer.count_ps.foo",
+			"ruleID": 2,
+			"until": 1452677778,
+			"ack": false,
+			"comment": "deploying",
+			"createdAt": "2016-01-13T16:36:18+08:00"
+		},
+		...
+	]
+
+31. Snooze a metric on a rule for a duration in seconds (at most 30 days).
+
+Basic auth required. ruleID 0 is for all rules.
+
+	POST /api/snooze -d
+	{
+		"metric": "timer.count_ps.foo",
+		"ruleID": 2,
+		"duration": 3600,
+		"comment": "deploying"
+	}
+
+	200
+	{
+		"id": 1,
+		"metric": "timer.count_ps.foo",
+		"ruleID": 2,
+		"until": 1452677778,
+		"ack": false,
+		...
+	}
+
+32. Acknowledge an active alert of a metric on a rule.
+
+Basic auth required. Notifications are stopped until the alert is resolved,
+but at most a period.
+
+	POST /api/ack -d
+	{
+		"metric": "timer.count_ps.foo",
+		"ruleID": 2,
+		"comment": "working on it"
+	}
+
+	200
+	{
+		"id": 2,
+		"metric": "timer.count_ps.foo",
+		"ruleID": 2,
+		"until": 1452760578,
+		"ack": true,
+		...
+	}
+
+33. Delete a snooze or an acknowledgement.
+
+Basic auth required.
+
+	DELETE /api/snooze/:id
+
+	200
+
+34. Get maintenance windows, optionally by project or rule.
+
+	GET /api/maintenances?project=1&rule=2
+
+	200
+	[
+		{
+			"id": 1,
+			"projectID": 1,
+			"ruleID": 0,
+			"start": 1452674178,
+			"end": 1452677778,
+			"weekdays": "",
+			"timeStart": "",
+			"timeEnd": "",
+			"timezone": "",
+			"comment": "deploy"
+		},
+		...
+	]
+
+35. Create a maintenance window for a project or a rule.
+
+Basic auth required. Alerts are suppressed but still recorded during the
+window. Either projectID or ruleID should be set. An absolute window is from
+start to end (unix stamps), a recurring window is from timeStart to timeEnd
+("15:04", may cross the midnight) on the weekdays (0 for Sunday) in the IANA
+timezone (default UTC), optionally bounded by start and end.
+
+	POST /api/maintenance -d
+	{
+		"ruleID": 2,
+		"weekdays": "2,4",
+		"timeStart": "22:00",
+		"timeEnd": "02:00",
+		"timezone": "Asia/Shanghai",
+		"comment": "weekly release"
+	}
+
+	200
+	{
+		"id": 2,
+		"projectID": 0,
+		"ruleID": 2,
+		...
+	}
+
+36. Delete a maintenance window.
+
+Basic auth required.
+
+	DELETE /api/maintenance/:id
+
+	200
+
+37. Backup the whole storage.
+
+Basic auth required. A consistent snapshot of admin, index, metric and event
+dbs is taken while running, and responded as a gzipped tarball, which can be
+restored by command "banshee restore".
+
+	POST /api/admin/backup
+
+	200
+	Content-Type: application/x-gzip
+
+38. Delete a metric.
+
+Basic auth required. Both the index and the data of the metric are deleted.
+In cluster mode, the deletion is also requested on all other nodes with the
+same basic auth, add local=1 to delete only on the node requested.
+
+	DELETE /api/metric/:name
+
+	200
+
+39. Delete metrics by pattern.
+
+Basic auth required. In cluster mode, the deletion is also requested on all
+other nodes the same as 38, the deleted number is the sum.
+
+	DELETE /api/metrics?pattern=foo.*
+
+	200
+	{"deleted": 12}
+
+40. Import history metrics.
+
+Basic auth required. Metrics are saved and scored the same way as detected,
+but rules are not tested and no events are sent to the alerter, this seeds
+new deployments with the history data. Metrics of the same name should be in
+time order, and metrics older than expiration are refused. In cluster mode,
+metrics owned by other nodes are refused as well, import them on the owners.
+
+	POST /api/metrics/import?format=<line|csv> -d
+	timer.count_ps.foo 1452674178 3.4
+	timer.count_ps.foo 1452674188 3.2
+
+The format is optional, default to "line", the detector's tcp protocol. For
+"csv", columns are "name,stamp,value" with an optional header line. Also can
+be imported by command "banshee import". The body is limited to 64MB, split
+larger imports into multiple requests, metrics before the limit are imported.
+
+	200
+	{
+		"imported": 1,
+		"failed": 1,
+		"errors": [{"line": 2, "msg": "metric stamp is too small"}]
+	}
+
+41. Replay a rule against the stored history.
+
+Basic auth required. Stored metrics matching the rule are scored again and
+tested with the rule as if they were coming in, nothing is saved and no
+events are sent to the alerter. Either an existing rule by ruleID or a rule
+to try, with alternate detector options optional:
+
+	POST /api/replay -d
+	{
+		"rule": {"pattern": "timer.count_ps.*", "trendUp": true, "thresholdMax": 100},
+		"start": 1452574178,
+		"stop": 1452674178,
+		"trendingFactor": 0.05,
+		"filterTimes": 3,
+		"limit": 100
+	}
+
+The start defaults to the expiration ago and the stop to now. At most limit
+events are returned, default 100, notify intervals of the alerter are not
+applied. At most 256 metrics and 552960 points (e.g. 64 metrics of a day by
+10s) can be replayed at once, narrow the range for more metrics. Events are
+counted by days in UTC. Also can be run by command "banshee replay".
+
+	200
+	{
+		"numMetrics": 2,
+		"numPoints": 17280,
+		"numEvents": 3,
+		"days": [{"day": "2016-01-13", "numEvents": 3}],
+		"events": [{"metric": {"name": "timer.count_ps.foo", ...}, ...}, ...]
+	}
+
+42. Get replication status.
+
+The primary address and whether it's connected are for a replica, and the
+replicas connected for a primary.
+
+	GET /api/replication
+
+	200
+	{
+		"role": "replica",
+		"primary": "10.0.0.1:2018",
+		"connected": true,
+		"replicas": []
+	}
+
+43. Promote a replica to primary.
+
+Basic auth required. The replica stops following the primary, and starts
+the detector and alerter. Changes except this one are rejected on a replica
+with 403, since its storage is from the primary.
+
+	POST /api/replication/promote
+
+	200
+	{"role": "primary", "connected": false, "replicas": []}
+
+44. Reload config.
+
+Basic auth required. The config file is reloaded and validated, only these
+fields take effect without restarting, the others are kept: the detector
+blacklist, interval_hit_limit, default thresholds and fill_blank_zeros, the
+alerter interval, one_day_limit and default_silent_time_range, and the webapp
+language. Same as sending SIGHUP to the process.
+
+	POST /api/config/reload
+
+	200
+	{"interval": 10, "period": 86400, ...}
+
+	400
+	{"code": 400, "msg": "alerter.interval should be greater than 0"}
+
+45. Get detector settings, optionally by kind.
+
+Settings in config are not included.
+
+	GET /api/detector/settings?kind=blacklist
+
+	200
+	[
+		{"id": 1, "kind": "blacklist", "pattern": "statsd.**", "value": 0, "comment": ""},
+		...
+	]
+
+46. Create a detector setting.
+
+Basic auth required. The kind is one of "blacklist",
+"default_threshold_max", "default_threshold_min" and "fill_blank_zeros",
+and the pattern is the same to rule patterns. The value is required for
+default thresholds. Settings take effect immediately in addition to the ones
+in config, and settings in db take precedence for default thresholds.
+
+	POST /api/detector/setting -d
+	{
+		"kind": "default_threshold_max",
+		"pattern": "counter.**",
+		"value": 10,
+		"comment": "low traffic counters"
+	}
+
+	200
+	{"id": 2, "kind": "default_threshold_max", "pattern": "counter.**", ...}
+
+47. Update a detector setting.
+
+Basic auth required. Same to creating.
+
+	PATCH /api/detector/setting/:id -d
+	{"kind": "blacklist", "pattern": "statsd.*.timer", "value": 0, "comment": ""}
+
+	200
+	{"id": 1, "kind": "blacklist", "pattern": "statsd.*.timer", ...}
+
+48. Delete a detector setting.
+
+Basic auth required.
+
+	DELETE /api/detector/setting/:id
+
+	200
+	{"id": 1, "kind": "blacklist", "pattern": "statsd.*.timer", ...}
+
 */
 package webapp
@@ -23,6 +23,8 @@ var (
 	ErrPrimaryKey = NewWebError(http.StatusForbidden, "Primarykey voilated")
 	ErrUnique     = NewWebError(http.StatusForbidden, "Value should be unique")
 	ErrNotFound   = NewWebError(http.StatusNotFound, "Not found")
+	// Config
+	ErrConfigReload = NewWebError(http.StatusBadRequest, "Config is not loaded from file")
 	// Project
 	ErrProjectID            = NewWebError(http.StatusBadRequest, "Bad project id")
 	ErrProjectNotFound      = NewWebError(http.StatusNotFound, "Project not found")
@@ -41,7 +43,27 @@ var (
 	ErrRuleCommentNotValid  = NewWebError(http.StatusBadRequest, "Rule comment is not valid, empty?")
 	ErrRuleUpdateFailed     = NewWebError(http.StatusBadRequest, "Failed to update rule")
 	// Metric
-	ErrMetricNotFound = NewWebError(http.StatusNotFound, "Metric not found")
+	ErrMetricNotFound  = NewWebError(http.StatusNotFound, "Metric not found")
+	ErrMetricsTooMany  = NewWebError(http.StatusRequestEntityTooLarge, "Too many metrics in a request")
+	ErrMetricValueNull = NewWebError(http.StatusBadRequest, "Metric value is null")
+	ErrMetricPattern   = NewWebError(http.StatusBadRequest, "Bad metric pattern")
+	// Import
+	ErrMetricsImportTooLarge = NewWebError(http.StatusRequestEntityTooLarge, "Too large metrics import, split it")
+	// Replay
+	ErrReplayTooManyMetrics = NewWebError(http.StatusRequestEntityTooLarge, "Too many metrics to replay")
+	ErrReplayTooManyPoints  = NewWebError(http.StatusRequestEntityTooLarge, "Too many points to replay, narrow the range")
+	// Replication
+	ErrNotReplica      = NewWebError(http.StatusBadRequest, "Not a replica")
+	ErrReplicaReadOnly = NewWebError(http.StatusForbidden, "Replica is read only")
+	// Snooze
+	ErrSnoozeID       = NewWebError(http.StatusBadRequest, "Bad snooze id")
+	ErrSnoozeNotFound = NewWebError(http.StatusNotFound, "Snooze not found")
+	// Maintenance
+	ErrMaintenanceID       = NewWebError(http.StatusBadRequest, "Bad maintenance id")
+	ErrMaintenanceNotFound = NewWebError(http.StatusNotFound, "Maintenance not found")
+	// Detector setting
+	ErrDetectorSettingID       = NewWebError(http.StatusBadRequest, "Bad detector setting id")
+	ErrDetectorSettingNotFound = NewWebError(http.StatusNotFound, "Detector setting not found")
 )
 
 // NewWebError creates a WebError.
@@ -0,0 +1,64 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package webapp
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/eleme/banshee/storage/eventdb"
+	"github.com/julienschmidt/httprouter"
+)
+
+// getEvents returns alerting events history.
+func getEvents(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	q := r.URL.Query()
+	opts := &eventdb.QueryOptions{Pattern: q.Get("pattern")}
+	// Options
+	var err error
+	if s := q.Get("project"); len(s) > 0 {
+		if opts.ProjectID, err = strconv.Atoi(s); err != nil {
+			ResponseError(w, ErrProjectID)
+			return
+		}
+	}
+	if s := q.Get("rule"); len(s) > 0 {
+		if opts.RuleID, err = strconv.Atoi(s); err != nil {
+			ResponseError(w, ErrRuleID)
+			return
+		}
+	}
+	if s := q.Get("start"); len(s) > 0 {
+		start, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			ResponseError(w, ErrBadRequest)
+			return
+		}
+		opts.Start = uint32(start)
+	}
+	if s := q.Get("stop"); len(s) > 0 {
+		stop, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			ResponseError(w, ErrBadRequest)
+			return
+		}
+		opts.Stop = uint32(stop)
+	}
+	if s := q.Get("limit"); len(s) > 0 {
+		if opts.Limit, err = strconv.Atoi(s); err != nil {
+			ResponseError(w, ErrBadRequest)
+			return
+		}
+	}
+	// Query
+	records, err := db.Event.Query(opts)
+	if err == eventdb.ErrBadPattern {
+		ResponseError(w, ErrMetricPattern)
+		return
+	}
+	if err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	ResponseJSONOK(w, records)
+}
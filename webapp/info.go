@@ -11,3 +11,9 @@ import (
 func getInfo(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	ResponseJSONOK(w, health.Get())
 }
+
+// getPrometheusMetrics returns health info in prometheus text format.
+func getPrometheusMetrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	health.WritePrometheus(w)
+}
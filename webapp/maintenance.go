@@ -0,0 +1,131 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package webapp
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/eleme/banshee/models"
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+)
+
+// getMaintenances returns maintenance windows, optionally by project or
+// rule.
+func getMaintenances(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	q := db.Admin.DB()
+	if s := r.URL.Query().Get("project"); len(s) > 0 {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			ResponseError(w, ErrProjectID)
+			return
+		}
+		q = q.Where("project_id = ?", id)
+	}
+	if s := r.URL.Query().Get("rule"); len(s) > 0 {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			ResponseError(w, ErrRuleID)
+			return
+		}
+		q = q.Where("rule_id = ?", id)
+	}
+	mts := make([]models.Maintenance, 0)
+	if err := q.Find(&mts).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	ResponseJSONOK(w, mts)
+}
+
+// createMaintenance request
+type createMaintenanceRequest struct {
+	ProjectID int    `json:"projectID"`
+	RuleID    int    `json:"ruleID"`
+	Start     uint32 `json:"start"`
+	End       uint32 `json:"end"`
+	Weekdays  string `json:"weekdays"`
+	TimeStart string `json:"timeStart"`
+	TimeEnd   string `json:"timeEnd"`
+	Timezone  string `json:"timezone"`
+	Comment   string `json:"comment"`
+}
+
+// createMaintenance creates a maintenance window for a project or a rule.
+func createMaintenance(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Request
+	req := &createMaintenanceRequest{}
+	if err := RequestBind(r, req); err != nil {
+		ResponseError(w, ErrBadRequest)
+		return
+	}
+	mt := &models.Maintenance{
+		ProjectID: req.ProjectID,
+		RuleID:    req.RuleID,
+		Start:     req.Start,
+		End:       req.End,
+		Weekdays:  req.Weekdays,
+		TimeStart: req.TimeStart,
+		TimeEnd:   req.TimeEnd,
+		Timezone:  req.Timezone,
+		Comment:   req.Comment,
+	}
+	// Validate
+	if err := models.ValidateMaintenance(mt); err != nil {
+		ResponseError(w, NewValidationWebError(err))
+		return
+	}
+	// Find project or rule.
+	var err error
+	if mt.ProjectID > 0 {
+		err = db.Admin.DB().First(&models.Project{}, mt.ProjectID).Error
+		if err == gorm.RecordNotFound {
+			ResponseError(w, ErrProjectNotFound)
+			return
+		}
+	} else {
+		err = db.Admin.DB().First(&models.Rule{}, mt.RuleID).Error
+		if err == gorm.RecordNotFound {
+			ResponseError(w, ErrRuleNotFound)
+			return
+		}
+	}
+	if err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	// Create
+	if err := db.Admin.DB().Create(mt).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	ResponseJSONOK(w, mt)
+}
+
+// deleteMaintenance deletes a maintenance window.
+func deleteMaintenance(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Params
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		ResponseError(w, ErrMaintenanceID)
+		return
+	}
+	// Find
+	mt := &models.Maintenance{}
+	if err := db.Admin.DB().First(mt, id).Error; err != nil {
+		switch err {
+		case gorm.RecordNotFound:
+			ResponseError(w, ErrMaintenanceNotFound)
+			return
+		default:
+			ResponseError(w, NewUnexceptedWebError(err))
+			return
+		}
+	}
+	// Delete
+	if err := db.Admin.DB().Delete(mt).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+}
@@ -3,16 +3,35 @@
 package webapp
 
 import (
-	"github.com/eleme/banshee/models"
-	"github.com/eleme/banshee/storage/indexdb"
-	"github.com/julienschmidt/httprouter"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
 	"math"
 	"net/http"
 	"sort"
 	"strconv"
 	"time"
+
+	"github.com/eleme/banshee/detector"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage/indexdb"
+	"github.com/eleme/banshee/util/log"
+	"github.com/julienschmidt/httprouter"
 )
 
+// Max number of metrics in a single postMetrics request.
+const maxPostMetricsNum = 10 * 1024
+
+// Max body size of a postMetrics request, 1KB for each metric.
+const maxPostMetricsBytes = maxPostMetricsNum * 1024
+
+// Max number of errors returned by importMetrics.
+const maxImportMetricsErrors = 100
+
+// Max body size of an importMetrics request.
+const maxImportMetricsBytes = 64 * 1024 * 1024
+
 type indexByScore []*models.Index
 
 func (l indexByScore) Len() int { return len(l) }
@@ -26,6 +45,25 @@ func (l indexByScore) Less(i, j int) bool {
 		l[j].Score/math.Pow(float64(uint32(2+now)-l[j].Stamp), 1.5)
 }
 
+// uniqueIndexesByName removes duplicate indexes by name and keeps the latest
+// ones, a metric may be on multiple nodes in cluster after nodes changed.
+func uniqueIndexesByName(idxs []*models.Index) []*models.Index {
+	m := make(map[string]int, len(idxs))
+	var l []*models.Index
+	for _, idx := range idxs {
+		i, ok := m[idx.Name]
+		if !ok {
+			m[idx.Name] = len(l)
+			l = append(l, idx)
+			continue
+		}
+		if idx.Stamp > l[i].Stamp {
+			l[i] = idx
+		}
+	}
+	return l
+}
+
 // getMetricIndexes returns metric names.
 func getMetricIndexes(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	// Options
@@ -42,6 +80,11 @@ func getMetricIndexes(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 		projID = 0
 	}
 	pattern := r.URL.Query().Get("pattern")
+	selectors, err := models.ParseTagSelectors(r.URL.Query().Get("tags"))
+	if err != nil {
+		ResponseError(w, NewValidationWebError(err))
+		return
+	}
 	// Index
 	var idxs []*models.Index
 	if pattern == "" {
@@ -58,13 +101,35 @@ func getMetricIndexes(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 			// Filter
 			for i := 0; i < len(rules); i++ {
 				rule := &rules[i]
-				idxs = append(idxs, db.Index.Filter(rule.Pattern)...)
+				idxs = append(idxs, filterRuleIndexes(rule)...)
 			}
 		} else {
 			// Filter
 			idxs = db.Index.Filter(pattern)
 		}
 	}
+	// Tags
+	if len(selectors) > 0 {
+		var l []*models.Index
+		for _, idx := range idxs {
+			if models.MatchTagSelectors(selectors, idx.Tags) {
+				l = append(l, idx)
+			}
+		}
+		idxs = l
+	}
+	// Cluster
+	if fanout(r) {
+		for _, b := range fanoutGet(r) {
+			var l []*models.Index
+			if err := json.Unmarshal(b, &l); err != nil {
+				log.Errorf("cluster metric indexes: %v, skipping..", err)
+				continue
+			}
+			idxs = append(idxs, l...)
+		}
+		idxs = uniqueIndexesByName(idxs)
+	}
 	// Sort
 	sort.Sort(indexByScore(idxs))
 	if order == "up" {
@@ -83,7 +148,7 @@ func getMetricIndexes(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 	}
 	// Matched rules
 	for _, idx := range idxs {
-		m := &models.Metric{Name: idx.Name}
+		m := &models.Metric{Name: idx.Name, Tags: idx.Tags}
 		idx.MatchedRules = flt.MatchedRules(m)
 	}
 	ResponseJSONOK(w, idxs)
@@ -110,19 +175,29 @@ func getMetrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	var metrics []*models.Metric
 	// Get index.
 	idx, err := db.Index.Get(name)
-	if err != nil {
-		if err == indexdb.ErrNotFound {
-			ResponseJSONOK(w, metrics)
-			return
-		}
+	if err != nil && err != indexdb.ErrNotFound {
 		ResponseError(w, NewUnexceptedWebError(err))
 		return
 	}
 	// Query
-	metrics, err = db.Metric.Get(name, idx.Link, uint32(start), uint32(stop))
-	if err != nil {
-		ResponseError(w, NewUnexceptedWebError(err))
-		return
+	if err == nil {
+		metrics, err = db.Metric.Get(name, idx.Link, uint32(start), uint32(stop))
+		if err != nil {
+			ResponseError(w, NewUnexceptedWebError(err))
+			return
+		}
+	}
+	// Cluster
+	if fanout(r) {
+		for _, b := range fanoutGet(r) {
+			var l []*models.Metric
+			if err := json.Unmarshal(b, &l); err != nil {
+				log.Errorf("cluster metrics: %v, skipping..", err)
+				continue
+			}
+			metrics = append(metrics, l...)
+		}
+		metrics = uniqueMetricsByStamp(metrics)
 	}
 	// http://danott.co/posts/json-marshalling-empty-slices-to-empty-arrays-in-go.html
 	if len(metrics) == 0 {
@@ -131,6 +206,27 @@ func getMetrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	ResponseJSONOK(w, metrics)
 }
 
+// metricByStamp implements sort.Interface.
+type metricByStamp []*models.Metric
+
+func (l metricByStamp) Len() int           { return len(l) }
+func (l metricByStamp) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l metricByStamp) Less(i, j int) bool { return l[i].Stamp < l[j].Stamp }
+
+// uniqueMetricsByStamp sorts metrics by stamp and removes duplicates, a metric
+// may be on multiple nodes in cluster after nodes changed.
+func uniqueMetricsByStamp(metrics []*models.Metric) []*models.Metric {
+	sort.Stable(metricByStamp(metrics))
+	var l []*models.Metric
+	for _, m := range metrics {
+		if len(l) > 0 && l[len(l)-1].Stamp == m.Stamp {
+			continue
+		}
+		l = append(l, m)
+	}
+	return l
+}
+
 // getMetricRules returns the rules matching the given metric.
 func getMetricRules(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	// Params
@@ -141,6 +237,246 @@ func getMetricRules(w http.ResponseWriter, r *http.Request, ps httprouter.Params
 	}
 	// Find matched rules
 	m := &models.Metric{Name: name}
+	if err := m.NormalizeTags(); err != nil {
+		ResponseError(w, NewValidationWebError(err))
+		return
+	}
 	rules := flt.MatchedRules(m)
 	ResponseJSONOK(w, rules)
 }
+
+// postMetricsItem is an item of postMetrics request.
+type postMetricsItem struct {
+	Name  string            `json:"name"`
+	Tags  map[string]string `json:"tags"`
+	Stamp uint32            `json:"stamp"`
+	Value *float64          `json:"value"`
+}
+
+// postMetricsError is an error of an invalid item in postMetrics request.
+type postMetricsError struct {
+	Index int    `json:"index"`
+	Msg   string `json:"msg"`
+}
+
+// postMetricsResponse is the response of postMetrics.
+type postMetricsResponse struct {
+	Accepted int                `json:"accepted"`
+	Errors   []postMetricsError `json:"errors"`
+}
+
+// decodePostMetrics decodes request body into metric items, the body can be
+// either a JSON array or newline delimited JSON objects (NDJSON). Decoding
+// stops once there are more than maxPostMetricsNum items.
+func decodePostMetrics(r io.Reader) ([]*postMetricsItem, error) {
+	br := bufio.NewReader(r)
+	// Peek the first non-space byte.
+	var c byte
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		c = b[0]
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			break
+		}
+		br.ReadByte()
+	}
+	var items []*postMetricsItem
+	dec := json.NewDecoder(br)
+	if c == '[' {
+		// JSON array, decoded item by item.
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		for dec.More() {
+			var item *postMetricsItem
+			if err := dec.Decode(&item); err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if len(items) > maxPostMetricsNum {
+				return items, nil
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+	// NDJSON
+	for {
+		item := &postMetricsItem{}
+		err := dec.Decode(item)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if len(items) > maxPostMetricsNum {
+			break
+		}
+	}
+	return items, nil
+}
+
+// postMetrics accepts a batch of metrics and feeds them to detector.
+func postMetrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Request
+	items, err := decodePostMetrics(http.MaxBytesReader(w, r.Body, maxPostMetricsBytes))
+	if err != nil || len(items) == 0 {
+		ResponseError(w, ErrBadRequest)
+		return
+	}
+	if len(items) > maxPostMetricsNum {
+		ResponseError(w, ErrMetricsTooMany)
+		return
+	}
+	// Feed
+	now := uint32(time.Now().Unix())
+	resp := &postMetricsResponse{Errors: make([]postMetricsError, 0)}
+	for i, item := range items {
+		if item == nil || item.Value == nil {
+			resp.Errors = append(resp.Errors, postMetricsError{i, ErrMetricValueNull.Msg})
+			continue
+		}
+		m := &models.Metric{Name: item.Name, Tags: item.Tags, Stamp: item.Stamp, Value: *item.Value}
+		if m.Stamp == 0 {
+			// Default to now.
+			m.Stamp = now
+		}
+		if err := det.Feed(m); err != nil {
+			resp.Errors = append(resp.Errors, postMetricsError{i, err.Error()})
+			continue
+		}
+		resp.Accepted++
+	}
+	ResponseJSONOK(w, resp)
+}
+
+// importMetricsError is an error of an invalid line in importMetrics request.
+type importMetricsError struct {
+	Line int    `json:"line"`
+	Msg  string `json:"msg"`
+}
+
+// importMetricsResponse is the response of importMetrics, at most
+// maxImportMetricsErrors errors are returned.
+type importMetricsResponse struct {
+	Imported int                  `json:"imported"`
+	Failed   int                  `json:"failed"`
+	Errors   []importMetricsError `json:"errors"`
+}
+
+// importMetrics imports history metrics into storage without alerting.
+func importMetrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Options
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = detector.ImportFormatLine
+	}
+	body := http.MaxBytesReader(w, r.Body, maxImportMetricsBytes)
+	imp, err := detector.NewImporter(body, format)
+	if err != nil {
+		ResponseError(w, NewValidationWebError(err))
+		return
+	}
+	// Import
+	resp := &importMetricsResponse{Errors: make([]importMetricsError, 0)}
+	for imp.Scan() {
+		m, err := imp.Metric()
+		if err == nil {
+			err = det.Import(m)
+		}
+		if err != nil {
+			resp.Failed++
+			if len(resp.Errors) < maxImportMetricsErrors {
+				resp.Errors = append(resp.Errors, importMetricsError{imp.Line(), err.Error()})
+			}
+			continue
+		}
+		resp.Imported++
+	}
+	if err := imp.Err(); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ResponseError(w, ErrMetricsImportTooLarge)
+			return
+		}
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	ResponseJSONOK(w, resp)
+}
+
+// deleteMetric deletes a metric by name, both the index and the data.
+func deleteMetric(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Params
+	name := ps.ByName("name")
+	if name == "" {
+		ResponseError(w, ErrBadRequest)
+		return
+	}
+	// Delete
+	found := true
+	if err := det.DeleteMetric(name); err != nil {
+		if err != indexdb.ErrNotFound {
+			ResponseError(w, NewUnexceptedWebError(err))
+			return
+		}
+		found = false
+	}
+	// Cluster
+	if fanout(r) {
+		bodies, err := fanoutDelete(r)
+		if err != nil {
+			ResponseError(w, NewUnexceptedWebError(err))
+			return
+		}
+		found = found || len(bodies) > 0
+	}
+	if !found {
+		ResponseError(w, ErrMetricNotFound)
+	}
+}
+
+// deleteMetricsResponse is the response of deleteMetrics.
+type deleteMetricsResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// deleteMetrics deletes metrics matching a pattern.
+func deleteMetrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Options
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		ResponseError(w, ErrMetricPattern)
+		return
+	}
+	// Delete
+	n, err := det.DeleteMetrics(pattern)
+	if err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	// Cluster
+	if fanout(r) {
+		bodies, err := fanoutDelete(r)
+		if err != nil {
+			ResponseError(w, NewUnexceptedWebError(err))
+			return
+		}
+		for _, b := range bodies {
+			resp := &deleteMetricsResponse{}
+			if err := json.Unmarshal(b, resp); err != nil {
+				ResponseError(w, NewUnexceptedWebError(err))
+				return
+			}
+			n += resp.Deleted
+		}
+	}
+	ResponseJSONOK(w, &deleteMetricsResponse{n})
+}
@@ -0,0 +1,72 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eleme/banshee/util"
+)
+
+func TestDecodePostMetrics(t *testing.T) {
+	cases := []struct {
+		body  string
+		n     int
+		err   bool
+		nulls int // items or values null
+	}{
+		// JSON array
+		{`[{"name": "foo", "stamp": 1452674178, "value": 3.4}, {"name": "bar", "value": 1}]`, 2, false, 0},
+		{"  \n[]", 0, false, 0},
+		{`[{"name": "foo", "value": null}, null]`, 2, false, 2},
+		{`[{"name": "foo", "value": 1}`, 0, true, 0},
+		{`[{"name": "foo", "value": "a"}]`, 0, true, 0},
+		// NDJSON
+		{"{\"name\": \"foo\", \"value\": 3.4}\n{\"name\": \"bar\", \"tags\": {\"a\": \"b\"}, \"value\": 1}\n", 2, false, 0},
+		{`{"name": "foo"}`, 1, false, 1},
+		{"{\"name\": \"foo\", \"value\": 1}\n{\"name\":", 0, true, 0},
+		// Empty
+		{"", 0, true, 0},
+		{" \n ", 0, true, 0},
+	}
+	for _, c := range cases {
+		items, err := decodePostMetrics(strings.NewReader(c.body))
+		if c.err {
+			util.Must(t, err != nil)
+			continue
+		}
+		util.Must(t, err == nil && len(items) == c.n)
+		nulls := 0
+		for _, item := range items {
+			if item == nil || item.Value == nil {
+				nulls++
+			}
+		}
+		util.Must(t, nulls == c.nulls)
+	}
+}
+
+func TestDecodePostMetricsTooMany(t *testing.T) {
+	item := `{"name": "foo", "value": 1}`
+	items := make([]string, maxPostMetricsNum+10)
+	for i := range items {
+		items[i] = item
+	}
+	// Decoding stops once there are more than the max.
+	l, err := decodePostMetrics(strings.NewReader("[" + strings.Join(items, ",") + "]"))
+	util.Must(t, err == nil && len(l) == maxPostMetricsNum+1)
+	l, err = decodePostMetrics(strings.NewReader(strings.Join(items, "\n")))
+	util.Must(t, err == nil && len(l) == maxPostMetricsNum+1)
+}
+
+func TestDecodePostMetricsOversize(t *testing.T) {
+	// A single huge item.
+	body := `[{"name": "` + strings.Repeat("a", maxPostMetricsBytes) + `", "value": 1}]`
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/metrics", strings.NewReader(body))
+	_, err := decodePostMetrics(http.MaxBytesReader(w, r.Body, maxPostMetricsBytes))
+	util.Must(t, err != nil)
+}
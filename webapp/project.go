@@ -227,7 +227,7 @@ func getProjectRules(w http.ResponseWriter, r *http.Request, ps httprouter.Param
 		return
 	}
 	for i := 0; i < len(rules); i++ {
-		rules[i].SetNumMetrics(len(db.Index.Filter(rules[i].Pattern)))
+		rules[i].SetNumMetrics(len(filterRuleIndexes(&rules[i])))
 	}
 	ResponseJSONOK(w, rules)
 }
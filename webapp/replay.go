@@ -0,0 +1,89 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package webapp
+
+import (
+	"net/http"
+
+	"github.com/eleme/banshee/detector"
+	"github.com/eleme/banshee/models"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Default max number of events in replay response.
+const defaultReplayLimit = 100
+
+// replay request
+type replayRequest struct {
+	// Either an existing rule or a rule to try.
+	RuleID int                `json:"ruleID"`
+	Rule   *createRuleRequest `json:"rule"`
+	// Options
+	Start          uint32  `json:"start"`
+	Stop           uint32  `json:"stop"`
+	TrendingFactor float64 `json:"trendingFactor"`
+	FilterTimes    int     `json:"filterTimes"`
+	Limit          int     `json:"limit"`
+}
+
+// replay replays stored metrics with a rule and returns the would-be events.
+func replay(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Request
+	req := &replayRequest{Limit: defaultReplayLimit}
+	if err := RequestBind(r, req); err != nil {
+		ResponseError(w, ErrBadRequest)
+		return
+	}
+	// Rule
+	var rule *models.Rule
+	switch {
+	case req.RuleID > 0:
+		var ok bool
+		if rule, ok = db.Admin.RulesCache.Get(req.RuleID); !ok {
+			ResponseError(w, ErrRuleNotFound)
+			return
+		}
+	case req.Rule != nil:
+		if err := req.Rule.validate(); err != nil {
+			ResponseError(w, err)
+			return
+		}
+		rule = &models.Rule{
+			Pattern:      req.Rule.Pattern,
+			TrendUp:      req.Rule.TrendUp,
+			TrendDown:    req.Rule.TrendDown,
+			ThresholdMax: req.Rule.ThresholdMax,
+			ThresholdMin: req.Rule.ThresholdMin,
+			Comment:      req.Rule.Comment,
+			Level:        req.Rule.Level,
+			Algorithm:    req.Rule.Algorithm,
+			TagSelectors: req.Rule.TagSelectors,
+		}
+	default:
+		ResponseError(w, ErrBadRequest)
+		return
+	}
+	// Replay
+	opts := &detector.ReplayOptions{
+		Start:          req.Start,
+		Stop:           req.Stop,
+		TrendingFactor: req.TrendingFactor,
+		FilterTimes:    req.FilterTimes,
+		Limit:          req.Limit,
+	}
+	result, err := det.Replay(rule, opts)
+	if err != nil {
+		switch err {
+		case detector.ErrReplayRange, detector.ErrReplayTrendingFactor, detector.ErrReplayFilterTimes:
+			ResponseError(w, NewValidationWebError(err))
+		case detector.ErrReplayTooManyMetrics:
+			ResponseError(w, ErrReplayTooManyMetrics)
+		case detector.ErrReplayTooManyPoints:
+			ResponseError(w, ErrReplayTooManyPoints)
+		default:
+			ResponseError(w, NewUnexceptedWebError(err))
+		}
+		return
+	}
+	ResponseJSONOK(w, result)
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package webapp
+
+import (
+	"net/http"
+
+	"github.com/eleme/banshee/replication"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Apis allowed to post on a replica, which change nothing in storage or
+// promote it.
+var replicaPostPaths = map[string]bool{
+	"/api/replication/promote": true,
+	"/api/replay":              true,
+	"/api/admin/backup":        true,
+	"/api/config/reload":       true,
+}
+
+// replicaHandler rejects changes on a replica, the storage of a replica is
+// from the primary only.
+type replicaHandler struct {
+	h http.Handler
+}
+
+// newReplicaHandler creates a replicaHandler.
+func newReplicaHandler(h http.Handler) http.Handler {
+	return &replicaHandler{h}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *replicaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+	default:
+		if !rep.IsPrimary() && !replicaPostPaths[r.URL.Path] {
+			ResponseError(w, ErrReplicaReadOnly)
+			return
+		}
+	}
+	h.h.ServeHTTP(w, r)
+}
+
+// getReplication returns the replication status.
+func getReplication(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ResponseJSONOK(w, rep.Status())
+}
+
+// promoteReplication promotes the replica to primary.
+func promoteReplication(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := rep.Promote(); err != nil {
+		if err == replication.ErrNotReplica {
+			ResponseError(w, ErrNotReplica)
+			return
+		}
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	ResponseJSONOK(w, rep.Status())
+}
@@ -22,6 +22,28 @@ type createRuleRequest struct {
 	Comment      string  `json:"comment"`
 	Level        int     `json:"level"`
 	Disabled     bool    `json:"disabled"`
+	Algorithm    string  `json:"algorithm"`
+	TagSelectors string  `json:"tagSelectors"`
+}
+
+// validate the rule request, returns nil if ok.
+func (req *createRuleRequest) validate() *WebError {
+	if err := models.ValidateRulePattern(req.Pattern); err != nil {
+		return NewValidationWebError(err)
+	}
+	if !req.TrendUp && !req.TrendDown && req.ThresholdMax == 0 && req.ThresholdMin == 0 {
+		return ErrRuleNoCondition
+	}
+	if err := models.ValidateRuleLevel(req.Level); err != nil {
+		return NewValidationWebError(err)
+	}
+	if err := models.ValidateRuleAlgorithm(req.Algorithm); err != nil {
+		return NewValidationWebError(err)
+	}
+	if err := models.ValidateRuleTagSelectors(req.TagSelectors); err != nil {
+		return NewValidationWebError(err)
+	}
+	return nil
 }
 
 // createRule creates a rule.
@@ -42,21 +64,13 @@ func createRule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		return
 	}
 	// Validate
-	if err := models.ValidateRulePattern(req.Pattern); err != nil {
-		ResponseError(w, NewValidationWebError(err))
-		return
-	}
 	if projectID <= 0 {
 		// ProjectID is invalid.
 		ResponseError(w, ErrProjectID)
 		return
 	}
-	if !req.TrendUp && !req.TrendDown && req.ThresholdMax == 0 && req.ThresholdMin == 0 {
-		ResponseError(w, ErrRuleNoCondition)
-		return
-	}
-	if err := models.ValidateRuleLevel(req.Level); err != nil {
-		ResponseError(w, NewValidationWebError(err))
+	if err := req.validate(); err != nil {
+		ResponseError(w, err)
 		return
 	}
 	// Find project.
@@ -82,6 +96,8 @@ func createRule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		Comment:      req.Comment,
 		Level:        req.Level,
 		Disabled:     req.Disabled,
+		Algorithm:    req.Algorithm,
+		TagSelectors: req.TagSelectors,
 	}
 	if err := db.Admin.DB().Create(rule).Error; err != nil {
 		// Write errors.
@@ -106,7 +122,7 @@ func createRule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	// Cache
 	db.Admin.RulesCache.Put(rule)
 	// Response
-	rule.SetNumMetrics(len(db.Index.Filter(rule.Pattern)))
+	rule.SetNumMetrics(len(filterRuleIndexes(rule)))
 	ResponseJSONOK(w, rule)
 }
 
@@ -149,16 +165,8 @@ func editRule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		return
 	}
 	// Validate
-	if err := models.ValidateRulePattern(req.Pattern); err != nil {
-		ResponseError(w, NewValidationWebError(err))
-		return
-	}
-	if err := models.ValidateRuleLevel(req.Level); err != nil {
-		ResponseError(w, NewValidationWebError(err))
-		return
-	}
-	if !req.TrendUp && !req.TrendDown && req.ThresholdMax == 0 && req.ThresholdMin == 0 {
-		ResponseError(w, ErrRuleNoCondition)
+	if err := req.validate(); err != nil {
+		ResponseError(w, err)
 		return
 	}
 
@@ -176,6 +184,8 @@ func editRule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	rule.ThresholdMax = req.ThresholdMax
 	rule.ThresholdMin = req.ThresholdMin
 	rule.Disabled = req.Disabled
+	rule.Algorithm = req.Algorithm
+	rule.TagSelectors = req.TagSelectors
 
 	if db.Admin.DB().Save(rule).Error != nil {
 		ResponseError(w, ErrRuleUpdateFailed)
@@ -184,6 +194,18 @@ func editRule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	// Cache
 	db.Admin.RulesCache.Delete(id)
 	db.Admin.RulesCache.Put(rule)
-	rule.SetNumMetrics(len(db.Index.Filter(rule.Pattern)))
+	rule.SetNumMetrics(len(filterRuleIndexes(rule)))
 	ResponseJSONOK(w, rule)
 }
+
+// filterRuleIndexes returns the indexes of metrics matching the rule pattern
+// and tag selectors.
+func filterRuleIndexes(rule *models.Rule) []*models.Index {
+	var idxs []*models.Index
+	for _, idx := range db.Index.Filter(rule.Pattern) {
+		if rule.MatchTags(idx.Tags) {
+			idxs = append(idxs, idx)
+		}
+	}
+	return idxs
+}
@@ -5,9 +5,12 @@ package webapp
 import (
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/detector"
 	"github.com/eleme/banshee/filter"
+	"github.com/eleme/banshee/replication"
 	"github.com/eleme/banshee/storage"
 	"github.com/eleme/banshee/util/log"
 	"github.com/julienschmidt/httprouter"
@@ -15,32 +18,42 @@ import (
 
 // Globals
 var (
-	// Config
-	cfg *config.Config
+	// Config, replaced on reloading.
+	cfg     *config.Config
+	cfgLock sync.RWMutex
+	// Function to reload config.
+	configReloader func() (*config.Config, error)
 	// Storage
 	db *storage.DB
 	// Filter
 	flt *filter.Filter
+	// Detector
+	det *detector.Detector
+	// Replication
+	rep *replication.Replication
 )
 
 // Init globals.
 func Init(c *config.Config, d *storage.DB) {
-	cfg = c
+	SetConfig(c)
 	db = d
 }
 
 // Start http server.
-func Start(c *config.Config, d *storage.DB, f *filter.Filter) {
+func Start(c *config.Config, d *storage.DB, f *filter.Filter, dt *detector.Detector, rp *replication.Replication) {
 	// Init globals.
-	cfg = c
+	SetConfig(c)
 	db = d
 	flt = f
+	det = dt
+	rep = rp
 	// Auth
-	auth := newAuthHandler(cfg.Webapp.Auth[0], cfg.Webapp.Auth[1])
+	auth := newAuthHandler(c.Webapp.Auth[0], c.Webapp.Auth[1])
 	// Routes
 	router := httprouter.New()
 	// Api
 	router.GET("/api/config", auth.handler(getConfig))
+	router.POST("/api/config/reload", auth.handler(reloadConfig))
 	router.GET("/api/interval", getInterval)
 	router.GET("/api/privateDocUrl", getPrivateDocURL)
 	router.GET("/api/language", getLanguage)
@@ -65,12 +78,33 @@ func Start(c *config.Config, d *storage.DB, f *filter.Filter) {
 	router.GET("/api/metric/rules/:name", getMetricRules)
 	router.GET("/api/metric/indexes", getMetricIndexes)
 	router.GET("/api/metric/data", getMetrics)
+	router.POST("/api/metrics", auth.handler(postMetrics))
+	router.POST("/api/metrics/import", auth.handler(importMetrics))
+	router.DELETE("/api/metric/:name", auth.handler(deleteMetric))
+	router.DELETE("/api/metrics", auth.handler(deleteMetrics))
+	router.GET("/api/events", getEvents)
+	router.GET("/api/snoozes", getSnoozes)
+	router.POST("/api/snooze", auth.handler(createSnooze))
+	router.POST("/api/ack", auth.handler(ackAlert))
+	router.DELETE("/api/snooze/:id", auth.handler(deleteSnooze))
+	router.GET("/api/maintenances", getMaintenances)
+	router.POST("/api/maintenance", auth.handler(createMaintenance))
+	router.DELETE("/api/maintenance/:id", auth.handler(deleteMaintenance))
+	router.GET("/api/detector/settings", getDetectorSettings)
+	router.POST("/api/detector/setting", auth.handler(createDetectorSetting))
+	router.PATCH("/api/detector/setting/:id", auth.handler(updateDetectorSetting))
+	router.DELETE("/api/detector/setting/:id", auth.handler(deleteDetectorSetting))
+	router.POST("/api/admin/backup", auth.handler(backupStorage))
+	router.POST("/api/replay", auth.handler(replay))
+	router.GET("/api/replication", getReplication)
+	router.POST("/api/replication/promote", auth.handler(promoteReplication))
 	router.GET("/api/info", getInfo)
 	router.GET("/api/version", getVersion)
+	router.GET("/metrics", getPrometheusMetrics)
 	// Static
-	router.NotFound = newStaticHandler(http.Dir(cfg.Webapp.Static), auth)
+	router.NotFound = newStaticHandler(http.Dir(c.Webapp.Static), auth)
 	// Serve
-	addr := fmt.Sprintf("0.0.0.0:%d", cfg.Webapp.Port)
+	addr := fmt.Sprintf("0.0.0.0:%d", c.Webapp.Port)
 	log.Infof("webapp is listening and serving on %s..", addr)
-	log.Fatal(http.ListenAndServe(addr, router))
+	log.Fatal(http.ListenAndServe(addr, newReplicaHandler(router)))
 }
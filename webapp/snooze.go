@@ -0,0 +1,141 @@
+// Copyright 2016 Eleme Inc. All rights reserved.
+
+package webapp
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eleme/banshee/models"
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+)
+
+// getSnoozes returns all active snoozes and acknowledgements.
+func getSnoozes(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	now := uint32(time.Now().Unix())
+	snoozes := make([]models.Snooze, 0)
+	if err := db.Admin.DB().Where("until > ?", now).Order("until").Find(&snoozes).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	ResponseJSONOK(w, snoozes)
+}
+
+// createSnooze request
+type createSnoozeRequest struct {
+	Metric   string `json:"metric"`
+	RuleID   int    `json:"ruleID"`
+	Duration uint32 `json:"duration"`
+	Comment  string `json:"comment"`
+}
+
+// validateSnoozeRequest validates the metric and rule of the request.
+func validateSnoozeRequest(req *createSnoozeRequest) *WebError {
+	if err := models.ValidateMetricName(req.Metric); err != nil {
+		return NewValidationWebError(err)
+	}
+	if req.RuleID < 0 {
+		return ErrRuleID
+	}
+	if req.RuleID > 0 {
+		if err := db.Admin.DB().First(&models.Rule{}, req.RuleID).Error; err != nil {
+			switch err {
+			case gorm.RecordNotFound:
+				return ErrRuleNotFound
+			default:
+				return NewUnexceptedWebError(err)
+			}
+		}
+	}
+	return nil
+}
+
+// createSnooze stops notifications of a metric on a rule for a duration.
+func createSnooze(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Request
+	req := &createSnoozeRequest{}
+	if err := RequestBind(r, req); err != nil {
+		ResponseError(w, ErrBadRequest)
+		return
+	}
+	// Validate
+	if err := validateSnoozeRequest(req); err != nil {
+		ResponseError(w, err)
+		return
+	}
+	if err := models.ValidateSnoozeDuration(req.Duration); err != nil {
+		ResponseError(w, NewValidationWebError(err))
+		return
+	}
+	// Create
+	snooze := &models.Snooze{
+		Metric:  req.Metric,
+		RuleID:  req.RuleID,
+		Until:   uint32(time.Now().Unix()) + req.Duration,
+		Comment: req.Comment,
+	}
+	if err := db.Admin.DB().Create(snooze).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	ResponseJSONOK(w, snooze)
+}
+
+// ackAlert acknowledges an active alert of a metric on a rule, the
+// notifications are stopped until the alert is resolved, but at most a
+// period.
+func ackAlert(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Request
+	req := &createSnoozeRequest{}
+	if err := RequestBind(r, req); err != nil {
+		ResponseError(w, ErrBadRequest)
+		return
+	}
+	// Validate
+	if err := validateSnoozeRequest(req); err != nil {
+		ResponseError(w, err)
+		return
+	}
+	// Create
+	snooze := &models.Snooze{
+		Metric:  req.Metric,
+		RuleID:  req.RuleID,
+		Until:   uint32(time.Now().Unix()) + currentConfig().Period,
+		Ack:     true,
+		Comment: req.Comment,
+	}
+	if err := db.Admin.DB().Create(snooze).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+	ResponseJSONOK(w, snooze)
+}
+
+// deleteSnooze deletes a snooze or an acknowledgement.
+func deleteSnooze(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Params
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		ResponseError(w, ErrSnoozeID)
+		return
+	}
+	// Find
+	snooze := &models.Snooze{}
+	if err := db.Admin.DB().First(snooze, id).Error; err != nil {
+		switch err {
+		case gorm.RecordNotFound:
+			ResponseError(w, ErrSnoozeNotFound)
+			return
+		default:
+			ResponseError(w, NewUnexceptedWebError(err))
+			return
+		}
+	}
+	// Delete
+	if err := db.Admin.DB().Delete(snooze).Error; err != nil {
+		ResponseError(w, NewUnexceptedWebError(err))
+		return
+	}
+}